@@ -0,0 +1,256 @@
+package vcard
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// All returns the fields of type T in the order they appear in the VCard
+func All[T FieldFormatter](v *VCard) []T {
+	var fields []T
+	for _, f := range v.Fields {
+		if f, ok := f.(T); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// First returns the first field of type T, reporting whether the VCard has one
+func First[T FieldFormatter](v *VCard) (T, bool) {
+	for _, f := range v.Fields {
+		if f, ok := f.(T); ok {
+			return f, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Preferred returns the preferred field of type T, reporting whether the VCard has one. The field with
+// the lowest PREF parameter wins, TYPE=pref counts as the highest preference. Without any preference
+// the first field is returned.
+func Preferred[T FieldFormatter](v *VCard) (T, bool) {
+	var (
+		best  T
+		rank  int
+		found bool
+	)
+	for _, f := range v.Fields {
+		t, ok := f.(T)
+		if !ok {
+			continue
+		}
+		if r := prefRank(f, v.Version); !found || r < rank {
+			best, rank, found = t, r, true
+		}
+	}
+	return best, found
+}
+
+// prefRank returns the preference of a field from 1 to 100, fields without a preference rank 101
+func prefRank(f FieldFormatter, version string) int {
+	l, err := f.Format(version)
+	if err == ErrVersion {
+		l, err = f.Format("4.0")
+	}
+	if err != nil {
+		return 101
+	}
+	p, err := parseProperty(l)
+	if err != nil {
+		return 101
+	}
+	if n, err := strconv.Atoi(p.Params.Get("PREF")); err == nil && n >= 1 && n <= 100 {
+		return n
+	}
+	for _, t := range p.Params["TYPE"] {
+		if strings.EqualFold(t, TelPref) {
+			return 1
+		}
+	}
+	return 101
+}
+
+// checkVersion returns an error if a field isn't supported by the version of the VCard
+func (v *VCard) checkVersion(fields []FieldFormatter) error {
+	for _, f := range fields {
+		if _, err := f.Format(v.Version); err == ErrVersion {
+			return fmt.Errorf("%T is an unsupported field for vCard version %s", f, v.Version)
+		}
+	}
+	return nil
+}
+
+// checkFields returns an error if a field isn't supported by the version of the VCard or is invalid
+func (v *VCard) checkFields(fields []FieldFormatter) error {
+	if err := v.checkVersion(fields); err != nil {
+		return err
+	}
+	return (&VCard{Version: v.Version, Fields: fields}).validateFields()
+}
+
+// setFields replaces the fields of the VCard, unless that makes a valid VCard fail Validate.
+// Cards which are still being built, and are invalid already, only get the fields replaced.
+func (v *VCard) setFields(fields []FieldFormatter) error {
+	if err := (&VCard{Version: v.Version, Fields: fields}).Validate(); err != nil && v.Validate() == nil {
+		return err
+	}
+	v.Fields = fields
+	return nil
+}
+
+// Remove removes the fields for which match returns true and returns the number of removed fields.
+// Nothing is removed when it would leave the VCard without a field required by its version.
+func (v *VCard) Remove(match func(FieldFormatter) bool) (int, error) {
+	kept := make([]FieldFormatter, 0, len(v.Fields))
+	for _, f := range v.Fields {
+		if !match(f) {
+			kept = append(kept, f)
+		}
+	}
+
+	before, after := v.fieldMap(), (&VCard{Fields: kept}).fieldMap()
+	for _, req := range versions[v.Version].required {
+		if before[req] > 0 && after[req] == 0 {
+			return 0, fmt.Errorf("%s is a required field for vCard version %s", req, v.Version)
+		}
+	}
+
+	n := len(v.Fields) - len(kept)
+	v.Fields = kept
+	return n, nil
+}
+
+// sameKind reports whether two fields describe the same property, which are fields of the same type
+// or generic properties with the same name
+func sameKind(a, b FieldFormatter) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	pa, ok := a.(Property)
+	if !ok {
+		return true
+	}
+	return strings.EqualFold(pa.Name, b.(Property).Name)
+}
+
+// Replace replaces the fields of the same kind as f by f, in the place of the first one. Fields of a
+// generic Property are replaced when they have the same name. When the VCard has no such field f is
+// inserted. The VCard is left unchanged when f is invalid or the VCard would no longer pass Validate.
+func (v *VCard) Replace(f FieldFormatter) error {
+	if err := v.checkFields([]FieldFormatter{f}); err != nil {
+		return err
+	}
+
+	fields := make([]FieldFormatter, 0, len(v.Fields))
+	replaced := false
+	for _, cur := range v.Fields {
+		if !sameKind(cur, f) {
+			fields = append(fields, cur)
+			continue
+		}
+		if !replaced {
+			fields = append(fields, f)
+			replaced = true
+		}
+	}
+	if !replaced {
+		return v.Insert(f)
+	}
+	return v.setFields(fields)
+}
+
+// Insert adds fields after the last field of the same kind, so fields of a kind stay together, fields
+// of a new kind are appended. No field is inserted if any of them is unsupported by the version or
+// invalid, or if the VCard would no longer pass Validate.
+func (v *VCard) Insert(fields ...FieldFormatter) error {
+	if err := v.checkFields(fields); err != nil {
+		return err
+	}
+
+	result := append([]FieldFormatter(nil), v.Fields...)
+	for _, f := range fields {
+		i := len(result)
+		for j := len(result) - 1; j >= 0; j-- {
+			if sameKind(result[j], f) {
+				i = j + 1
+				break
+			}
+		}
+		result = append(result, nil)
+		copy(result[i+1:], result[i:])
+		result[i] = f
+	}
+	return v.setFields(result)
+}
+
+// Clone returns a deep copy of the VCard, which shares no slices, maps or pointers with the original
+func (v *VCard) Clone() *VCard {
+	if v == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(v)).Interface().(*VCard)
+}
+
+// deepCopy copies a value including everything it refers to through exported fields, unexported
+// fields such as the location of a time.Time are copied as they are
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// UID returns the value of the UID property, or an empty string if the VCard has none
+func (v *VCard) UID() string {
+	for _, f := range v.Fields {
+		if p, ok := f.(Property); ok && strings.EqualFold(p.Name, "UID") && p.Group == "" {
+			return p.Value
+		}
+	}
+	return ""
+}
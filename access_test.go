@@ -0,0 +1,148 @@
+package vcard_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func newForrest(t *testing.T) *vcard.VCard {
+	card, err := vcard.New("4.0",
+		vcard.FN{"Forrest Gump"},
+		vcard.Title{"Shrimp Man"},
+		vcard.Email{Types: []string{vcard.TelHome}, Email: "forrest@example.com"},
+		vcard.Tel{Types: []string{vcard.TelHome}, Number: "+1-404-555-1212"},
+		vcard.Email{Types: []string{vcard.TelWork, vcard.EmailPref}, Email: "gump@example.com"},
+		vcard.Property{Name: "X-QQ", Params: vcard.Params{"PREF": {"2"}}, Value: "21588891"},
+		vcard.Property{Name: "X-QQ", Params: vcard.Params{"PREF": {"1"}}, Value: "21588892"},
+		vcard.Photo{URI: &url.URL{Scheme: "http", Host: "www.example.com", Path: "/forrest.gif"}},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return card
+}
+
+func TestAccessors(t *testing.T) {
+	card := newForrest(t)
+
+	emails := vcard.All[vcard.Email](card)
+	if len(emails) != 2 || emails[0].Email != "forrest@example.com" {
+		t.Fatalf("expected 2 emails, but got %v", emails)
+	}
+	if title, ok := vcard.First[vcard.Title](card); !ok || title.Title != "Shrimp Man" {
+		t.Fatalf("expected %q, but got %v", "Shrimp Man", title)
+	}
+	if _, ok := vcard.First[vcard.Org](card); ok {
+		t.Fatalf("expected no organization")
+	}
+
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{preferredValue(vcard.Preferred[vcard.Email](card)), "gump@example.com"},
+		{preferredValue(vcard.Preferred[vcard.Tel](card)), "+1-404-555-1212"},
+		{preferredValue(vcard.Preferred[vcard.Property](card)), "21588892"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, test.got)
+		}
+	}
+}
+
+// preferredValue returns the value of the preferred field
+func preferredValue(f vcard.FieldFormatter, ok bool) string {
+	switch f := f.(type) {
+	case vcard.Email:
+		return f.Email
+	case vcard.Tel:
+		return f.Number
+	case vcard.Property:
+		return f.Value
+	}
+	return ""
+}
+
+// legacyField is a field which version 4.0 doesn't support
+type legacyField struct{}
+
+func (legacyField) Format(v string) (string, error) {
+	if v == "4.0" {
+		return "", vcard.ErrVersion
+	}
+	return "X-LEGACY:true", nil
+}
+
+func TestMutations(t *testing.T) {
+	card := newForrest(t)
+
+	if err := card.Replace(vcard.Title{"Captain"}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if card.Fields[1] != (vcard.Title{"Captain"}) {
+		t.Fatalf("expected the title to be replaced in place, but got %v", card.Fields)
+	}
+	if err := card.Insert(vcard.Tel{Number: "+1-404-555-1213"}, vcard.Org{Name: "Bubba Gump Shrimp Co."}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if !reflect.DeepEqual(card.Fields[4], vcard.Tel{Number: "+1-404-555-1213"}) {
+		t.Fatalf("expected the phone after the other phone, but got %v", card.Fields)
+	}
+	if _, ok := card.Fields[len(card.Fields)-1].(vcard.Org); !ok {
+		t.Fatalf("expected the organization to be appended, but got %v", card.Fields)
+	}
+
+	n, err := card.Remove(func(f vcard.FieldFormatter) bool {
+		_, ok := f.(vcard.Email)
+		return ok
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 removed emails, but got %d: %v", n, err)
+	}
+	if _, err := card.Remove(func(f vcard.FieldFormatter) bool { return true }); err == nil {
+		t.Fatalf("expected removing the name to fail")
+	}
+	if err := card.Insert(legacyField{}); err == nil {
+		t.Fatalf("expected a legacy field to fail for version 4.0")
+	}
+	before := len(card.Fields)
+	if err := card.Insert(vcard.FN{"Forrest"}, vcard.Email{Email: "not an address"}); err == nil || len(card.Fields) != before {
+		t.Fatalf("expected an invalid email to fail without inserting fields, but got %v: %v", card.Fields, err)
+	}
+	if err := card.Replace(vcard.Email{Email: "not an address"}); err == nil || len(card.Fields) != before {
+		t.Fatalf("expected an invalid email to fail without replacing fields, but got %v: %v", card.Fields, err)
+	}
+	if err := card.Validate(); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	card := newForrest(t)
+	agent, err := vcard.New("3.0", vcard.N{FamilyName: "Blue"}, vcard.FN{"Bubba Blue"})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	card.Fields = append(card.Fields, vcard.Agent{VCard: agent})
+
+	c := card.Clone()
+	if !reflect.DeepEqual(c, card) {
+		t.Fatalf("expected %v, but got %v", card, c)
+	}
+
+	c.Fields[2].(vcard.Email).Types[0] = "changed"
+	c.Fields[5].(vcard.Property).Params["PREF"][0] = "3"
+	c.Fields[7].(vcard.Photo).URI.Host = "changed"
+	c.Fields[8].(vcard.Agent).VCard.Fields[0] = vcard.N{FamilyName: "changed"}
+	if card.Fields[8].(vcard.Agent).VCard.Fields[0] != (vcard.N{FamilyName: "Blue"}) {
+		t.Fatalf("expected the original agent to be unchanged, but got %v", card.Fields[8])
+	}
+	card.Fields = card.Fields[:8]
+	if !reflect.DeepEqual(card, newForrest(t)) {
+		t.Fatalf("expected the original to be unchanged, but got %v", card)
+	}
+}
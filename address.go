@@ -0,0 +1,309 @@
+package vcard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// addressFormat holds the postal conventions of a country, in the style of the address data of
+// Google's libaddressinput. The format uses %A for the street lines, %C for the locality, %S for the
+// region, %Z for the postal code and %n for a new line, other text is copied. The fields listed in
+// upper are written in upper case on envelopes.
+type addressFormat struct {
+	name    string
+	aliases []string
+	format  string
+	upper   string
+	zip     *regexp.Regexp
+}
+
+// defaultAddressFormat is used for countries without a known format
+var defaultAddressFormat = addressFormat{format: "%A%n%C %S %Z"}
+
+// addressFormats contains the postal conventions keyed by ISO 3166-1 alpha-2 country code
+var addressFormats = map[string]addressFormat{
+	"AT": {"Austria", []string{"Österreich"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"AU": {"Australia", nil, "%A%n%C %S %Z", "CS", regexp.MustCompile(`\d{4}`)},
+	"BE": {"Belgium", []string{"België", "Belgique"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"BR": {"Brazil", []string{"Brasil"}, "%A%n%C-%S%n%Z", "CS", regexp.MustCompile(`\d{5}-?\d{3}`)},
+	"CA": {"Canada", nil, "%A%n%C %S %Z", "CSZ", regexp.MustCompile(`(?i)[ABCEGHJKLMNPRSTVXY]\d[A-Z] ?\d[A-Z]\d`)},
+	"CH": {"Switzerland", []string{"Schweiz", "Suisse", "Svizzera"}, "%A%nCH-%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"DE": {"Germany", []string{"Deutschland"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{5}`)},
+	"DK": {"Denmark", []string{"Danmark"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"ES": {"Spain", []string{"España"}, "%A%n%Z %C %S", "CS", regexp.MustCompile(`\d{5}`)},
+	"FI": {"Finland", []string{"Suomi"}, "%A%nFI-%Z %C", "", regexp.MustCompile(`\d{5}`)},
+	"FR": {"France", nil, "%A%n%Z %C", "C", regexp.MustCompile(`\d{2} ?\d{3}`)},
+	"GB": {"United Kingdom", []string{"UK", "Great Britain", "England", "Scotland", "Wales"}, "%A%n%C%n%Z", "CZ", regexp.MustCompile(`(?i)GIR ?0AA|[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}`)},
+	"IE": {"Ireland", []string{"Éire"}, "%A%n%C%n%S%n%Z", "", regexp.MustCompile(`(?i)[\dA-Z]{3} ?[\dA-Z]{4}`)},
+	"IT": {"Italy", []string{"Italia"}, "%A%n%Z %C %S", "CS", regexp.MustCompile(`\d{5}`)},
+	"LT": {"Lithuania", []string{"Lietuva"}, "%A%nLT-%Z %C %S", "", regexp.MustCompile(`\d{5}`)},
+	"LU": {"Luxembourg", nil, "%A%nL-%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"LV": {"Latvia", []string{"Latvija"}, "%A%n%C, %Z", "", regexp.MustCompile(`LV-\d{4}`)},
+	"MX": {"Mexico", []string{"México"}, "%A%n%Z %C, %S", "CS", regexp.MustCompile(`\d{5}`)},
+	"NL": {"Netherlands", []string{"Nederland", "The Netherlands", "Holland"}, "%A%n%Z %C", "", regexp.MustCompile(`(?i)\d{4} ?[A-Z]{2}`)},
+	"NO": {"Norway", []string{"Norge"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{4}`)},
+	"NZ": {"New Zealand", nil, "%A%n%C %Z", "", regexp.MustCompile(`\d{4}`)},
+	"PL": {"Poland", []string{"Polska"}, "%A%n%Z %C", "", regexp.MustCompile(`\d{2}-\d{3}`)},
+	"PT": {"Portugal", nil, "%A%n%Z %C", "", regexp.MustCompile(`\d{4}-\d{3}`)},
+	"SE": {"Sweden", []string{"Sverige"}, "%A%nSE-%Z %C", "", regexp.MustCompile(`\d{3} ?\d{2}`)},
+	"US": {"United States of America", []string{"United States", "USA", "U.S.A.", "America"}, "%A%n%C, %S %Z", "CS", regexp.MustCompile(`\d{5}(?:-\d{4})?`)},
+}
+
+// addressCountry returns the code of a country given by name, alias or code, reporting whether it
+// is known
+func addressCountry(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if _, ok := addressFormats[strings.ToUpper(name)]; ok {
+		return strings.ToUpper(name), true
+	}
+	for code, f := range addressFormats {
+		if strings.EqualFold(f.name, name) {
+			return code, true
+		}
+		for _, a := range f.aliases {
+			if strings.EqualFold(a, name) {
+				return code, true
+			}
+		}
+	}
+	return "", false
+}
+
+// formatOf returns the address format of a country code
+func formatOf(country string) addressFormat {
+	if f, ok := addressFormats[strings.ToUpper(country)]; ok {
+		return f
+	}
+	return defaultAddressFormat
+}
+
+// addressToken is either a field, such as 'C', or literal text of an address format line
+type addressToken struct {
+	field rune
+	text  string
+}
+
+// tokenize splits a line of an address format into fields and literal text
+func tokenize(line string) []addressToken {
+	var (
+		tokens []addressToken
+		text   strings.Builder
+	)
+	r := []rune(line)
+	for i := 0; i < len(r); i++ {
+		if r[i] == '%' && i+1 < len(r) {
+			if text.Len() > 0 {
+				tokens = append(tokens, addressToken{text: text.String()})
+				text.Reset()
+			}
+			tokens = append(tokens, addressToken{field: r[i+1]})
+			i++
+			continue
+		}
+		text.WriteRune(r[i])
+	}
+	if text.Len() > 0 {
+		tokens = append(tokens, addressToken{text: text.String()})
+	}
+	return tokens
+}
+
+// streetLines returns the street lines of an address, which are the street, extended address and
+// post office box
+func (f Adr) streetLines() []string {
+	var lines []string
+	for _, s := range []string{f.StreetAddress, f.ExtendedAddress, f.PostOfficeBox} {
+		for _, l := range strings.Split(s, "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return lines
+}
+
+// lines renders the address with the format of its country, given by CC or else by name, leaving
+// out the country when it is the same as from, and in upper case where the postal conventions
+// prefer it when upper is set
+func (f Adr) lines(from string, upper bool) []string {
+	country, known := strings.ToUpper(strings.TrimSpace(f.CC)), true
+	if country == "" {
+		country, known = addressCountry(f.CountryName)
+	}
+	format := formatOf(country)
+	values := map[rune]string{'C': f.Locality, 'S': f.Region, 'Z': f.PostalCode}
+	if upper {
+		for _, c := range format.upper {
+			values[c] = strings.ToUpper(values[c])
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(format.format, "%n") {
+		if line == "%A" {
+			lines = append(lines, f.streetLines()...)
+			continue
+		}
+		// literal text is kept only between fields which are set, or before or after a single one
+		tokens := tokenize(line)
+		var b strings.Builder
+		for i, t := range tokens {
+			if t.field != 0 {
+				b.WriteString(values[t.field])
+				continue
+			}
+			before, after := i == 0, i == len(tokens)-1
+			if i > 0 && tokens[i-1].field != 0 {
+				before = values[tokens[i-1].field] != ""
+			}
+			if i < len(tokens)-1 && tokens[i+1].field != 0 {
+				after = values[tokens[i+1].field] != ""
+			}
+			if before && after {
+				b.WriteString(t.text)
+			}
+		}
+		if l := strings.TrimSpace(b.String()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	if name := strings.TrimSpace(f.CountryName); name != "" && !(known && strings.EqualFold(country, from)) {
+		if upper {
+			name = strings.ToUpper(name)
+		}
+		lines = append(lines, name)
+	}
+	return lines
+}
+
+// Lines returns the address laid out for an envelope sent from the country from, an ISO 3166-1
+// alpha-2 code. The layout follows the postal conventions of the country of the address, the
+// country itself is left out for domestic mail.
+func (f Adr) Lines(from string) []string {
+	return f.lines(from, true)
+}
+
+// LabelText returns the address laid out in the format of its country including the country, as used
+// for the LABEL parameter of ADR
+func (f Adr) LabelText() string {
+	return strings.Join(f.lines("", false), "\n")
+}
+
+// postOfficeBox matches the street lines holding a post office box
+var postOfficeBox = regexp.MustCompile(`(?i)^(?:p\.? ?o\.? ?box|post office box|postfach|boîte postale|b\.?p\.?|apartado|casella postale)\s+\S+`)
+
+// ParseAddress splits a free text address into the components of Adr. The address is given on multiple
+// lines, or on a single line separated by commas. The country is detected from the last line by name
+// or code, otherwise the address is taken to be in the region, an ISO 3166-1 alpha-2 code. The
+// locality, region and postal code are read according to the format of the country.
+func ParseAddress(text, region string) (Adr, error) {
+	var f Adr
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if l = strings.Trim(l, " \t,"); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	single := len(lines) == 1
+	if single {
+		lines = nil
+		for _, l := range strings.Split(text, ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return f, fmt.Errorf("empty address")
+	}
+
+	country := strings.ToUpper(region)
+	if code, ok := addressCountry(lines[len(lines)-1]); ok && len(lines) > 1 {
+		country, f.CountryName = code, lines[len(lines)-1]
+		lines = lines[:len(lines)-1]
+	}
+	format := formatOf(country)
+
+	// the lines after the street lines are matched from the bottom up
+	templates := strings.Split(format.format, "%n")
+	for i := len(templates) - 1; i >= 0 && templates[i] != "%A" && len(lines) > 1; i-- {
+		line := lines[len(lines)-1]
+		lines = lines[:len(lines)-1]
+
+		tokens := tokenize(templates[i])
+		var fields, sep string
+		for j, t := range tokens {
+			if t.field != 0 {
+				fields += string(t.field)
+			} else if j > 0 && j < len(tokens)-1 && tokens[j-1].field == 'C' && tokens[j+1].field == 'S' {
+				sep = strings.TrimSpace(t.text)
+			}
+		}
+
+		if strings.ContainsRune(fields, 'Z') && format.zip != nil {
+			loc := format.zip.FindStringIndex(line)
+			if loc == nil && fields == "Z" {
+				// the line without postal code belongs to the next format line
+				lines = append(lines, line)
+				continue
+			}
+			if loc != nil {
+				f.PostalCode = strings.ToUpper(line[loc[0]:loc[1]])
+				before := line[:loc[0]]
+				// drop a country prefix such as LT-
+				if strings.HasSuffix(before, "-") {
+					before = strings.TrimRight(strings.TrimSuffix(before, "-"), "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+				}
+				line = strings.TrimSpace(before + " " + line[loc[1]:])
+			}
+		}
+		line = strings.Trim(line, " ,-")
+
+		switch {
+		case strings.ContainsRune(fields, 'C') && strings.ContainsRune(fields, 'S'):
+			c, s := line, ""
+			words := strings.Fields(line)
+			switch {
+			case sep != "" && strings.Contains(line, sep):
+				i := strings.LastIndex(line, sep)
+				c, s = line[:i], line[i+len(sep):]
+			case sep != "" && single && len(lines) > 1:
+				// the separator split the locality into a line of its own
+				c, s = lines[len(lines)-1], line
+				lines = lines[:len(lines)-1]
+			case len(words) > 1 && (strings.Contains(fields, "SZ") && format.zip != nil || abbreviation(words[len(words)-1])):
+				// a region before the postal code is required, otherwise only abbreviations are taken
+				c, s = strings.Join(words[:len(words)-1], " "), words[len(words)-1]
+			}
+			f.Locality, f.Region = strings.TrimSpace(c), strings.TrimSpace(s)
+		case strings.ContainsRune(fields, 'C'):
+			f.Locality = line
+		case strings.ContainsRune(fields, 'S'):
+			f.Region = line
+		}
+	}
+
+	var street []string
+	for _, l := range lines {
+		if postOfficeBox.MatchString(l) && f.PostOfficeBox == "" {
+			f.PostOfficeBox = l
+			continue
+		}
+		street = append(street, l)
+	}
+	if len(street) > 0 {
+		f.StreetAddress = street[0]
+		f.ExtendedAddress = strings.Join(street[1:], ", ")
+	}
+	return f, nil
+}
+
+// abbreviation reports whether a word is an abbreviation such as a state or province code
+func abbreviation(s string) bool {
+	if len(s) < 2 || len(s) > 3 {
+		return false
+	}
+	return strings.ToUpper(s) == s
+}
@@ -0,0 +1,98 @@
+package vcard_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		text     string
+		region   string
+		expected vcard.Adr
+	}{
+		{
+			"100 Waters Edge\nBaytown, LA 30314\nUnited States of America", "",
+			vcard.Adr{StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America"},
+		},
+		{
+			"100 Waters Edge, Suite 4, Baytown, LA 30314, USA", "",
+			vcard.Adr{StreetAddress: "100 Waters Edge", ExtendedAddress: "Suite 4", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "USA"},
+		},
+		{
+			"42 Plantation St.\nPO Box 12\nWinston-Salem NC 27101", "US",
+			vcard.Adr{PostOfficeBox: "PO Box 12", StreetAddress: "42 Plantation St.", Locality: "Winston-Salem", Region: "NC", PostalCode: "27101"},
+		},
+		{
+			"Bandymų g. 1-1\nLT-01100 Vilnius\nLietuva", "",
+			vcard.Adr{StreetAddress: "Bandymų g. 1-1", Locality: "Vilnius", PostalCode: "01100", CountryName: "Lietuva"},
+		},
+		{
+			"Unter den Linden 1\n10117 Berlin\nGermany", "",
+			vcard.Adr{StreetAddress: "Unter den Linden 1", Locality: "Berlin", PostalCode: "10117", CountryName: "Germany"},
+		},
+		{
+			"10 Downing Street\nLondon\nSW1A 2AA\nUK", "",
+			vcard.Adr{StreetAddress: "10 Downing Street", Locality: "London", PostalCode: "SW1A 2AA", CountryName: "UK"},
+		},
+		{
+			"Via del Corso 1\n00186 Roma RM", "IT",
+			vcard.Adr{StreetAddress: "Via del Corso 1", Locality: "Roma", Region: "RM", PostalCode: "00186"},
+		},
+		{
+			"Damrak 1\n1012 LG Amsterdam", "NL",
+			vcard.Adr{StreetAddress: "Damrak 1", Locality: "Amsterdam", PostalCode: "1012 LG"},
+		},
+		{
+			"Somewhere 1\nAtlantis City", "",
+			vcard.Adr{StreetAddress: "Somewhere 1", Locality: "Atlantis City"},
+		},
+	}
+	for _, test := range tests {
+		a, err := vcard.ParseAddress(test.text, test.region)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if !reflect.DeepEqual(a, test.expected) {
+			t.Fatalf("expected %+v, but got %+v", test.expected, a)
+		}
+	}
+
+	if _, err := vcard.ParseAddress(" \n", "US"); err == nil {
+		t.Fatalf("expected an empty address to fail")
+	}
+}
+
+func TestAdrLines(t *testing.T) {
+	us := vcard.Adr{StreetAddress: "100 Waters Edge", ExtendedAddress: "Suite 4", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America"}
+	lt := vcard.Adr{StreetAddress: "Bandymų g. 1-1", Locality: "Vilnius", PostalCode: "01100", CountryName: "Lithuania"}
+	tests := []struct {
+		adr      vcard.Adr
+		from     string
+		expected string
+	}{
+		{us, "US", "100 Waters Edge\nSuite 4\nBAYTOWN, LA 30314"},
+		{us, "DE", "100 Waters Edge\nSuite 4\nBAYTOWN, LA 30314\nUNITED STATES OF AMERICA"},
+		{lt, "US", "Bandymų g. 1-1\nLT-01100 Vilnius\nLITHUANIA"},
+		{vcard.Adr{StreetAddress: "10 Downing Street", Locality: "London", PostalCode: "sw1a 2aa", CountryName: "GB"}, "GB", "10 Downing Street\nLONDON\nSW1A 2AA"},
+		// literal text next to missing fields is left out
+		{vcard.Adr{StreetAddress: "100 Waters Edge", Region: "LA", CountryName: "USA"}, "US", "100 Waters Edge\nLA"},
+		{vcard.Adr{StreetAddress: "Somewhere 1", Locality: "Atlantis"}, "", "Somewhere 1\nAtlantis"},
+		// countries without a known format keep their region and postal code
+		{vcard.Adr{StreetAddress: "Somewhere 1", Locality: "Atlantis", Region: "Deep", PostalCode: "0042", CountryName: "Atlantis"}, "", "Somewhere 1\nAtlantis Deep 0042\nATLANTIS"},
+		// the country code takes precedence over the name
+		{vcard.Adr{StreetAddress: "Unter den Linden 1", Locality: "Berlin", PostalCode: "10117", CountryName: "Allemagne", CC: "de"}, "DE", "Unter den Linden 1\n10117 Berlin"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(test.adr.Lines(test.from), "\n"); got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+	}
+
+	if label := us.LabelText(); label != "100 Waters Edge\nSuite 4\nBaytown, LA 30314\nUnited States of America" {
+		t.Fatalf("expected the label with the country, but got %q", label)
+	}
+}
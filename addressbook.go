@@ -0,0 +1,440 @@
+package vcard
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrDuplicateUID is returned by AddressBook.Add when a card with the same UID is already present
+var ErrDuplicateUID = errors.New("duplicate UID")
+
+// index maps keys onto the UIDs of the cards they appear in, the keys are kept sorted for prefix searches
+type index struct {
+	uids map[string]map[string]bool
+	keys []string
+}
+
+func newIndex() *index {
+	return &index{uids: make(map[string]map[string]bool)}
+}
+
+func (ix *index) add(key, uid string) {
+	if key == "" {
+		return
+	}
+	set, ok := ix.uids[key]
+	if !ok {
+		set = make(map[string]bool)
+		ix.uids[key] = set
+		i := sort.SearchStrings(ix.keys, key)
+		ix.keys = append(ix.keys, "")
+		copy(ix.keys[i+1:], ix.keys[i:])
+		ix.keys[i] = key
+	}
+	set[uid] = true
+}
+
+func (ix *index) remove(key, uid string) {
+	set, ok := ix.uids[key]
+	if !ok {
+		return
+	}
+	delete(set, uid)
+	if len(set) > 0 {
+		return
+	}
+	delete(ix.uids, key)
+	i := sort.SearchStrings(ix.keys, key)
+	ix.keys = append(ix.keys[:i], ix.keys[i+1:]...)
+}
+
+// prefix adds the UIDs of all keys starting with p to set
+func (ix *index) prefix(p string, set map[string]bool) {
+	if p == "" {
+		return
+	}
+	for i := sort.SearchStrings(ix.keys, p); i < len(ix.keys) && strings.HasPrefix(ix.keys[i], p); i++ {
+		for uid := range ix.uids[ix.keys[i]] {
+			set[uid] = true
+		}
+	}
+}
+
+// fuzzy adds the UIDs of all keys within edit distance d of s to set
+func (ix *index) fuzzy(s string, d int, set map[string]bool) {
+	for _, k := range ix.keys {
+		if abs(len([]rune(k))-len([]rune(s))) <= d && distance(k, s) <= d {
+			for uid := range ix.uids[k] {
+				set[uid] = true
+			}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// distance returns the Levenshtein distance between two strings
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// normalizePhone returns the digits of a phone number, which is how phones are indexed
+func normalizePhone(number string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimPrefix(number, "tel:") {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// phoneKey returns the key of a phone number, which is its E.164 form with extension when it is
+// international and its digits with extension otherwise
+func phoneKey(number string) string {
+	if p, err := ParsePhone(number, ""); err == nil {
+		return p.String()
+	}
+	return canonicalPhone(number)
+}
+
+// nationalNumbers returns the national numbers of a phone number, with and without trunk prefix
+// when it is international and its digits otherwise
+func nationalNumbers(number string) []string {
+	p, err := ParsePhone(number, "")
+	if err != nil {
+		return []string{normalizePhone(strings.SplitN(canonicalPhone(number), ";", 2)[0])}
+	}
+	if trunk := phoneTrunks[p.CountryCode]; trunk != "" {
+		return []string{p.Number, trunk + p.Number}
+	}
+	return []string{p.Number}
+}
+
+// phoneMatch reports whether two phone keys are the same number. Extensions must be equal, a
+// national number matches the international number it ends, with or without the trunk prefix of
+// its country.
+func phoneMatch(a, b string) bool {
+	a, extA, _ := strings.Cut(a, ";ext=")
+	b, extB, _ := strings.Cut(b, ";ext=")
+	if extA != extB {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	if strings.HasPrefix(b, "+") {
+		a, b = b, a
+	}
+	if strings.HasPrefix(b, "+") {
+		return false
+	}
+	if strings.HasPrefix(a, "+") {
+		code, rest := splitCountryCode(a[1:])
+		if trunk := phoneTrunks[code]; trunk != "" && b == trunk+rest {
+			return true
+		}
+		a = a[1:]
+	}
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return len(b) >= 7 && len(a)-len(b) <= 3 && strings.HasSuffix(a, b)
+}
+
+// nameTokens splits a name into lower case words
+func nameTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// keys returns the index keys of a card, which are its emails, phone keys, national numbers and
+// name tokens
+func keys(v *VCard) (emails, phones, nationals, names []string) {
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case Email:
+			emails = append(emails, strings.ToLower(f.Email))
+		case Tel:
+			phones = append(phones, phoneKey(f.Number))
+			nationals = append(nationals, nationalNumbers(f.Number)...)
+		case FN:
+			names = append(names, nameTokens(f.FormattedName)...)
+		case N:
+			for _, s := range []string{f.FamilyName, f.GivenName, f.AdditionalNames, f.HonorificPrefixes, f.HonorificSuffixes} {
+				names = append(names, nameTokens(s)...)
+			}
+		}
+	}
+	return emails, phones, nationals, names
+}
+
+// AddressBook is an in memory collection of VCards indexed by UID, email, phone number and name.
+// It is safe for concurrent use. The cards returned by an AddressBook are shared and must not be
+// modified, Clone them and Put the copy instead.
+type AddressBook struct {
+	mu        sync.RWMutex
+	cards     map[string]*VCard
+	emails    *index
+	phones    *index
+	nationals *index
+	names     *index
+}
+
+// NewAddressBook returns an address book holding the cards
+func NewAddressBook(cards ...*VCard) (*AddressBook, error) {
+	b := &AddressBook{
+		cards:     make(map[string]*VCard),
+		emails:    newIndex(),
+		phones:    newIndex(),
+		nationals: newIndex(),
+		names:     newIndex(),
+	}
+	for _, c := range cards {
+		if _, err := b.Add(c); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Add stores a copy of the card and returns its UID, a UID is generated when the card has none.
+// It returns ErrDuplicateUID if the address book already holds a card with the UID.
+func (b *AddressBook) Add(card *VCard) (string, error) {
+	return b.put(card, false)
+}
+
+// Put stores a copy of the card and returns its UID, replacing the card with the same UID
+func (b *AddressBook) Put(card *VCard) (string, error) {
+	return b.put(card, true)
+}
+
+func (b *AddressBook) put(card *VCard, replace bool) (string, error) {
+	c := card.Clone()
+	uid := c.UID()
+	if uid == "" {
+		var err error
+		if uid, err = newUID(); err != nil {
+			return "", err
+		}
+		c.Fields = append(c.Fields, Property{Name: "UID", Value: uid})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.cards[uid]; ok {
+		if !replace {
+			return "", ErrDuplicateUID
+		}
+		b.unindex(uid)
+	}
+	b.cards[uid] = c
+	emails, phones, nationals, names := keys(c)
+	for _, k := range emails {
+		b.emails.add(k, uid)
+	}
+	for _, k := range phones {
+		b.phones.add(k, uid)
+	}
+	for _, k := range nationals {
+		b.nationals.add(k, uid)
+	}
+	for _, k := range names {
+		b.names.add(k, uid)
+	}
+	return uid, nil
+}
+
+// unindex removes the card with the UID, the lock must be held
+func (b *AddressBook) unindex(uid string) {
+	emails, phones, nationals, names := keys(b.cards[uid])
+	for _, k := range emails {
+		b.emails.remove(k, uid)
+	}
+	for _, k := range phones {
+		b.phones.remove(k, uid)
+	}
+	for _, k := range nationals {
+		b.nationals.remove(k, uid)
+	}
+	for _, k := range names {
+		b.names.remove(k, uid)
+	}
+	delete(b.cards, uid)
+}
+
+// Delete removes the card with the UID, reporting whether it was present
+func (b *AddressBook) Delete(uid string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.cards[uid]; !ok {
+		return false
+	}
+	b.unindex(uid)
+	return true
+}
+
+// Get returns the card with the UID
+func (b *AddressBook) Get(uid string) (*VCard, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c, ok := b.cards[uid]
+	return c, ok
+}
+
+// Len returns the number of cards
+func (b *AddressBook) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.cards)
+}
+
+// All returns all cards, sorted by formatted name
+func (b *AddressBook) All() []*VCard {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	set := make(map[string]bool, len(b.cards))
+	for uid := range b.cards {
+		set[uid] = true
+	}
+	return b.sorted(set)
+}
+
+// ByEmail returns the cards with the email address, compared case insensitively
+func (b *AddressBook) ByEmail(email string) []*VCard {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sorted(b.emails.uids[strings.ToLower(email)])
+}
+
+// ByPhone returns the cards with the phone number. International numbers are compared by country
+// code, national number and extension regardless of how they are written, national numbers match
+// the international numbers they end, with or without trunk prefix.
+func (b *AddressBook) ByPhone(number string) []*VCard {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	key := phoneKey(number)
+	set := make(map[string]bool)
+	for _, k := range b.phones.keys {
+		if phoneMatch(k, key) {
+			for uid := range b.phones.uids[k] {
+				set[uid] = true
+			}
+		}
+	}
+	return b.sorted(set)
+}
+
+// Search returns the cards matching every word of the query, sorted by formatted name. A word
+// matches the start of a word in the name, of an email address or of the digits of a phone number
+// with or without its country code.
+func (b *AddressBook) Search(query string) []*VCard {
+	return b.search(query, func(word string, set map[string]bool) {
+		b.names.prefix(word, set)
+		b.emails.prefix(word, set)
+		if digits := normalizePhone(word); digits != "" {
+			b.phones.prefix("+"+digits, set)
+			b.phones.prefix(digits, set)
+			b.nationals.prefix(digits, set)
+		}
+	})
+}
+
+// SearchFuzzy returns the cards with a name matching every word of the query within an edit
+// distance of maxDistance, or starting with the word, sorted by formatted name
+func (b *AddressBook) SearchFuzzy(query string, maxDistance int) []*VCard {
+	return b.search(query, func(word string, set map[string]bool) {
+		b.names.prefix(word, set)
+		b.names.fuzzy(word, maxDistance, set)
+	})
+}
+
+func (b *AddressBook) search(query string, match func(word string, set map[string]bool)) []*VCard {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var result map[string]bool
+	for _, w := range words {
+		set := make(map[string]bool)
+		match(w, set)
+		if result != nil {
+			for uid := range result {
+				if !set[uid] {
+					delete(result, uid)
+				}
+			}
+		} else {
+			result = set
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return b.sorted(result)
+}
+
+// sorted returns the cards of the UIDs sorted by formatted name and UID, the lock must be held
+func (b *AddressBook) sorted(uids map[string]bool) []*VCard {
+	if len(uids) == 0 {
+		return nil
+	}
+	type entry struct {
+		name, uid string
+	}
+	entries := make([]entry, 0, len(uids))
+	for uid := range uids {
+		e := entry{uid: uid}
+		if fn, ok := First[FN](b.cards[uid]); ok {
+			e.name = strings.ToLower(fn.FormattedName)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return entries[i].uid < entries[j].uid
+	})
+
+	cards := make([]*VCard, len(entries))
+	for i, e := range entries {
+		cards[i] = b.cards[e.uid]
+	}
+	return cards
+}
@@ -0,0 +1,163 @@
+package vcard_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func names(cards []*vcard.VCard) []string {
+	var n []string
+	for _, c := range cards {
+		fn, _ := vcard.First[vcard.FN](c)
+		n = append(n, fn.FormattedName)
+	}
+	return n
+}
+
+func TestAddressBook(t *testing.T) {
+	forrest := vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.Email{Email: "Forrest@example.com"},
+		vcard.Tel{Number: "+1 (404) 555-1212"},
+		vcard.Property{Name: "UID", Value: "forrest"},
+	}}
+	jenny := vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Jenny Curran"},
+		vcard.Email{Email: "jenny@example.org"},
+		vcard.Tel{Number: "tel:+1-404-555-1213"},
+	}}
+	bubba := vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Benjamin Buford Blue"},
+		vcard.Email{Email: "bubba@example.com"},
+		vcard.Property{Name: "UID", Value: "bubba"},
+	}}
+
+	book, err := vcard.NewAddressBook(&forrest, &jenny, &bubba)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if book.Len() != 3 {
+		t.Fatalf("expected 3 cards, but got %d", book.Len())
+	}
+	if jenny.UID() != "" {
+		t.Fatalf("expected the card to be copied, but got UID %q", jenny.UID())
+	}
+	if _, err := book.Add(&forrest); err != vcard.ErrDuplicateUID {
+		t.Fatalf("expected %v, but got: %v", vcard.ErrDuplicateUID, err)
+	}
+
+	tests := []struct {
+		name     string
+		got      []*vcard.VCard
+		expected []string
+	}{
+		{"all", book.All(), []string{"Benjamin Buford Blue", "Forrest Gump", "Jenny Curran"}},
+		{"email", book.ByEmail("forrest@EXAMPLE.com"), []string{"Forrest Gump"}},
+		{"phone", book.ByPhone("+1 404 555 1213"), []string{"Jenny Curran"}},
+		{"phone written differently", book.ByPhone("+14045551212"), []string{"Forrest Gump"}},
+		{"national phone", book.ByPhone("(404) 555-1212"), []string{"Forrest Gump"}},
+		{"name prefix", book.Search("gu"), []string{"Forrest Gump"}},
+		{"words", book.Search("b blue"), []string{"Benjamin Buford Blue"}},
+		{"email prefix", book.Search("bubba@"), []string{"Benjamin Buford Blue"}},
+		{"phone prefix", book.Search("1404"), []string{"Forrest Gump", "Jenny Curran"}},
+		{"national phone prefix", book.Search("404-555"), []string{"Forrest Gump", "Jenny Curran"}},
+		{"no match", book.Search("forrest blue"), nil},
+		{"fuzzy", book.SearchFuzzy("jeny curan", 1), []string{"Jenny Curran"}},
+		{"fuzzy distance", book.SearchFuzzy("gimp", 0), nil},
+	}
+	for _, test := range tests {
+		got := names(test.got)
+		if fmt.Sprint(got) != fmt.Sprint(test.expected) {
+			t.Fatalf("%s: expected %v, but got %v", test.name, test.expected, got)
+		}
+	}
+
+	forrest.Fields[0] = vcard.FN{"Captain Forrest Gump"}
+	if _, err := book.Put(&forrest); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got := names(book.Search("captain")); len(got) != 1 {
+		t.Fatalf("expected the updated card to be indexed, but got %v", got)
+	}
+	if !book.Delete("bubba") || book.Delete("bubba") {
+		t.Fatalf("expected the card to be deleted once")
+	}
+	if got := book.Search("blue"); got != nil {
+		t.Fatalf("expected the deleted card to be unindexed, but got %v", names(got))
+	}
+}
+
+func TestAddressBookPhones(t *testing.T) {
+	card := func(name, number string) *vcard.VCard {
+		return &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{name}, vcard.Tel{Number: number}}}
+	}
+	book, err := vcard.NewAddressBook(
+		card("Dan Taylor", "+1 404 555 1212 x12"),
+		card("Bubba Blue", "+1 404 555 1212 x13"),
+		card("Jenny Curran", "+370 612 34567"),
+		card("Forrest Gump", "+31 6 12345678"),
+		card("Mrs. Gump", "+44 (0)20 7946 0958"),
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	tests := []struct {
+		number   string
+		expected []string
+	}{
+		{"+1-404-555-1212;ext=12", []string{"Dan Taylor"}},
+		{"(404) 555-1212 x13", []string{"Bubba Blue"}},
+		{"+1 404 555 1212", nil},
+		{"8 612 34567", []string{"Jenny Curran"}},
+		{"00370 61234567", []string{"Jenny Curran"}},
+		{"06 12345678", []string{"Forrest Gump"}},
+		{"020 7946 0958", []string{"Mrs. Gump"}},
+	}
+	for _, test := range tests {
+		if got := names(book.ByPhone(test.number)); fmt.Sprint(got) != fmt.Sprint(test.expected) {
+			t.Fatalf("%s: expected %v, but got %v", test.number, test.expected, got)
+		}
+	}
+	if got := names(book.Search("0612")); fmt.Sprint(got) != "[Forrest Gump]" {
+		t.Fatalf("expected %v, but got %v", []string{"Forrest Gump"}, got)
+	}
+}
+
+func TestAddressBookConcurrency(t *testing.T) {
+	book, err := vcard.NewAddressBook()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				uid := fmt.Sprintf("%d-%d", i, j)
+				card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+					vcard.FN{fmt.Sprintf("Shrimp %d", j)},
+					vcard.Property{Name: "UID", Value: uid},
+				}}
+				if _, err := book.Put(card); err != nil {
+					t.Errorf("expected to pass, but got: %v", err)
+				}
+				book.Search("shrimp")
+				if j%2 == 0 {
+					book.Delete(uid)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if book.Len() != 200 || len(book.Search("shrimp")) != 200 {
+		t.Fatalf("expected 200 cards, but got %d", book.Len())
+	}
+}
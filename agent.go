@@ -0,0 +1,124 @@
+package vcard
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxDepth is the maximum nesting of agent vCards when the Decoder has no MaxDepth, and when
+// formatting
+const DefaultMaxDepth = 4
+
+// ErrAgentDepth is returned when agent vCards are nested deeper than the maximum depth
+var ErrAgentDepth = errors.New("agent vCards nested too deeply")
+
+// agentDepth returns the nesting of the agent vCards of the VCard, or a value above limit if they
+// are nested deeper, which includes agents referring to themselves
+func (v *VCard) agentDepth(limit int) int {
+	if limit < 0 {
+		return 0
+	}
+	d := 0
+	for _, f := range v.Fields {
+		if a, ok := f.(Agent); ok && a.VCard != nil {
+			if n := 1 + a.VCard.agentDepth(limit-1); n > d {
+				d = n
+			}
+		}
+	}
+	return d
+}
+
+// nested returns the VCard of the agent formatted in the version
+func (f Agent) nested(v string) (string, error) {
+	if 1+f.VCard.agentDepth(DefaultMaxDepth-1) > DefaultMaxDepth {
+		return "", ErrAgentDepth
+	}
+	return (&VCard{Version: v, Fields: f.VCard.Fields}).Generate()
+}
+
+// related returns the agent as RELATED property of version 4.0
+func (f Agent) related() (string, error) {
+	p := Property{Name: "RELATED", Params: Params{"TYPE": {"agent"}}}
+	switch {
+	case f.VCard != nil:
+		nested, err := f.nested("4.0")
+		if err != nil {
+			return "", err
+		}
+		p.Value = "data:text/vcard;base64," + base64.StdEncoding.EncodeToString([]byte(nested))
+	case f.URI != nil:
+		p.Value = f.URI.String()
+	default:
+		p.Params["VALUE"] = []string{"text"}
+		p.Value = escapeText(f.Text)
+	}
+	return p.Format("4.0")
+}
+
+// decodeRelated decodes RELATED;TYPE=agent of version 4.0 as Agent, other relations are kept as
+// Property. An embedded VCard is returned as text, which the Decoder parses.
+func decodeRelated(v string, p Property) (FieldFormatter, error) {
+	if t := types(p.Params); len(t) != 1 || t[0] != "agent" {
+		return p, nil
+	}
+	if strings.EqualFold(p.Params.Get("VALUE"), "text") {
+		return Agent{Text: unescapeText(p.Value)}, nil
+	}
+	const data = "data:text/vcard"
+	if len(p.Value) > len(data) && strings.EqualFold(p.Value[:len(data)], data) {
+		i := strings.IndexByte(p.Value, ',')
+		if i < 0 {
+			return p, nil
+		}
+		if strings.HasSuffix(strings.ToLower(p.Value[:i]), ";base64") {
+			b, err := base64.StdEncoding.DecodeString(p.Value[i+1:])
+			if err != nil {
+				return p, nil
+			}
+			return Agent{Text: string(b)}, nil
+		}
+		s, err := url.PathUnescape(p.Value[i+1:])
+		if err != nil {
+			return p, nil
+		}
+		return Agent{Text: s}, nil
+	}
+	u, err := url.Parse(p.Value)
+	if err != nil || u.Scheme == "" {
+		return p, nil
+	}
+	return Agent{URI: u}, nil
+}
+
+// isNestedCard reports whether the text of an agent is a vCard
+func isNestedCard(s string) bool {
+	return len(s) >= 11 && strings.EqualFold(s[:11], "BEGIN:VCARD")
+}
+
+// decodeAgent parses the vCard in the text of an agent, as written by version 3.0 or a data URI,
+// one level deeper than the decoder. Text which isn't a valid vCard is kept as it is.
+func (d *Decoder) decodeAgent(a Agent) (Agent, error) {
+	sub := &Decoder{Strict: d.Strict, MaxDepth: d.MaxDepth, depth: d.depth + 1}
+	sub.s = bufio.NewScanner(strings.NewReader(a.Text))
+	sub.s.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	nested, err := sub.Decode()
+	if errors.Is(err, ErrAgentDepth) {
+		return a, ErrAgentDepth
+	}
+	if err != nil {
+		return a, nil
+	}
+	return Agent{VCard: nested}, nil
+}
+
+// maxDepth returns the maximum nesting of agent vCards
+func (d *Decoder) maxDepth() int {
+	if d.MaxDepth > 0 {
+		return d.MaxDepth
+	}
+	return DefaultMaxDepth
+}
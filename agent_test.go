@@ -0,0 +1,169 @@
+package vcard_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func agentCard(version string, fields ...vcard.FieldFormatter) *vcard.VCard {
+	return &vcard.VCard{Version: version, Fields: append([]vcard.FieldFormatter{vcard.FN{"Forrest Gump"}}, fields...)}
+}
+
+func TestAgentRoundTrip(t *testing.T) {
+	bubba := &vcard.VCard{Version: "3.0", Fields: []vcard.FieldFormatter{
+		vcard.N{FamilyName: "Blue", GivenName: "Benjamin", AdditionalNames: "Buford"},
+		vcard.FN{"Bubba; Blue, Jr."},
+		vcard.Tel{Types: []string{"work"}, Number: "+1-111-555-1212"},
+	}}
+	for _, version := range []string{"2.1", "3.0", "4.0"} {
+		s, err := agentCard(version, vcard.Agent{VCard: bubba}, vcard.Title{"Shrimp Man"}).Generate()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		cards, err := vcard.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("expected %q to pass, but got: %v", s, err)
+		}
+		if len(cards) != 1 || len(cards[0].Fields) != 3 {
+			t.Fatalf("expected 1 card with 3 fields, but got %+v", cards)
+		}
+		agent, ok := cards[0].Fields[1].(vcard.Agent)
+		if !ok || agent.VCard == nil {
+			t.Fatalf("expected a nested agent, but got %+v", cards[0].Fields[1])
+		}
+		if agent.VCard.Version != version {
+			t.Fatalf("expected %q, but got %q", version, agent.VCard.Version)
+		}
+		fn, _ := vcard.First[vcard.FN](agent.VCard)
+		if fn.FormattedName != "Bubba; Blue, Jr." {
+			t.Fatalf("expected %q, but got %q", "Bubba; Blue, Jr.", fn.FormattedName)
+		}
+		if _, ok := cards[0].Fields[2].(vcard.Title); !ok {
+			t.Fatalf("expected the title after the agent, but got %+v", cards[0].Fields[2])
+		}
+	}
+}
+
+func TestAgentURI(t *testing.T) {
+	u, _ := url.Parse("urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6")
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"2.1", "AGENT;VALUE=URL:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"},
+		{"3.0", "AGENT;VALUE=uri:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"},
+		{"4.0", "RELATED;TYPE=agent:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"},
+	}
+	for _, test := range tests {
+		got, err := vcard.Agent{URI: u}.Format(test.version)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+
+		cards, err := vcard.Parse(strings.NewReader("BEGIN:VCARD\nVERSION:" + test.version + "\n" + got + "\nEND:VCARD\n"))
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if a, ok := cards[0].Fields[0].(vcard.Agent); !ok || a.URI == nil || a.URI.String() != u.String() {
+			t.Fatalf("expected the agent URI, but got %+v", cards[0].Fields[0])
+		}
+	}
+
+	cards, err := vcard.Parse(strings.NewReader("BEGIN:VCARD\nVERSION:4.0\nRELATED;TYPE=friend:urn:uuid:1\nEND:VCARD\n"))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if _, ok := cards[0].Fields[0].(vcard.Property); !ok {
+		t.Fatalf("expected other relations to be kept as property, but got %+v", cards[0].Fields[0])
+	}
+}
+
+func TestAgentEmpty21(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:2.1\nFN:Forrest Gump\nAGENT:\nTITLE:Shrimp Man\nEND:VCARD\n"
+	cards, err := vcard.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards[0].Fields) != 3 {
+		t.Fatalf("expected 3 fields, but got %+v", cards[0].Fields)
+	}
+	if _, ok := cards[0].Fields[2].(vcard.Title); !ok {
+		t.Fatalf("expected the title after the empty agent, but got %+v", cards[0].Fields[2])
+	}
+}
+
+func TestAgentDepth(t *testing.T) {
+	card := agentCard("2.1")
+	for i := 0; i < 3; i++ {
+		card = agentCard("2.1", vcard.Agent{VCard: card})
+	}
+	s, err := card.Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	if _, err := vcard.NewDecoder(strings.NewReader(s)).Decode(); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	d := vcard.NewDecoder(strings.NewReader(s))
+	d.MaxDepth = 2
+	if _, err := d.Decode(); !errors.Is(err, vcard.ErrAgentDepth) {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrAgentDepth, err)
+	}
+
+	card.Version = "3.0"
+	if s, err = card.Generate(); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	d = vcard.NewDecoder(strings.NewReader(s))
+	d.MaxDepth = 2
+	if _, err := d.Decode(); !errors.Is(err, vcard.ErrAgentDepth) {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrAgentDepth, err)
+	}
+
+	loop := agentCard("3.0")
+	loop.Fields = append(loop.Fields, vcard.Agent{VCard: loop})
+	if _, err := loop.Generate(); err != vcard.ErrAgentDepth {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrAgentDepth, err)
+	}
+}
+
+func TestAgentProperties21(t *testing.T) {
+	bubba := agentCard("2.1", vcard.N{FamilyName: "Blue", GivenName: "Bubba"})
+	card := agentCard("2.1", vcard.Agent{VCard: bubba}, vcard.N{FamilyName: "Gump", GivenName: "Forrest"})
+
+	patched, conflicts, err := vcard.Patch(card, nil)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected to pass, but got: %v %+v", err, conflicts)
+	}
+	agent, ok := patched.Fields[1].(vcard.Agent)
+	if !ok || agent.VCard == nil {
+		t.Fatalf("expected a nested agent, but got %+v", patched.Fields[1])
+	}
+	expected, _ := card.Generate()
+	if got, _ := patched.Generate(); got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	c, err := card.Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if strings.Count(string(c), "\n") != strings.Count(string(c), "\r\n") {
+		t.Fatalf("expected only CRLF line endings, but got %q", c)
+	}
+	parsed, err := vcard.Parse(strings.NewReader(expected))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if pc, _ := parsed[0].Canonical(); string(pc) != string(c) {
+		t.Fatalf("expected %q, but got %q", c, pc)
+	}
+}
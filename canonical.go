@@ -0,0 +1,228 @@
+package vcard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// foldLength is the maximum length in octets of a line of the canonical form
+const foldLength = 75
+
+// caseInsensitiveParams are the parameters whose values are compared case insensitively
+var caseInsensitiveParams = map[string]bool{
+	"TYPE":      true,
+	"VALUE":     true,
+	"ENCODING":  true,
+	"CHARSET":   true,
+	"CALSCALE":  true,
+	"MEDIATYPE": true,
+}
+
+// normalizeEscapes writes the escape sequences of a value in a single way, \N as \n and escaped
+// characters which need no escaping without backslash, keeping the structure of the value
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteString(`\n`)
+		case '\\', ';', ',':
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// canonical returns a property as content line which is the same regardless of the case of the
+// names and case insensitive parameter values, the order of the parameters and types and the
+// escaping of the value
+func canonical(p Property) string {
+	params := make(Params, len(p.Params))
+	for k, vals := range p.Params {
+		k = strings.ToUpper(k)
+		var values []string
+		for _, v := range vals {
+			if k == "TYPE" {
+				// TYPE=work,voice and TYPE=work;TYPE=voice are the same
+				values = append(values, strings.Split(v, ",")...)
+			} else {
+				values = append(values, v)
+			}
+		}
+		if caseInsensitiveParams[k] {
+			for i := range values {
+				values[i] = strings.ToLower(values[i])
+			}
+		}
+		if k == "TYPE" {
+			sort.Strings(values)
+			values = unique(values)
+		}
+		params[k] = append(params[k], values...)
+	}
+	return propertyName(p) + params.String() + ":" + normalizeEscapes(p.Value)
+}
+
+// unique removes adjacent duplicates from a sorted slice
+func unique(s []string) []string {
+	var u []string
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			u = append(u, v)
+		}
+	}
+	return u
+}
+
+// fold folds a content line into lines of at most foldLength octets, without splitting UTF-8
+// sequences
+func fold(l string) string {
+	var b strings.Builder
+	for len(l) > foldLength {
+		n := foldLength
+		if b.Len() > 0 {
+			// continuation lines start with a space
+			n--
+		}
+		for n > 0 && l[n]&0xc0 == 0x80 {
+			n--
+		}
+		b.WriteString(l[:n])
+		b.WriteString("\r\n ")
+		l = l[n:]
+	}
+	b.WriteString(l)
+	return b.String()
+}
+
+// canonicalField returns a field normalized by its syntax only. Normalizations which depend on
+// URISchemes, IMPPPlatforms or the dialing conventions of phone numbers aren't applied, so
+// extending these doesn't change canonical forms and the signatures made over them.
+func canonicalField(f FieldFormatter) FieldFormatter {
+	switch n := f.(type) {
+	case Email:
+		if e, err := n.Normalize(); err == nil {
+			return e
+		}
+	case FbURL:
+		if n.URL != nil {
+			if u, err := uriSyntax(n.URL); err == nil {
+				return FbURL{u}
+			}
+		}
+	case Photo:
+		if n.URI != nil {
+			if u, err := uriSyntax(n.URI); err == nil {
+				n.URI = u
+			}
+		}
+		return n
+	case Property:
+		if name := strings.ToUpper(n.Name); n.Group == "" && (name == "URL" || name == "SOURCE") {
+			if u, err := url.Parse(n.Value); err == nil && u.Scheme != "" {
+				if u, err = uriSyntax(u); err == nil {
+					n.Value = u.String()
+				}
+			}
+		}
+		return n
+	case Tel:
+		t := n.Types
+		if len(t) == 0 {
+			t = []string{TelVoice}
+		}
+		return Property{Name: "TEL", Params: Params{"TYPE": t}, Value: canonicalPhone(n.Number)}
+	case IMPP:
+		p := Property{Name: "IMPP", Params: Params{}, Value: strings.ToLower(n.Platform) + ":" + n.Handle}
+		if len(n.Types) > 0 {
+			p.Params["TYPE"] = n.Types
+		}
+		return p
+	}
+	return f
+}
+
+// canonicalPhone returns a phone number with only its digits, a leading plus and the extension,
+// without interpreting them
+func canonicalPhone(number string) string {
+	s := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(number), "tel:"))
+	var ext string
+	if m := phoneExtension.FindStringSubmatchIndex(s); m != nil {
+		ext, s = s[m[2]:m[3]], s[:m[0]]
+	}
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	digits := normalizePhone(phoneLetters.Replace(strings.ToUpper(s)))
+	if digits == "" {
+		return number
+	}
+	if strings.HasPrefix(s, "+") {
+		digits = "+" + digits
+	}
+	if ext != "" {
+		digits += ";ext=" + ext
+	}
+	return digits
+}
+
+// canonicalForm returns the canonical form of the VCard without the properties with the excluded
+// name, see Canonical
+func (v *VCard) canonicalForm(exclude string) ([]byte, error) {
+	c := &VCard{Version: v.Version, Fields: make([]FieldFormatter, len(v.Fields))}
+	for i, f := range v.Fields {
+		c.Fields[i] = canonicalField(f)
+	}
+	props, err := c.properties(v.Version)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(props))
+	for _, p := range props {
+		if propertyName(p) != exclude {
+			lines = append(lines, canonical(p))
+		}
+	}
+	sort.Strings(lines)
+	lines = unique(lines)
+	for i := range lines {
+		lines[i] = fold(lines[i])
+	}
+	lines = append([]string{"BEGIN:VCARD", "VERSION:" + v.Version}, lines...)
+	return []byte(strings.Join(append(lines, "END:VCARD"), "\r\n") + "\r\n"), nil
+}
+
+// Canonical returns a deterministic representation of the VCard in its version. Email addresses and
+// URIs are normalized where possible and phone numbers are reduced to their digits, the properties
+// are sorted and written with upper case names, sorted parameters, lower case TYPE values and a
+// single escaping, without duplicates and folded at 75 octets with CRLF line endings. Cards which
+// differ only in these respects have the same canonical form. It depends only on the syntax of the
+// card, not on URISchemes or IMPPPlatforms.
+func (v *VCard) Canonical() ([]byte, error) {
+	return v.canonicalForm("")
+}
+
+// Hash returns the hex encoded SHA-256 hash of the canonical form of the VCard, which is suitable
+// as ETag or cache key
+func (v *VCard) Hash() (string, error) {
+	c, err := v.Canonical()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(c)
+	return hex.EncodeToString(sum[:]), nil
+}
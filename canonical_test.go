@@ -0,0 +1,114 @@
+package vcard_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestCanonical(t *testing.T) {
+	a := "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Forrest Gump\r\nEMAIL;TYPE=WORK,pref:forrest@EXAMPLE.com\r\n" +
+		"TEL;TYPE=voice;TYPE=work:+1 (111) 555-1212\r\nitem1.X-ABLABEL:Shrimp\\: boat\r\nNOTE:Life was like\\Na box\r\n" +
+		"URL:HTTPS://Example.com/forrest\r\nEND:VCARD\r\n"
+	b := "BEGIN:VCARD\nVERSION:4.0\nURL:https://example.com/forrest\nNOTE:Life was like\\na box\n" +
+		"ITEM1.X-ABLABEL:Shrimp: boat\nTEL;TYPE=work,voice:+11115551212\nEMAIL;TYPE=pref;TYPE=work:\n forrest@example.com\n" +
+		"FN:Forrest\n  Gump\nFN:Forrest Gump\nEND:VCARD\n"
+
+	cards := make([]*vcard.VCard, 2)
+	for i, s := range []string{a, b} {
+		parsed, err := vcard.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		cards[i] = parsed[0]
+	}
+	ca, err := cards[0].Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	cb, err := cards[1].Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if string(ca) != string(cb) {
+		t.Fatalf("expected %q, but got %q", ca, cb)
+	}
+	expected := "BEGIN:VCARD\r\nVERSION:4.0\r\nEMAIL;TYPE=pref,work:forrest@example.com\r\nFN:Forrest Gump\r\n" +
+		"ITEM1.X-ABLABEL:Shrimp: boat\r\nNOTE:Life was like\\na box\r\nTEL;TYPE=voice,work:+11115551212\r\n" +
+		"URL:https://example.com/forrest\r\nEND:VCARD\r\n"
+	if string(ca) != expected {
+		t.Fatalf("expected %q, but got %q", expected, ca)
+	}
+
+	ha, _ := cards[0].Hash()
+	hb, _ := cards[1].Hash()
+	if ha != hb || len(ha) != 64 {
+		t.Fatalf("expected equal SHA-256 hashes, but got %q and %q", ha, hb)
+	}
+	cards[1].Fields = append(cards[1].Fields, vcard.Title{"Shrimp Man"})
+	if hc, _ := cards[1].Hash(); hc == ha {
+		t.Fatalf("expected different cards to have different hashes")
+	}
+}
+
+func TestCanonicalFolding(t *testing.T) {
+	note := strings.Repeat("Run, Forrest, run! Ĉu vi ŝatas salikokojn? ", 5)
+	card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.Property{Name: "NOTE", Value: note},
+	}}
+	c, err := card.Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(c), "\r\n"), "\r\n")
+	for _, l := range lines {
+		if len(l) > 75 {
+			t.Fatalf("expected lines of at most 75 octets, but got %d", len(l))
+		}
+		if !utf8.ValidString(l) {
+			t.Fatalf("expected folding not to split characters in %q", l)
+		}
+	}
+
+	parsed, err := vcard.Parse(strings.NewReader(string(c)))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	p, _ := vcard.First[vcard.Property](parsed[0])
+	if p.Value != note {
+		t.Fatalf("expected %q, but got %q", note, p.Value)
+	}
+}
+
+func TestCanonicalRegistries(t *testing.T) {
+	card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.Property{Name: "URL", Value: "GOPHER://Example.com/forrest"},
+		vcard.IMPP{Platform: "Shrimp", Handle: "forrest"},
+		vcard.Tel{Number: "+370 612 34567"},
+	}}
+	before, err := card.Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	// extending the registries doesn't change the canonical form and thus signatures
+	schemes, platforms := vcard.URISchemes["URL"], vcard.IMPPPlatforms
+	defer func() { vcard.URISchemes["URL"], vcard.IMPPPlatforms = schemes, platforms }()
+	vcard.URISchemes["URL"] = append(append([]string(nil), schemes...), "gopher")
+	vcard.IMPPPlatforms = append(append([]vcard.IMPPPlatform(nil), platforms...), vcard.IMPPPlatform{Name: "Shrimp", Scheme: "shrimp", Query: "chat?user="})
+
+	after, err := card.Canonical()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Fatalf("expected %q, but got %q", before, after)
+	}
+	if expected := "IMPP:shrimp:forrest\r\nTEL;TYPE=voice:+37061234567\r\nURL:gopher://example.com/forrest\r\n"; !strings.Contains(string(after), expected) {
+		t.Fatalf("expected %q in %q", expected, after)
+	}
+}
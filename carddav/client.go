@@ -0,0 +1,462 @@
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+// Collection is an address book collection on a remote server
+type Collection struct {
+	Path        string
+	DisplayName string
+	Description string
+	SyncToken   string
+}
+
+// AddressObject is a card on a remote server
+type AddressObject struct {
+	Path string
+	ETag string
+	Card *vcard.VCard
+}
+
+// SyncResult holds the changes to an address book since the previous sync
+type SyncResult struct {
+	Updated   []AddressObject
+	Deleted   []string
+	SyncToken string
+}
+
+// HTTPError is returned by the Client when the server answers with an unexpected status
+type HTTPError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+// Error implements the error interface
+func (err *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", err.Method, err.Path, err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// ObjectError reports a card on a remote server which couldn't be read
+type ObjectError struct {
+	Path string
+	Err  error
+}
+
+// Error implements the error interface
+func (err *ObjectError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Path, err.Err)
+}
+
+// ObjectErrors collects the errors of all cards of a request which couldn't be read
+type ObjectErrors []*ObjectError
+
+// Error implements the error interface
+func (errs ObjectErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Client is a CardDAV client
+type Client struct {
+	HTTPClient *http.Client
+	Endpoint   *url.URL
+	Username   string
+	Password   string
+}
+
+// NewClient returns a client for the CardDAV server at endpoint, if c is nil http.DefaultClient is used
+func NewClient(endpoint string, c *http.Client) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("endpoint %q must be an absolute URL", endpoint)
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &Client{HTTPClient: c, Endpoint: u}, nil
+}
+
+// resolve turns a path or href into an absolute URL on the server
+func (c *Client) resolve(p string) string {
+	u, err := url.Parse(p)
+	if err != nil {
+		return c.Endpoint.String()
+	}
+	return c.Endpoint.ResolveReference(u).String()
+}
+
+// hrefPath returns the path of an href, so hrefs returned as absolute URLs can be compared with paths
+func hrefPath(href string) string {
+	if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+		return u.Path
+	}
+	return href
+}
+
+func (c *Client) request(method, p string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.resolve(p), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// statusError maps an unexpected response onto an error
+func statusError(method, p string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusPreconditionFailed:
+		return ErrPrecondition
+	case http.StatusForbidden:
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResourceSize))
+		if bytes.Contains(b, []byte("valid-sync-token")) {
+			return ErrSyncToken
+		}
+	}
+	return &HTTPError{Method: method, Path: p, StatusCode: resp.StatusCode}
+}
+
+// clientProp contains the properties the client reads from a multistatus response
+type clientProp struct {
+	ETag         string `xml:"DAV: getetag"`
+	DisplayName  string `xml:"DAV: displayname"`
+	SyncToken    string `xml:"DAV: sync-token"`
+	Description  string `xml:"urn:ietf:params:xml:ns:carddav addressbook-description"`
+	AddressData  string `xml:"urn:ietf:params:xml:ns:carddav address-data"`
+	ResourceType struct {
+		AddressBook *struct{} `xml:"urn:ietf:params:xml:ns:carddav addressbook"`
+	} `xml:"DAV: resourcetype"`
+	Principal struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"DAV: current-user-principal"`
+	HomeSet struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"urn:ietf:params:xml:ns:carddav addressbook-home-set"`
+}
+
+type clientResponse struct {
+	Href      string `xml:"DAV: href"`
+	Status    string `xml:"DAV: status"`
+	Propstats []struct {
+		Prop   clientProp `xml:"DAV: prop"`
+		Status string     `xml:"DAV: status"`
+	} `xml:"DAV: propstat"`
+}
+
+// prop returns the properties found on the resource
+func (r clientResponse) prop() clientProp {
+	for _, ps := range r.Propstats {
+		if strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop
+		}
+	}
+	return clientProp{}
+}
+
+// notFound reports whether the response is a 404 status for the resource itself
+func (r clientResponse) notFound() bool {
+	return strings.Contains(r.Status, " 404 ")
+}
+
+type clientMultistatus struct {
+	XMLName   xml.Name         `xml:"DAV: multistatus"`
+	Responses []clientResponse `xml:"DAV: response"`
+	SyncToken string           `xml:"DAV: sync-token"`
+}
+
+// dav sends a PROPFIND or REPORT request and decodes the multistatus response
+func (c *Client) dav(method, p, depth, body string) (*clientMultistatus, error) {
+	h := http.Header{"Content-Type": {"application/xml; charset=utf-8"}}
+	if depth != "" {
+		h.Set("Depth", depth)
+	}
+	resp, err := c.request(method, p, strings.NewReader(xml.Header+body), h)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(method, p, resp)
+	}
+	var ms clientMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+func (c *Client) propfind(p, depth string, props ...string) (*clientMultistatus, error) {
+	return c.dav("PROPFIND", p, depth, `<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav"><D:prop>`+
+		strings.Join(props, "")+`</D:prop></D:propfind>`)
+}
+
+// wellKnown resolves /.well-known/carddav when the endpoint has no path
+func (c *Client) wellKnown() string {
+	if c.Endpoint.Path != "" && c.Endpoint.Path != "/" {
+		return c.Endpoint.Path
+	}
+
+	noRedirect := *c.HTTPClient
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	req, err := http.NewRequest(http.MethodGet, c.resolve("/.well-known/carddav"), nil)
+	if err != nil {
+		return "/"
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		return "/"
+	}
+	resp.Body.Close()
+	if loc := resp.Header.Get("Location"); resp.StatusCode >= 300 && resp.StatusCode < 400 && loc != "" {
+		return hrefPath(c.resolve(loc))
+	}
+	return "/"
+}
+
+// FindAddressBookHomeSet discovers the current user principal and returns its address book home set
+func (c *Client) FindAddressBookHomeSet() (string, error) {
+	start := c.wellKnown()
+	ms, err := c.propfind(start, "0", "<D:current-user-principal/>")
+	if err != nil {
+		return "", err
+	}
+	principal := start
+	if len(ms.Responses) > 0 {
+		if href := ms.Responses[0].prop().Principal.Href; href != "" {
+			principal = hrefPath(href)
+		}
+	}
+
+	ms, err = c.propfind(principal, "0", "<C:addressbook-home-set/>")
+	if err != nil {
+		return "", err
+	}
+	for _, r := range ms.Responses {
+		if href := r.prop().HomeSet.Href; href != "" {
+			return hrefPath(href), nil
+		}
+	}
+	return "", fmt.Errorf("no address book home set for principal %s", principal)
+}
+
+// FindAddressBooks lists the address book collections in a home set
+func (c *Client) FindAddressBooks(homeSet string) ([]Collection, error) {
+	ms, err := c.propfind(homeSet, "1",
+		"<D:resourcetype/>", "<D:displayname/>", "<D:sync-token/>", "<C:addressbook-description/>")
+	if err != nil {
+		return nil, err
+	}
+
+	var books []Collection
+	for _, r := range ms.Responses {
+		p := r.prop()
+		if p.ResourceType.AddressBook == nil {
+			continue
+		}
+		books = append(books, Collection{
+			Path:        hrefPath(r.Href),
+			DisplayName: p.DisplayName,
+			Description: p.Description,
+			SyncToken:   p.SyncToken,
+		})
+	}
+	return books, nil
+}
+
+// ListObjects returns the paths and ETags of all cards in an address book, without the cards
+func (c *Client) ListObjects(book string) ([]AddressObject, error) {
+	ms, err := c.propfind(book, "1", "<D:resourcetype/>", "<D:getetag/>")
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []AddressObject
+	for _, r := range ms.Responses {
+		p := r.prop()
+		if p.ETag == "" || p.ResourceType.AddressBook != nil {
+			continue
+		}
+		objs = append(objs, AddressObject{Path: hrefPath(r.Href), ETag: p.ETag})
+	}
+	return objs, nil
+}
+
+// MultiGet fetches the cards at the given paths of an address book in one request, cards which
+// don't exist are left out. Cards which can't be parsed are reported in an ObjectErrors, along with
+// the other cards.
+func (c *Client) MultiGet(book string, paths ...string) ([]AddressObject, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	fmt.Fprint(&b, `<C:addressbook-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">`)
+	fmt.Fprint(&b, `<D:prop><D:getetag/><C:address-data/></D:prop>`)
+	for _, p := range paths {
+		fmt.Fprintf(&b, "<D:href>%s</D:href>", escape(p))
+	}
+	fmt.Fprint(&b, `</C:addressbook-multiget>`)
+
+	ms, err := c.dav("REPORT", book, "1", b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		objs []AddressObject
+		errs ObjectErrors
+	)
+	for _, r := range ms.Responses {
+		if r.notFound() {
+			continue
+		}
+		o, err := addressObject(r)
+		if err != nil {
+			errs = append(errs, &ObjectError{Path: hrefPath(r.Href), Err: err})
+			continue
+		}
+		objs = append(objs, o)
+	}
+	if len(errs) > 0 {
+		return objs, errs
+	}
+	return objs, nil
+}
+
+func addressObject(r clientResponse) (AddressObject, error) {
+	p := r.prop()
+	cards, err := vcard.Parse(strings.NewReader(p.AddressData))
+	if err != nil {
+		return AddressObject{}, err
+	}
+	if len(cards) != 1 {
+		return AddressObject{}, fmt.Errorf("expected exactly one card, got %d", len(cards))
+	}
+	return AddressObject{Path: hrefPath(r.Href), ETag: p.ETag, Card: cards[0]}, nil
+}
+
+// Sync returns the cards changed and deleted since the sync token, an empty token returns all cards.
+// ErrSyncToken is returned when the server no longer accepts the token and a full sync is required.
+// Changed cards which can't be parsed are reported in an ObjectErrors, along with the result.
+func (c *Client) Sync(book, token string) (*SyncResult, error) {
+	ms, err := c.dav("REPORT", book, "", `<D:sync-collection xmlns:D="DAV:"><D:sync-token>`+escape(token)+
+		`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+	if err != nil {
+		return nil, err
+	}
+
+	res := SyncResult{SyncToken: ms.SyncToken}
+	var changed []string
+	for _, r := range ms.Responses {
+		switch {
+		case r.notFound():
+			res.Deleted = append(res.Deleted, hrefPath(r.Href))
+		case hrefPath(r.Href) != hrefPath(c.resolve(book)):
+			changed = append(changed, hrefPath(r.Href))
+		}
+	}
+
+	res.Updated, err = c.MultiGet(book, changed...)
+	if _, ok := err.(ObjectErrors); err != nil && !ok {
+		return nil, err
+	}
+	return &res, err
+}
+
+// GetCard fetches a single card
+func (c *Client) GetCard(p string) (*AddressObject, error) {
+	resp, err := c.request(http.MethodGet, p, nil, http.Header{"Accept": {"text/vcard"}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(http.MethodGet, p, resp)
+	}
+
+	cards, err := vcard.Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != 1 {
+		return nil, fmt.Errorf("%s: expected exactly one card, got %d", p, len(cards))
+	}
+	return &AddressObject{Path: hrefPath(c.resolve(p)), ETag: resp.Header.Get("ETag"), Card: cards[0]}, nil
+}
+
+// conditionHeader returns the If-Match and If-None-Match headers of the conditions
+func conditionHeader(cond Conditions) http.Header {
+	h := http.Header{}
+	if cond.IfMatch != "" {
+		h.Set("If-Match", cond.IfMatch)
+	}
+	if cond.IfNoneMatch {
+		h.Set("If-None-Match", "*")
+	}
+	return h
+}
+
+// PutCard creates or updates a card and returns its new ETag, which servers may omit. Use the
+// ETag the card was fetched with as IfMatch, or IfNoneMatch to only create new cards, so
+// concurrent changes aren't overwritten; ErrPrecondition is returned when they would be.
+func (c *Client) PutCard(p string, card *vcard.VCard, cond Conditions) (string, error) {
+	data, err := card.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	h := conditionHeader(cond)
+	h.Set("Content-Type", "text/vcard; charset=utf-8")
+	resp, err := c.request(http.MethodPut, p, strings.NewReader(data), h)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return "", statusError(http.MethodPut, p, resp)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// DeleteCard deletes a card, with the same conditions as PutCard
+func (c *Client) DeleteCard(p string, cond Conditions) error {
+	resp, err := c.request(http.MethodDelete, p, nil, conditionHeader(cond))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return statusError(http.MethodDelete, p, resp)
+	}
+	return nil
+}
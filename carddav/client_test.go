@@ -0,0 +1,144 @@
+package carddav_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+	"github.com/arjanvaneersel/vcard/carddav"
+)
+
+func TestClientDiscovery(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	c, err := carddav.NewClient(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	home, err := c.FindAddressBookHomeSet()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if home != "/dav/" {
+		t.Fatalf("expected %q, but got %q", "/dav/", home)
+	}
+
+	books, err := c.FindAddressBooks(home)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(books) != 1 || books[0].Path != "/dav/team/" || books[0].DisplayName != "Team" || books[0].SyncToken == "" {
+		t.Fatalf("expected address book Team, but got %+v", books)
+	}
+
+	objs, err := c.ListObjects(books[0].Path)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(objs) != 2 || objs[0].Path != "/dav/team/a.vcf" || objs[0].ETag == "" || objs[0].Card != nil {
+		t.Fatalf("expected a.vcf and b.vcf without cards, but got %+v", objs)
+	}
+
+	if _, err := carddav.NewClient("/dav/", nil); err == nil {
+		t.Fatalf("expected a relative endpoint to fail")
+	}
+}
+
+func TestClientSync(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	c, err := carddav.NewClient(srv.URL+"/dav/", srv.Client())
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	res, err := c.Sync("/dav/team/", "")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(res.Updated) != 2 || len(res.Deleted) != 0 || res.SyncToken == "" {
+		t.Fatalf("expected two cards and a sync token, but got %+v", res)
+	}
+	a := res.Updated[0]
+	if expected := []vcard.FieldFormatter{vcard.FN{FormattedName: "Forrest Gump"}}; a.Path != "/dav/team/a.vcf" || !reflect.DeepEqual(a.Card.Fields[:1], expected) {
+		t.Fatalf("expected a.vcf with %v, but got %s with %v", expected, a.Path, a.Card.Fields)
+	}
+
+	// writes with a stale ETag don't overwrite concurrent changes
+	if _, err := c.PutCard(a.Path, newCard(t, "Stale"), carddav.Conditions{IfMatch: `"stale"`}); err != carddav.ErrPrecondition {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrPrecondition, err)
+	}
+	if _, err := c.PutCard(a.Path, newCard(t, "Taken"), carddav.Conditions{IfNoneMatch: true}); err != carddav.ErrPrecondition {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrPrecondition, err)
+	}
+	etag, err := c.PutCard(a.Path, newCard(t, "Forrest Gump Jr."), carddav.Conditions{IfMatch: a.ETag})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if etag == "" || etag == a.ETag {
+		t.Fatalf("expected a new ETag, but got %q", etag)
+	}
+	if err := c.DeleteCard("/dav/team/b.vcf", carddav.Conditions{IfMatch: res.Updated[1].ETag}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	next, err := c.Sync("/dav/team/", res.SyncToken)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(next.Updated) != 1 || next.Updated[0].ETag != etag || !reflect.DeepEqual(next.Deleted, []string{"/dav/team/b.vcf"}) {
+		t.Fatalf("expected a.vcf updated and b.vcf deleted, but got %+v", next)
+	}
+	if next.SyncToken == res.SyncToken {
+		t.Fatalf("expected a new sync token")
+	}
+
+	if _, err := c.Sync("/dav/team/", "bogus"); err != carddav.ErrSyncToken {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrSyncToken, err)
+	}
+
+	objs, err := c.MultiGet("/dav/team/", "/dav/team/a.vcf", "/dav/team/b.vcf")
+	if err != nil || len(objs) != 1 || objs[0].ETag != etag {
+		t.Fatalf("expected only a.vcf, but got %+v, err %v", objs, err)
+	}
+	got, err := c.GetCard("/dav/team/a.vcf")
+	if err != nil || got.Path != "/dav/team/a.vcf" || got.ETag != etag {
+		t.Fatalf("expected a.vcf with ETag %s, but got %+v, err %v", etag, got, err)
+	}
+	if _, err := c.GetCard("/dav/team/b.vcf"); err != carddav.ErrNotFound {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrNotFound, err)
+	}
+}
+
+func TestClientObjectErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">`+
+			`<D:response><D:href>/dav/team/a.vcf</D:href><D:propstat><D:prop><D:getetag>"a"</D:getetag>`+
+			`<C:address-data>BEGIN:VCARD&#13;&#10;VERSION:4.0&#13;&#10;FN:Forrest Gump&#13;&#10;END:VCARD&#13;&#10;</C:address-data>`+
+			`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`+
+			`<D:response><D:href>/dav/team/b.vcf</D:href><D:propstat><D:prop><D:getetag>"b"</D:getetag>`+
+			`<C:address-data>not a card</C:address-data>`+
+			`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+	}))
+	defer srv.Close()
+
+	c, err := carddav.NewClient(srv.URL+"/dav/", srv.Client())
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	objs, err := c.MultiGet("/dav/team/", "/dav/team/a.vcf", "/dav/team/b.vcf")
+	errs, ok := err.(carddav.ObjectErrors)
+	if !ok || len(errs) != 1 || errs[0].Path != "/dav/team/b.vcf" {
+		t.Fatalf("expected an error for b.vcf, but got: %v", err)
+	}
+	if len(objs) != 1 || objs[0].Path != "/dav/team/a.vcf" {
+		t.Fatalf("expected a.vcf, but got %+v", objs)
+	}
+}
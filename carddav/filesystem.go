@@ -0,0 +1,333 @@
+package carddav
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileEntry is the last known state of a card file
+type fileEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+type fileBook struct {
+	entries map[string]fileEntry
+	log     *changeLog
+}
+
+// FileStorage is a Storage keeping every address book in a directory below its root,
+// with one .vcf file per card. Changes made to the files by other programs are picked up
+// and reported through sync-collection.
+type FileStorage struct {
+	root  string
+	mu    sync.Mutex
+	books map[string]*fileBook
+}
+
+// NewFileStorage returns a FileStorage for an existing root directory
+func NewFileStorage(root string) (*FileStorage, error) {
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: root, Err: os.ErrInvalid}
+	}
+	return &FileStorage{root: root, books: make(map[string]*fileBook)}, nil
+}
+
+func (s *FileStorage) dir(book string) (string, error) {
+	if !validName(book) {
+		return "", ErrNotFound
+	}
+	dir := filepath.Join(s.root, book)
+	fi, err := os.Stat(dir)
+	if os.IsNotExist(err) || (err == nil && !fi.IsDir()) {
+		return "", ErrNotFound
+	}
+	return dir, err
+}
+
+func (s *FileStorage) book(name string) *fileBook {
+	b, ok := s.books[name]
+	if !ok {
+		b = &fileBook{entries: make(map[string]fileEntry), log: newChangeLog()}
+		s.books[name] = b
+	}
+	return b
+}
+
+// refresh updates the state of a single card file and records it when it changed
+func (s *FileStorage) refresh(book, dir, name string) (fileEntry, bool, error) {
+	b := s.book(book)
+	old, known := b.entries[name]
+
+	fi, err := os.Stat(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		if known {
+			delete(b.entries, name)
+			b.log.record(name, true)
+		}
+		return fileEntry{}, false, nil
+	}
+	if err != nil {
+		return fileEntry{}, false, err
+	}
+	if known && fi.Size() == old.size && fi.ModTime().Equal(old.modTime) {
+		return old, true, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return fileEntry{}, false, err
+	}
+	e := fileEntry{size: fi.Size(), modTime: fi.ModTime(), etag: ETag(data)}
+	b.entries[name] = e
+	if !known || e.etag != old.etag {
+		b.log.record(name, false)
+	}
+	return e, true, nil
+}
+
+// scan refreshes all card files of an address book and returns their names
+func (s *FileStorage) scan(book string) ([]string, error) {
+	dir, err := s.dir(book)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		names []string
+		seen  = make(map[string]bool, len(files))
+	)
+	for _, fi := range files {
+		if fi.IsDir() || !validName(fi.Name()) || !strings.HasSuffix(fi.Name(), ".vcf") {
+			continue
+		}
+		if _, ok, err := s.refresh(book, dir, fi.Name()); err != nil {
+			return nil, err
+		} else if ok {
+			names = append(names, fi.Name())
+			seen[fi.Name()] = true
+		}
+	}
+	for name := range s.book(book).entries {
+		if !seen[name] {
+			s.refresh(book, dir, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AddressBooks implements the Storage interface
+func (s *FileStorage) AddressBooks() ([]AddressBook, error) {
+	files, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []AddressBook
+	for _, fi := range files {
+		if !fi.IsDir() || !validName(fi.Name()) {
+			continue
+		}
+		b, err := s.AddressBook(fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, nil
+}
+
+// AddressBook implements the Storage interface
+func (s *FileStorage) AddressBook(name string) (AddressBook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.scan(name); err != nil {
+		return AddressBook{}, err
+	}
+	return AddressBook{Name: name, DisplayName: name, SyncToken: s.book(name).log.token()}, nil
+}
+
+// Objects implements the Storage interface. Files which don't hold exactly one card, such as files
+// of other programs or cards broken by an editor, are skipped.
+func (s *FileStorage) Objects(book string) ([]Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.scan(book)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]Object, 0, len(names))
+	for _, name := range names {
+		o, err := s.load(book, name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if o.Card, err = parseObject(name, o.Data); err != nil {
+			continue
+		}
+		objs = append(objs, o)
+	}
+	return objs, nil
+}
+
+// Object implements the Storage interface
+func (s *FileStorage) Object(book, name string) (Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(book, name)
+}
+
+// read returns a card file with its parsed card
+func (s *FileStorage) read(book, name string) (Object, error) {
+	o, err := s.load(book, name)
+	if err != nil {
+		return Object{}, err
+	}
+	if o.Card, err = parseObject(name, o.Data); err != nil {
+		return Object{}, err
+	}
+	return o, nil
+}
+
+// load returns a card file without parsing it
+func (s *FileStorage) load(book, name string) (Object, error) {
+	dir, err := s.dir(book)
+	if err != nil {
+		return Object{}, err
+	}
+	if !validName(name) {
+		return Object{}, ErrNotFound
+	}
+
+	e, ok, err := s.refresh(book, dir, name)
+	if err != nil {
+		return Object{}, err
+	}
+	if !ok {
+		return Object{}, ErrNotFound
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Name: name, ETag: e.etag, ModTime: e.modTime, Data: data}, nil
+}
+
+// Put implements the Storage interface, names must have the .vcf extension. The data is written
+// unchanged to a temporary file which is renamed into place so readers never see a partial card.
+func (s *FileStorage) Put(book, name string, data []byte, cond Conditions) (Object, bool, error) {
+	if !validName(name) || !strings.HasSuffix(name, ".vcf") {
+		return Object{}, false, ErrName
+	}
+	card, err := parseObject(name, data)
+	if err != nil {
+		return Object{}, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.dir(book)
+	if err != nil {
+		return Object{}, false, err
+	}
+	old, exists, err := s.refresh(book, dir, name)
+	if err != nil {
+		return Object{}, false, err
+	}
+	if err := cond.check(old.etag); err != nil {
+		return Object{}, false, err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name)
+	if err != nil {
+		return Object{}, false, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Object{}, false, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return Object{}, false, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return Object{}, false, err
+	}
+
+	// forget the old state, the new file may have the same size and modification time
+	delete(s.book(book).entries, name)
+	e, _, err := s.refresh(book, dir, name)
+	if err != nil {
+		return Object{}, false, err
+	}
+	return Object{Name: name, ETag: e.etag, ModTime: e.modTime, Data: data, Card: card}, !exists, nil
+}
+
+// Delete implements the Storage interface
+func (s *FileStorage) Delete(book, name string, cond Conditions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.dir(book)
+	if err != nil {
+		return err
+	}
+	if !validName(name) {
+		return ErrNotFound
+	}
+	e, ok, err := s.refresh(book, dir, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	if err := cond.check(e.etag); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	_, _, err = s.refresh(book, dir, name)
+	return err
+}
+
+// Changes implements the Storage interface
+func (s *FileStorage) Changes(book, token string) ([]string, []string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.scan(book); err != nil {
+		return nil, nil, "", err
+	}
+	log := s.book(book).log
+	changed, deleted, err := log.since(token)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return changed, deleted, log.token(), nil
+}
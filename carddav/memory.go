@@ -0,0 +1,173 @@
+package carddav
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryBook struct {
+	AddressBook
+	objects map[string]Object
+	log     *changeLog
+}
+
+// MemoryStorage is a Storage keeping address books in memory
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	books map[string]*memoryBook
+}
+
+// NewMemoryStorage returns a MemoryStorage containing the given, empty, address books
+func NewMemoryStorage(books ...AddressBook) *MemoryStorage {
+	s := MemoryStorage{books: make(map[string]*memoryBook, len(books))}
+	for _, b := range books {
+		s.books[b.Name] = &memoryBook{
+			AddressBook: b,
+			objects:     make(map[string]Object),
+			log:         newChangeLog(),
+		}
+	}
+	return &s
+}
+
+func (s *MemoryStorage) book(name string) (*memoryBook, error) {
+	b, ok := s.books[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// AddressBooks implements the Storage interface
+func (s *MemoryStorage) AddressBooks() ([]AddressBook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	books := make([]AddressBook, 0, len(s.books))
+	for _, b := range s.books {
+		ab := b.AddressBook
+		ab.SyncToken = b.log.token()
+		books = append(books, ab)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].Name < books[j].Name })
+	return books, nil
+}
+
+// AddressBook implements the Storage interface
+func (s *MemoryStorage) AddressBook(name string) (AddressBook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.book(name)
+	if err != nil {
+		return AddressBook{}, err
+	}
+	ab := b.AddressBook
+	ab.SyncToken = b.log.token()
+	return ab, nil
+}
+
+// Objects implements the Storage interface
+func (s *MemoryStorage) Objects(book string) ([]Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.book(book)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]Object, 0, len(b.objects))
+	for _, o := range b.objects {
+		objs = append(objs, o)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Name < objs[j].Name })
+	return objs, nil
+}
+
+// Object implements the Storage interface
+func (s *MemoryStorage) Object(book, name string) (Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.book(book)
+	if err != nil {
+		return Object{}, err
+	}
+	o, ok := b.objects[name]
+	if !ok {
+		return Object{}, ErrNotFound
+	}
+	return o, nil
+}
+
+// Put implements the Storage interface
+func (s *MemoryStorage) Put(book, name string, data []byte, cond Conditions) (Object, bool, error) {
+	if !validName(name) {
+		return Object{}, false, ErrName
+	}
+	card, err := parseObject(name, data)
+	if err != nil {
+		return Object{}, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.book(book)
+	if err != nil {
+		return Object{}, false, err
+	}
+	old, exists := b.objects[name]
+	if err := cond.check(old.ETag); err != nil {
+		return Object{}, false, err
+	}
+
+	o := Object{
+		Name:    name,
+		ETag:    ETag(data),
+		ModTime: time.Now(),
+		Data:    append([]byte(nil), data...),
+		Card:    card,
+	}
+	b.objects[name] = o
+	b.log.record(name, false)
+	return o, !exists, nil
+}
+
+// Delete implements the Storage interface
+func (s *MemoryStorage) Delete(book, name string, cond Conditions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.book(book)
+	if err != nil {
+		return err
+	}
+	o, ok := b.objects[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := cond.check(o.ETag); err != nil {
+		return err
+	}
+	delete(b.objects, name)
+	b.log.record(name, true)
+	return nil
+}
+
+// Changes implements the Storage interface
+func (s *MemoryStorage) Changes(book, token string) ([]string, []string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.book(book)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	changed, deleted, err := b.log.since(token)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return changed, deleted, b.log.token(), nil
+}
@@ -0,0 +1,241 @@
+package carddav
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// privileges is the current-user-privilege-set of every resource, access control is left to middleware
+const privileges = `<privilege xmlns="DAV:"><read/></privilege><privilege xmlns="DAV:"><write/></privilege>`
+
+func qname(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// allProps are the properties returned for DAV:allprop, per kind of resource
+var (
+	homeProps = []xml.Name{
+		qname(nsDAV, "resourcetype"),
+		qname(nsDAV, "displayname"),
+		qname(nsDAV, "current-user-principal"),
+		qname(nsDAV, "principal-URL"),
+		qname(nsCardDAV, "addressbook-home-set"),
+	}
+	bookProps = []xml.Name{
+		qname(nsDAV, "resourcetype"),
+		qname(nsDAV, "displayname"),
+		qname(nsCardDAV, "addressbook-description"),
+		qname(nsDAV, "sync-token"),
+		qname(nsCS, "getctag"),
+		qname(nsDAV, "supported-report-set"),
+		qname(nsCardDAV, "supported-address-data"),
+		qname(nsCardDAV, "max-resource-size"),
+	}
+	objectProps = []xml.Name{
+		qname(nsDAV, "resourcetype"),
+		qname(nsDAV, "getetag"),
+		qname(nsDAV, "getcontenttype"),
+		qname(nsDAV, "getcontentlength"),
+		qname(nsDAV, "getlastmodified"),
+	}
+)
+
+func href(h string) string {
+	return `<href xmlns="DAV:">` + escape(h) + `</href>`
+}
+
+// target is a resource with the data needed to render its properties
+type target struct {
+	href   string
+	book   *AddressBook
+	object *Object
+}
+
+// prop returns the value of a property of the target as XML, and whether the target has the property
+func (s *Server) prop(t target, n xml.Name) (string, bool) {
+	switch n {
+	case qname(nsDAV, "current-user-principal"), qname(nsDAV, "principal-URL"):
+		return href(s.homeHref()), true
+	case qname(nsDAV, "current-user-privilege-set"):
+		return privileges, true
+	}
+
+	switch {
+	case t.object != nil:
+		return s.objectProp(t.object, n)
+	case t.book != nil:
+		return s.bookProp(t.book, n)
+	}
+
+	switch n {
+	case qname(nsDAV, "resourcetype"):
+		return "<collection/>", true
+	case qname(nsDAV, "displayname"):
+		return "Address books", true
+	case qname(nsCardDAV, "addressbook-home-set"):
+		return href(s.homeHref()), true
+	}
+	return "", false
+}
+
+func (s *Server) bookProp(b *AddressBook, n xml.Name) (string, bool) {
+	switch n {
+	case qname(nsDAV, "resourcetype"):
+		return `<collection/><addressbook xmlns="urn:ietf:params:xml:ns:carddav"/>`, true
+	case qname(nsDAV, "displayname"):
+		if b.DisplayName == "" {
+			return escape(b.Name), true
+		}
+		return escape(b.DisplayName), true
+	case qname(nsCardDAV, "addressbook-description"):
+		return escape(b.Description), b.Description != ""
+	case qname(nsDAV, "sync-token"), qname(nsCS, "getctag"):
+		return escape(b.SyncToken), true
+	case qname(nsDAV, "supported-report-set"):
+		var reports []string
+		for _, r := range []xml.Name{
+			qname(nsCardDAV, "addressbook-query"),
+			qname(nsCardDAV, "addressbook-multiget"),
+			qname(nsDAV, "sync-collection"),
+		} {
+			reports = append(reports, `<supported-report><report><`+r.Local+` xmlns="`+r.Space+`"/></report></supported-report>`)
+		}
+		return strings.Join(reports, ""), true
+	case qname(nsCardDAV, "supported-address-data"):
+		return `<address-data-type content-type="text/vcard" version="3.0"/><address-data-type content-type="text/vcard" version="4.0"/>`, true
+	case qname(nsCardDAV, "max-resource-size"):
+		return strconv.Itoa(maxResourceSize), true
+	}
+	return "", false
+}
+
+func (s *Server) objectProp(o *Object, n xml.Name) (string, bool) {
+	switch n {
+	case qname(nsDAV, "resourcetype"):
+		return "", true
+	case qname(nsDAV, "getetag"):
+		return escape(o.ETag), true
+	case qname(nsDAV, "getcontenttype"):
+		return "text/vcard; charset=utf-8", true
+	case qname(nsDAV, "getlastmodified"):
+		return o.ModTime.UTC().Format(http.TimeFormat), !o.ModTime.IsZero()
+	case qname(nsDAV, "getcontentlength"), qname(nsCardDAV, "address-data"):
+		if n.Local == "getcontentlength" {
+			return strconv.Itoa(len(o.Data)), true
+		}
+		return escape(string(o.Data)), true
+	}
+	return "", false
+}
+
+// response renders the requested properties of a target, with names only returns empty property elements
+func (s *Server) response(t target, names []xml.Name, namesOnly bool) response {
+	var found, missing propValues
+	for _, n := range names {
+		v, ok := s.prop(t, n)
+		switch {
+		case !ok:
+			missing.Values = append(missing.Values, rawProp{XMLName: n})
+		case namesOnly:
+			found.Values = append(found.Values, rawProp{XMLName: n})
+		default:
+			found.Values = append(found.Values, rawProp{XMLName: n, Inner: v})
+		}
+	}
+
+	resp := response{Href: t.href}
+	if len(found.Values) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{found, status(http.StatusOK)})
+	}
+	if len(missing.Values) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{missing, status(http.StatusNotFound)})
+	}
+	return resp
+}
+
+func (s *Server) propfind(w http.ResponseWriter, r *http.Request, res resource) {
+	var req propfindRequest
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxResourceSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		req.AllProp = &struct{}{}
+	} else if err := xml.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	depth := r.Header.Get("Depth")
+
+	targets, err := s.targets(res, depth != "0")
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	var ms multistatus
+	for _, t := range targets {
+		names := req.Prop
+		if req.AllProp != nil || req.PropName != nil {
+			switch {
+			case t.object != nil:
+				names = objectProps
+			case t.book != nil:
+				names = bookProps
+			default:
+				names = homeProps
+			}
+		}
+		ms.Responses = append(ms.Responses, s.response(t, names, req.PropName != nil))
+	}
+	writeXML(w, http.StatusMultiStatus, ms)
+}
+
+// targets returns the resource and, with children, its members
+func (s *Server) targets(res resource, children bool) ([]target, error) {
+	if res.isHome() {
+		targets := []target{{href: s.homeHref()}}
+		if !children {
+			return targets, nil
+		}
+		books, err := s.Storage.AddressBooks()
+		if err != nil {
+			return nil, err
+		}
+		for i := range books {
+			targets = append(targets, target{href: s.bookHref(books[i].Name), book: &books[i]})
+		}
+		return targets, nil
+	}
+
+	book, err := s.Storage.AddressBook(res.book)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.isBook() {
+		o, err := s.Storage.Object(res.book, res.name)
+		if err != nil {
+			return nil, err
+		}
+		return []target{{href: s.objectHref(res.book, o.Name), book: &book, object: &o}}, nil
+	}
+
+	targets := []target{{href: s.bookHref(book.Name), book: &book}}
+	if !children {
+		return targets, nil
+	}
+	objs, err := s.Storage.Objects(res.book)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objs {
+		targets = append(targets, target{href: s.objectHref(res.book, objs[i].Name), book: &book, object: &objs[i]})
+	}
+	return targets, nil
+}
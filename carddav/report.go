@@ -0,0 +1,251 @@
+package carddav
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+// defaultReportProps are returned by reports which don't request any properties
+var defaultReportProps = []xml.Name{qname(nsDAV, "getetag")}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request, res resource) {
+	d := xml.NewDecoder(io.LimitReader(r.Body, maxResourceSize))
+	var start xml.StartElement
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			http.Error(w, "invalid report request", http.StatusBadRequest)
+			return
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			start = se
+			break
+		}
+	}
+
+	var (
+		targets []response
+		token   string
+		err     error
+	)
+	switch start.Name {
+	case qname(nsCardDAV, "addressbook-query"):
+		var req queryRequest
+		if err := d.DecodeElement(&req, &start); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		targets, err = s.query(res, req)
+	case qname(nsCardDAV, "addressbook-multiget"):
+		var req multigetRequest
+		if err := d.DecodeElement(&req, &start); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		targets, err = s.multiget(req)
+	case qname(nsDAV, "sync-collection"):
+		var req syncRequest
+		if err := d.DecodeElement(&req, &start); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		targets, token, err = s.sync(res, req)
+	default:
+		writePrecondition(w, http.StatusForbidden, qname(nsDAV, "supported-report"))
+		return
+	}
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	writeXML(w, http.StatusMultiStatus, multistatus{Responses: targets, SyncToken: token})
+}
+
+func reportProps(names propNames) []xml.Name {
+	if len(names) == 0 {
+		return defaultReportProps
+	}
+	return names
+}
+
+// query runs an addressbook-query against all cards of an address book
+func (s *Server) query(res resource, req queryRequest) ([]response, error) {
+	if !res.isBook() {
+		return nil, ErrNotFound
+	}
+	book, err := s.Storage.AddressBook(res.book)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := s.Storage.Objects(res.book)
+	if err != nil {
+		return nil, err
+	}
+
+	var resps []response
+	for i := range objs {
+		props, err := objs[i].Card.Properties()
+		if err != nil || !req.Filter.match(props) {
+			continue
+		}
+		t := target{href: s.objectHref(res.book, objs[i].Name), book: &book, object: &objs[i]}
+		resps = append(resps, s.response(t, reportProps(req.Prop), false))
+		if req.Limit > 0 && len(resps) == req.Limit {
+			break
+		}
+	}
+	return resps, nil
+}
+
+// multiget returns the requested cards, hrefs which don't point to a card get a 404 status
+func (s *Server) multiget(req multigetRequest) ([]response, error) {
+	var resps []response
+	for _, h := range req.Hrefs {
+		h = strings.TrimSpace(h)
+		res, ok := s.parse(h)
+		if !ok || res.name == "" {
+			resps = append(resps, response{Href: h, Status: status(http.StatusNotFound)})
+			continue
+		}
+
+		book, err := s.Storage.AddressBook(res.book)
+		if err == nil {
+			var o Object
+			if o, err = s.Storage.Object(res.book, res.name); err == nil {
+				t := target{href: h, book: &book, object: &o}
+				resps = append(resps, s.response(t, reportProps(req.Prop), false))
+				continue
+			}
+		}
+		if err != ErrNotFound {
+			return nil, err
+		}
+		resps = append(resps, response{Href: h, Status: status(http.StatusNotFound)})
+	}
+	return resps, nil
+}
+
+// sync answers a sync-collection report with the cards changed and deleted since the sync token
+func (s *Server) sync(res resource, req syncRequest) ([]response, string, error) {
+	if !res.isBook() {
+		return nil, "", ErrNotFound
+	}
+	book, err := s.Storage.AddressBook(res.book)
+	if err != nil {
+		return nil, "", err
+	}
+	changed, deleted, token, err := s.Storage.Changes(res.book, strings.TrimSpace(req.SyncToken))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var resps []response
+	for _, n := range changed {
+		o, err := s.Storage.Object(res.book, n)
+		if err == ErrNotFound {
+			deleted = append(deleted, n)
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		t := target{href: s.objectHref(res.book, n), book: &book, object: &o}
+		resps = append(resps, s.response(t, reportProps(req.Prop), false))
+	}
+	for _, n := range deleted {
+		resps = append(resps, response{Href: s.objectHref(res.book, n), Status: status(http.StatusNotFound)})
+	}
+	return resps, token, nil
+}
+
+// match reports whether the properties of a card pass the filter, an empty filter matches every card
+func (f filter) match(props []vcard.Property) bool {
+	if len(f.PropFilters) == 0 {
+		return true
+	}
+	allOf := f.Test == "allof"
+	for _, pf := range f.PropFilters {
+		if m := pf.match(props); m != allOf {
+			return m
+		}
+	}
+	return allOf
+}
+
+func (pf propFilter) match(props []vcard.Property) bool {
+	var found []vcard.Property
+	for _, p := range props {
+		if strings.EqualFold(p.Name, pf.Name) {
+			found = append(found, p)
+		}
+	}
+
+	if pf.IsNotDefined != nil {
+		return len(found) == 0
+	}
+	if len(pf.TextMatches) == 0 && len(pf.ParamFilters) == 0 {
+		return len(found) > 0
+	}
+	for _, p := range found {
+		if pf.matchProperty(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pf propFilter) matchProperty(p vcard.Property) bool {
+	allOf := pf.Test == "allof"
+	for _, tm := range pf.TextMatches {
+		if m := tm.match(p.Text()); m != allOf {
+			return m
+		}
+	}
+	for _, pm := range pf.ParamFilters {
+		if m := pm.match(p.Params); m != allOf {
+			return m
+		}
+	}
+	return allOf
+}
+
+func (pf paramFilter) match(params vcard.Params) bool {
+	vals := params[strings.ToUpper(pf.Name)]
+	if pf.IsNotDefined != nil {
+		return len(vals) == 0
+	}
+	if pf.TextMatch == nil {
+		return len(vals) > 0
+	}
+	for _, v := range vals {
+		if pf.TextMatch.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tm textMatch) match(s string) bool {
+	needle := tm.Value
+	if tm.Collation != "i;octet" {
+		s, needle = strings.ToLower(s), strings.ToLower(needle)
+	}
+
+	var m bool
+	switch tm.MatchType {
+	case "equals":
+		m = s == needle
+	case "starts-with":
+		m = strings.HasPrefix(s, needle)
+	case "ends-with":
+		m = strings.HasSuffix(s, needle)
+	default:
+		m = strings.Contains(s, needle)
+	}
+	return m != (tm.NegateCondition == "yes")
+}
@@ -0,0 +1,234 @@
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+// maxResourceSize is the largest card accepted by PUT
+const maxResourceSize = 1 << 20
+
+// Server is an http.Handler implementing a CardDAV server. Address books are served
+// below the prefix as <prefix>/<book>/ and cards as <prefix>/<book>/<name>. The prefix
+// itself acts as principal and address book home set, authentication is left to middleware.
+type Server struct {
+	Storage Storage
+	Prefix  string
+}
+
+// NewServer returns a new Server for the storage, mounted at prefix
+func NewServer(s Storage, prefix string) *Server {
+	return &Server{Storage: s, Prefix: "/" + strings.Trim(prefix, "/")}
+}
+
+// resource is a parsed request path
+type resource struct {
+	book string
+	name string
+}
+
+func (r resource) isHome() bool {
+	return r.book == ""
+}
+
+func (r resource) isBook() bool {
+	return r.book != "" && r.name == ""
+}
+
+func (s *Server) prefix() string {
+	return strings.TrimSuffix(s.Prefix, "/")
+}
+
+func (s *Server) homeHref() string {
+	return s.prefix() + "/"
+}
+
+func (s *Server) bookHref(book string) string {
+	return s.prefix() + "/" + url.PathEscape(book) + "/"
+}
+
+func (s *Server) objectHref(book, name string) string {
+	return s.bookHref(book) + url.PathEscape(name)
+}
+
+// parse turns an escaped request path or href into a resource, the segments are unescaped after
+// splitting so names may contain escaped characters such as # and %
+func (s *Server) parse(p string) (resource, bool) {
+	if u, err := url.Parse(p); err == nil {
+		p = u.EscapedPath()
+	}
+	p = path.Clean("/" + p)
+	prefix := s.prefix()
+	if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+		return resource{}, false
+	}
+
+	rel := strings.Trim(strings.TrimPrefix(p, prefix), "/")
+	if rel == "" {
+		return resource{}, true
+	}
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return resource{}, false
+		}
+		parts[i] = part
+	}
+	switch len(parts) {
+	case 1:
+		return resource{book: parts[0]}, true
+	case 2:
+		return resource{book: parts[0], name: parts[1]}, true
+	}
+	return resource{}, false
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/.well-known/carddav" {
+		http.Redirect(w, r, s.homeHref(), http.StatusMovedPermanently)
+		return
+	}
+
+	res, ok := s.parse(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3, addressbook")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		s.propfind(w, r, res)
+	case "REPORT":
+		s.report(w, r, res)
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, res)
+	case http.MethodPut:
+		s.put(w, r, res)
+	case http.MethodDelete:
+		s.delete(w, r, res)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// storageError writes the response for an error returned by the Storage
+func storageError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrPrecondition:
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case ErrName:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case ErrSyncToken:
+		writePrecondition(w, http.StatusForbidden, xml.Name{Space: nsDAV, Local: "valid-sync-token"})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func conditions(r *http.Request) Conditions {
+	return Conditions{
+		IfMatch:     strings.TrimSpace(r.Header.Get("If-Match")),
+		IfNoneMatch: strings.TrimSpace(r.Header.Get("If-None-Match")) == "*",
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, res resource) {
+	if res.name == "" {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	o, err := s.Storage.Object(res.book, res.name)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/vcard; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(o.Data)))
+	h.Set("ETag", o.ETag)
+	if !o.ModTime.IsZero() {
+		h.Set("Last-Modified", o.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if inm := r.Header.Get("If-None-Match"); inm == o.ETag || inm == "*" {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(o.Data)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, res resource) {
+	if res.name == "" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(strings.ToLower(ct), "text/vcard") {
+		writePrecondition(w, http.StatusUnsupportedMediaType, xml.Name{Space: nsCardDAV, Local: "supported-address-data"})
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxResourceSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxResourceSize {
+		writePrecondition(w, http.StatusForbidden, xml.Name{Space: nsCardDAV, Local: "max-resource-size"})
+		return
+	}
+
+	cards, err := vcard.Parse(bytes.NewReader(body))
+	if err == nil && len(cards) == 1 {
+		err = cards[0].Validate()
+	}
+	if err != nil || len(cards) != 1 {
+		writePrecondition(w, http.StatusForbidden, xml.Name{Space: nsCardDAV, Local: "valid-address-data"})
+		return
+	}
+
+	// the body is stored as is, so the ETag identifies the data the client sent
+	o, created, err := s.Storage.Put(res.book, res.name, body, conditions(r))
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	w.Header().Set("ETag", o.ETag)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, res resource) {
+	if res.name == "" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.Storage.Delete(res.book, res.name, conditions(r)); err != nil {
+		storageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
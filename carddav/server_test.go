@@ -0,0 +1,251 @@
+package carddav_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard/carddav"
+)
+
+type testResponse struct {
+	Href      string `xml:"href"`
+	Status    string `xml:"status"`
+	Propstats []struct {
+		Status string `xml:"status"`
+		Prop   struct {
+			ETag        string `xml:"getetag"`
+			DisplayName string `xml:"displayname"`
+			SyncToken   string `xml:"sync-token"`
+			AddressData string `xml:"urn:ietf:params:xml:ns:carddav address-data"`
+			Home        struct {
+				Href string `xml:"DAV: href"`
+			} `xml:"urn:ietf:params:xml:ns:carddav addressbook-home-set"`
+			Type struct {
+				AddressBook *struct{} `xml:"urn:ietf:params:xml:ns:carddav addressbook"`
+			} `xml:"resourcetype"`
+		} `xml:"prop"`
+	} `xml:"propstat"`
+}
+
+type testMultistatus struct {
+	Responses []testResponse `xml:"response"`
+	SyncToken string         `xml:"sync-token"`
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return resp, b
+}
+
+func multistatus(t *testing.T, resp *http.Response, body []byte) testMultistatus {
+	t.Helper()
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusMultiStatus, resp.StatusCode, body)
+	}
+	var ms testMultistatus
+	if err := xml.Unmarshal(body, &ms); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return ms
+}
+
+func report(t *testing.T, srv *httptest.Server, path, body string, header ...string) testMultistatus {
+	t.Helper()
+	resp, b := do(t, srv, "REPORT", path, body, header...)
+	return multistatus(t, resp, b)
+}
+
+const (
+	cardA = "BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nEMAIL;TYPE=work:forrest@example.com\nEND:VCARD\n"
+	cardB = "BEGIN:VCARD\nVERSION:3.0\nN:Curran;Jenny;;;\nFN:Jenny Curran\nEMAIL:jenny@example.org\nEND:VCARD\n"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	storage := carddav.NewMemoryStorage(carddav.AddressBook{Name: "team", DisplayName: "Team"})
+	srv := httptest.NewServer(carddav.NewServer(storage, "/dav"))
+
+	for path, card := range map[string]string{"/dav/team/a.vcf": cardA, "/dav/team/b.vcf": cardB} {
+		resp, body := do(t, srv, http.MethodPut, path, card, "Content-Type", "text/vcard", "If-None-Match", "*")
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, but got %d: %s", http.StatusCreated, resp.StatusCode, body)
+		}
+	}
+	return srv
+}
+
+func TestPropfind(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := do(t, srv, "PROPFIND", "/dav/", `<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+		<prop><C:addressbook-home-set/><displayname/><resourcetype/><getetag/></prop></propfind>`, "Depth", "1")
+	ms := multistatus(t, resp, body)
+
+	if len(ms.Responses) != 2 {
+		t.Fatalf("expected home and one address book, but got %d responses", len(ms.Responses))
+	}
+	if home := ms.Responses[0]; home.Href != "/dav/" || home.Propstats[0].Prop.Home.Href != "/dav/" {
+		t.Fatalf("expected home set /dav/, but got %+v", home)
+	}
+	book := ms.Responses[1]
+	if book.Href != "/dav/team/" || book.Propstats[0].Prop.DisplayName != "Team" || book.Propstats[0].Prop.Type.AddressBook == nil {
+		t.Fatalf("expected address book Team, but got %+v", book)
+	}
+	if len(book.Propstats) != 2 || !strings.Contains(book.Propstats[1].Status, "404") {
+		t.Fatalf("expected getetag to be missing on the address book, but got %+v", book.Propstats)
+	}
+
+	resp, body = do(t, srv, "PROPFIND", "/dav/team/", "", "Depth", "1")
+	ms = multistatus(t, resp, body)
+	if len(ms.Responses) != 3 || ms.Responses[1].Href != "/dav/team/a.vcf" || ms.Responses[1].Propstats[0].Prop.ETag == "" {
+		t.Fatalf("expected the address book and two cards, but got %+v", ms.Responses)
+	}
+}
+
+func TestReport(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	query := `<C:addressbook-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+		<D:prop><D:getetag/><C:address-data/></D:prop>
+		<C:filter test="anyof">
+			<C:prop-filter name="EMAIL">
+				<C:text-match collation="i;unicode-casemap" match-type="ends-with">@EXAMPLE.ORG</C:text-match>
+			</C:prop-filter>
+		</C:filter>
+	</C:addressbook-query>`
+	ms := report(t, srv, "/dav/team/", query, "Depth", "1")
+	if len(ms.Responses) != 1 || ms.Responses[0].Href != "/dav/team/b.vcf" || ms.Responses[0].Propstats[0].Prop.AddressData != cardB {
+		t.Fatalf("expected only b.vcf with its address data, but got %+v", ms.Responses)
+	}
+
+	query = `<C:addressbook-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+		<C:filter test="allof">
+			<C:prop-filter name="N"><C:is-not-defined/></C:prop-filter>
+			<C:prop-filter name="EMAIL"><C:param-filter name="TYPE"><C:text-match>work</C:text-match></C:param-filter></C:prop-filter>
+		</C:filter>
+	</C:addressbook-query>`
+	ms = report(t, srv, "/dav/team/", query)
+	if len(ms.Responses) != 1 || ms.Responses[0].Href != "/dav/team/a.vcf" {
+		t.Fatalf("expected only a.vcf, but got %+v", ms.Responses)
+	}
+
+	multiget := `<C:addressbook-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+		<D:prop><D:getetag/><C:address-data/></D:prop>
+		<D:href>/dav/team/a.vcf</D:href>
+		<D:href>/dav/team/missing.vcf</D:href>
+	</C:addressbook-multiget>`
+	ms = report(t, srv, "/dav/team/", multiget)
+	if len(ms.Responses) != 2 || ms.Responses[0].Propstats[0].Prop.AddressData != cardA || !strings.Contains(ms.Responses[1].Status, "404") {
+		t.Fatalf("expected a.vcf and a 404 for missing.vcf, but got %+v", ms.Responses)
+	}
+}
+
+func TestSyncCollection(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	sync := func(token string) testMultistatus {
+		return report(t, srv, "/dav/team/", `<D:sync-collection xmlns:D="DAV:">
+			<D:sync-token>`+token+`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop>
+		</D:sync-collection>`)
+	}
+
+	ms := sync("")
+	if len(ms.Responses) != 2 || ms.SyncToken == "" {
+		t.Fatalf("expected two cards and a sync token, but got %+v", ms)
+	}
+	etag := ms.Responses[0].Propstats[0].Prop.ETag
+
+	// a stale ETag doesn't delete the card
+	if resp, _ := do(t, srv, http.MethodDelete, "/dav/team/a.vcf", "", "If-Match", `"stale"`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d, but got %d", http.StatusPreconditionFailed, resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodDelete, "/dav/team/a.vcf", "", "If-Match", etag); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodPut, "/dav/team/b.vcf", strings.Replace(cardB, "Jenny Curran", "Jenny Gump", 1)); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status %d, but got %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	next := sync(ms.SyncToken)
+	if len(next.Responses) != 2 || next.Responses[0].Href != "/dav/team/b.vcf" || next.Responses[1].Href != "/dav/team/a.vcf" || !strings.Contains(next.Responses[1].Status, "404") {
+		t.Fatalf("expected b.vcf changed and a.vcf deleted, but got %+v", next.Responses)
+	}
+	if next.SyncToken == ms.SyncToken {
+		t.Fatalf("expected a new sync token")
+	}
+
+	resp, body := do(t, srv, "REPORT", "/dav/team/", `<D:sync-collection xmlns:D="DAV:"><D:sync-token>bogus</D:sync-token></D:sync-collection>`)
+	if resp.StatusCode != http.StatusForbidden || !bytes.Contains(body, []byte("valid-sync-token")) {
+		t.Fatalf("expected a valid-sync-token error, but got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestGetPut(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, body := do(t, srv, http.MethodGet, "/dav/team/a.vcf", "")
+	// the card is served as it was PUT, so the ETag of the PUT identifies it
+	if resp.StatusCode != http.StatusOK || string(body) != cardA || resp.Header.Get("ETag") != carddav.ETag([]byte(cardA)) {
+		t.Fatalf("expected card a.vcf, but got %d: %s", resp.StatusCode, body)
+	}
+	etag := resp.Header.Get("ETag")
+
+	if resp, _ := do(t, srv, http.MethodGet, "/dav/team/a.vcf", "", "If-None-Match", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected status %d, but got %d", http.StatusNotModified, resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodPut, "/dav/team/a.vcf", cardA, "If-Match", `"stale"`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d, but got %d", http.StatusPreconditionFailed, resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodPut, "/dav/team/c.vcf", "BEGIN:VCARD\nVERSION:3.0\nFN:No N\nEND:VCARD\n"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d for an invalid card, but got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodGet, "/dav/other/a.vcf", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, but got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	// escaped characters are part of the name
+	for _, name := range []string{"a#b.vcf", "50%.vcf", "what?.vcf"} {
+		href := "/dav/team/" + url.PathEscape(name)
+		if resp, body := do(t, srv, http.MethodPut, href, cardB); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d for %q, but got %d: %s", http.StatusCreated, name, resp.StatusCode, body)
+		}
+		if resp, body := do(t, srv, http.MethodGet, href, ""); resp.StatusCode != http.StatusOK || string(body) != cardB {
+			t.Fatalf("expected card %q, but got %d: %s", name, resp.StatusCode, body)
+		}
+	}
+	resp, body = do(t, srv, "PROPFIND", "/dav/team/", `<propfind xmlns="DAV:"><prop><getetag/></prop></propfind>`, "Depth", "1")
+	if ms := multistatus(t, resp, body); !strings.Contains(string(body), "/dav/team/a%23b.vcf") || len(ms.Responses) != 6 {
+		t.Fatalf("expected the escaped names to be listed, but got %s", body)
+	}
+
+	resp, _ = do(t, srv, http.MethodOptions, "/dav/team/", "")
+	if !strings.Contains(resp.Header.Get("DAV"), "addressbook") {
+		t.Fatalf("expected addressbook DAV compliance, but got %q", resp.Header.Get("DAV"))
+	}
+}
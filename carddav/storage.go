@@ -0,0 +1,183 @@
+// Package carddav implements a CardDAV (RFC 6352) server for VCards on top of a pluggable Storage,
+// and a Client for syncing remote address books.
+package carddav
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+var (
+	// ErrNotFound is returned by a Storage when an address book or card doesn't exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrPrecondition is returned by a Storage when the conditions of a write don't hold
+	ErrPrecondition = errors.New("precondition failed")
+
+	// ErrSyncToken is returned by a Storage when a sync token is unknown or expired
+	ErrSyncToken = errors.New("invalid sync token")
+
+	// ErrName is returned by a Storage when the name of an address book or card isn't acceptable
+	ErrName = errors.New("invalid name")
+)
+
+// AddressBook describes an address book collection
+type AddressBook struct {
+	Name        string
+	DisplayName string
+	Description string
+	SyncToken   string
+}
+
+// Object is a card stored in an address book. Data holds the card as it was stored, which is
+// served unchanged so the ETag of a PUT remains valid.
+type Object struct {
+	Name    string
+	ETag    string
+	ModTime time.Time
+	Data    []byte
+	Card    *vcard.VCard
+}
+
+// Conditions are the preconditions of a write, taken from the If-Match and If-None-Match headers
+type Conditions struct {
+	// IfMatch requires the current ETag of the card to match, * requires the card to exist
+	IfMatch string
+	// IfNoneMatch requires the card not to exist
+	IfNoneMatch bool
+}
+
+// check verifies the conditions against the current ETag of a card, empty if it doesn't exist
+func (c Conditions) check(etag string) error {
+	if c.IfNoneMatch && etag != "" {
+		return ErrPrecondition
+	}
+	switch c.IfMatch {
+	case "":
+		return nil
+	case "*":
+		if etag == "" {
+			return ErrPrecondition
+		}
+	default:
+		if c.IfMatch != etag {
+			return ErrPrecondition
+		}
+	}
+	return nil
+}
+
+// Storage is the backend of a Server holding address books and their cards. Implementations must be
+// safe for concurrent use and are expected to apply Conditions atomically with the write.
+type Storage interface {
+	// AddressBooks returns all address books
+	AddressBooks() ([]AddressBook, error)
+
+	// AddressBook returns the named address book
+	AddressBook(name string) (AddressBook, error)
+
+	// Objects returns all cards in an address book
+	Objects(book string) ([]Object, error)
+
+	// Object returns a single card of an address book
+	Object(book, name string) (Object, error)
+
+	// Put creates or replaces a card with the data of a single vCard and reports whether it was created
+	Put(book, name string, data []byte, cond Conditions) (Object, bool, error)
+
+	// Delete removes a card
+	Delete(book, name string, cond Conditions) error
+
+	// Changes returns the names of the cards which changed and which were deleted since the sync token,
+	// along with the current sync token. An empty token returns all cards.
+	Changes(book, token string) (changed, deleted []string, current string, err error)
+}
+
+// ETag returns a strong entity tag for the content of a card, it matches the ETag a card is served
+// with by vcard.Serve
+func ETag(b []byte) string {
+	return vcard.ETag(b)
+}
+
+// parseObject parses the data of a card object, which must hold exactly one card
+func parseObject(name string, data []byte) (*vcard.VCard, error) {
+	cards, err := vcard.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != 1 {
+		return nil, fmt.Errorf("%s: expected exactly one card, got %d", name, len(cards))
+	}
+	return cards[0], nil
+}
+
+// validName reports whether a name can safely be used as an address book or card name
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// change is an entry of a changeLog
+type change struct {
+	rev     int
+	deleted bool
+}
+
+// changeLog keeps track of the changes to the cards of an address book for sync-collection
+type changeLog struct {
+	epoch   string
+	rev     int
+	changes map[string]change
+}
+
+func newChangeLog() *changeLog {
+	return &changeLog{
+		// the epoch invalidates tokens handed out by earlier instances
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		changes: make(map[string]change),
+	}
+}
+
+func (l *changeLog) record(name string, deleted bool) {
+	l.rev++
+	l.changes[name] = change{l.rev, deleted}
+}
+
+func (l *changeLog) token() string {
+	return fmt.Sprintf("urn:vcard:sync:%s:%d", l.epoch, l.rev)
+}
+
+func (l *changeLog) since(token string) ([]string, []string, error) {
+	from := 0
+	if token != "" {
+		parts := strings.Split(strings.TrimPrefix(token, "urn:vcard:sync:"), ":")
+		if len(parts) != 2 || parts[0] != l.epoch {
+			return nil, nil, ErrSyncToken
+		}
+		rev, err := strconv.Atoi(parts[1])
+		if err != nil || rev < 0 || rev > l.rev {
+			return nil, nil, ErrSyncToken
+		}
+		from = rev
+	}
+
+	var changed, deleted []string
+	for name, c := range l.changes {
+		switch {
+		case c.rev <= from:
+		case !c.deleted:
+			changed = append(changed, name)
+		case token != "":
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+	return changed, deleted, nil
+}
@@ -0,0 +1,165 @@
+package carddav_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+	"github.com/arjanvaneersel/vcard/carddav"
+)
+
+func newCard(t *testing.T, fn string) *vcard.VCard {
+	t.Helper()
+	card, err := vcard.New("4.0", vcard.FN{FormattedName: fn})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return card
+}
+
+func newData(t *testing.T, fn string) []byte {
+	t.Helper()
+	data, err := newCard(t, fn).Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return []byte(data)
+}
+
+func testStorage(t *testing.T, s carddav.Storage) {
+	if _, err := s.AddressBook("missing"); err != carddav.ErrNotFound {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrNotFound, err)
+	}
+
+	o, created, err := s.Put("team", "a.vcf", newData(t, "Test A"), carddav.Conditions{IfNoneMatch: true})
+	if err != nil || !created {
+		t.Fatalf("expected to create card, but got created %v, err %v", created, err)
+	}
+	if _, _, err := s.Put("team", "a.vcf", newData(t, "Test A"), carddav.Conditions{IfNoneMatch: true}); err != carddav.ErrPrecondition {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrPrecondition, err)
+	}
+	if _, _, err := s.Put("team", "a.vcf", newData(t, "Test A"), carddav.Conditions{IfMatch: `"stale"`}); err != carddav.ErrPrecondition {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrPrecondition, err)
+	}
+
+	_, _, token, err := s.Changes("team", "")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	o2, created, err := s.Put("team", "a.vcf", newData(t, "Test A2"), carddav.Conditions{IfMatch: o.ETag})
+	if err != nil || created {
+		t.Fatalf("expected to replace card, but got created %v, err %v", created, err)
+	}
+	if o2.ETag == o.ETag {
+		t.Fatalf("expected the ETag to change")
+	}
+	if _, _, err := s.Put("team", "b.vcf", newData(t, "Test B"), carddav.Conditions{}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	got, err := s.Object("team", "a.vcf")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if expected := []vcard.FieldFormatter{vcard.FN{FormattedName: "Test A2"}}; !reflect.DeepEqual(got.Card.Fields, expected) || got.ETag != o2.ETag {
+		t.Fatalf("expected %v with ETag %s, but got %v with ETag %s", expected, o2.ETag, got.Card.Fields, got.ETag)
+	}
+
+	if err := s.Delete("team", "b.vcf", carddav.Conditions{IfMatch: `"stale"`}); err != carddav.ErrPrecondition {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrPrecondition, err)
+	}
+	if err := s.Delete("team", "b.vcf", carddav.Conditions{}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	changed, deleted, _, err := s.Changes("team", token)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	// b was created and deleted since the token
+	if !reflect.DeepEqual(changed, []string{"a.vcf"}) || !reflect.DeepEqual(deleted, []string{"b.vcf"}) {
+		t.Fatalf("expected changed [a.vcf] and deleted [b.vcf], but got %v and %v", changed, deleted)
+	}
+	if _, _, _, err := s.Changes("team", "urn:vcard:sync:other:1"); err != carddav.ErrSyncToken {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrSyncToken, err)
+	}
+
+	objs, err := s.Objects("team")
+	if err != nil || len(objs) != 1 || objs[0].Name != "a.vcf" {
+		t.Fatalf("expected only a.vcf, but got %v, err %v", objs, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, carddav.NewMemoryStorage(carddav.AddressBook{Name: "team"}))
+}
+
+func TestFileStorage(t *testing.T) {
+	root, err := ioutil.TempDir("", "carddav")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "team"), 0755); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	s, err := carddav.NewFileStorage(root)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	testStorage(t, s)
+
+	// changes made by other programs are picked up
+	_, _, token, err := s.Changes("team", "")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "team", "c.vcf"), []byte("BEGIN:VCARD\nVERSION:4.0\nFN:Test C\nEND:VCARD\n"), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	changed, _, _, err := s.Changes("team", token)
+	if err != nil || !reflect.DeepEqual(changed, []string{"c.vcf"}) {
+		t.Fatalf("expected changed [c.vcf], but got %v, err %v", changed, err)
+	}
+
+	// both storages keep the data as is and agree on its ETag
+	data := []byte("BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Test D\r\nEND:VCARD\r\n")
+	o, _, err := s.Put("team", "d.vcf", data, carddav.Conditions{})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	mo, _, err := carddav.NewMemoryStorage(carddav.AddressBook{Name: "team"}).Put("team", "d.vcf", data, carddav.Conditions{})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if o.ETag != mo.ETag || o.ETag != carddav.ETag(data) {
+		t.Fatalf("expected ETag %s, but got %s and %s", carddav.ETag(data), o.ETag, mo.ETag)
+	}
+	if got, err := s.Object("team", "d.vcf"); err != nil || string(got.Data) != string(data) {
+		t.Fatalf("expected %q, but got %q, err %v", data, got.Data, err)
+	}
+
+	if _, _, err := s.Put("team", "../escape.vcf", newData(t, "Test"), carddav.Conditions{}); err != carddav.ErrName {
+		t.Fatalf("expected %v, but got: %v", carddav.ErrName, err)
+	}
+	// files which aren't a single card don't fail the address book
+	two := "BEGIN:VCARD\nVERSION:4.0\nFN:Test E\nEND:VCARD\nBEGIN:VCARD\nVERSION:4.0\nFN:Test F\nEND:VCARD\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "team", "e.vcf"), []byte(two), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "team", "f.vcf"), []byte("BEGIN:VCARD\nFN:Test F\n"), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	objs, err := s.Objects("team")
+	if err != nil || len(objs) != 3 || objs[0].Name != "a.vcf" || objs[1].Name != "c.vcf" || objs[2].Name != "d.vcf" {
+		t.Fatalf("expected a.vcf, c.vcf and d.vcf, but got %v, err %v", objs, err)
+	}
+	if _, err := s.Object("team", "e.vcf"); err == nil {
+		t.Fatalf("expected e.vcf to fail")
+	}
+}
@@ -0,0 +1,145 @@
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	nsCS      = "http://calendarserver.org/ns/"
+)
+
+// propNames collects the names of the requested properties of a DAV:prop element
+type propNames []xml.Name
+
+// UnmarshalXML implements the xml.Unmarshaler interface
+func (p *propNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*p = append(*p, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type propfindRequest struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     propNames `xml:"DAV: prop"`
+}
+
+type textMatch struct {
+	Value           string `xml:",chardata"`
+	Collation       string `xml:"collation,attr"`
+	NegateCondition string `xml:"negate-condition,attr"`
+	MatchType       string `xml:"match-type,attr"`
+}
+
+type paramFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:carddav is-not-defined"`
+	TextMatch    *textMatch `xml:"urn:ietf:params:xml:ns:carddav text-match"`
+}
+
+type propFilter struct {
+	Name         string        `xml:"name,attr"`
+	Test         string        `xml:"test,attr"`
+	IsNotDefined *struct{}     `xml:"urn:ietf:params:xml:ns:carddav is-not-defined"`
+	TextMatches  []textMatch   `xml:"urn:ietf:params:xml:ns:carddav text-match"`
+	ParamFilters []paramFilter `xml:"urn:ietf:params:xml:ns:carddav param-filter"`
+}
+
+type filter struct {
+	Test        string       `xml:"test,attr"`
+	PropFilters []propFilter `xml:"urn:ietf:params:xml:ns:carddav prop-filter"`
+}
+
+type queryRequest struct {
+	Prop   propNames `xml:"DAV: prop"`
+	Filter filter    `xml:"urn:ietf:params:xml:ns:carddav filter"`
+	Limit  int       `xml:"urn:ietf:params:xml:ns:carddav limit>nresults"`
+}
+
+type multigetRequest struct {
+	Prop  propNames `xml:"DAV: prop"`
+	Hrefs []string  `xml:"DAV: href"`
+}
+
+type syncRequest struct {
+	Prop      propNames `xml:"DAV: prop"`
+	SyncToken string    `xml:"DAV: sync-token"`
+	SyncLevel string    `xml:"DAV: sync-level"`
+}
+
+// rawProp is a property in a response, with its value as XML
+type rawProp struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+type propValues struct {
+	Values []rawProp
+}
+
+type propstat struct {
+	Prop   propValues `xml:"prop"`
+	Status string     `xml:"status"`
+}
+
+type response struct {
+	Href      string     `xml:"href"`
+	Propstats []propstat `xml:"propstat,omitempty"`
+	Status    string     `xml:"status,omitempty"`
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+// davError is the body of an error response naming the violated precondition
+type davError struct {
+	XMLName   xml.Name `xml:"DAV: error"`
+	Condition rawProp
+}
+
+func status(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// escape returns s escaped for use as XML character data
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func writeXML(w http.ResponseWriter, code int, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "%s%s", xml.Header, b)
+}
+
+func writePrecondition(w http.ResponseWriter, code int, name xml.Name) {
+	writeXML(w, code, davError{Condition: rawProp{XMLName: name}})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func runConvert(e *env, args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	to := fs.String("to", "", "target vCard version (default: keep the version of each card)")
+	format := fs.String("format", "vcf", "output format: vcf, jcard or xcard")
+	lossy := fs.Bool("lossy", false, "drop fields the target version doesn't support instead of failing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *format {
+	case "vcf", "jcard", "xcard":
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	cards, err := e.readCards(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	for n, card := range cards {
+		if *to != "" {
+			if card, err = convert(e, card, *to, *lossy); err != nil {
+				return fmt.Errorf("card %d: %v", n+1, err)
+			}
+		}
+
+		switch *format {
+		case "vcf":
+			err = writeCard(e.stdout, card)
+		case "jcard":
+			err = writeBytes(e, card.JCard)
+		case "xcard":
+			err = writeBytes(e, card.XCard)
+		}
+		if err != nil {
+			return fmt.Errorf("card %d: %v", n+1, err)
+		}
+	}
+	return nil
+}
+
+// convert returns a copy of the card for another version, reporting dropped fields on stderr
+func convert(e *env, card *vcard.VCard, version string, lossy bool) (*vcard.VCard, error) {
+	var fields []vcard.FieldFormatter
+	for _, f := range card.Fields {
+		if _, err := f.Format(version); err == vcard.ErrVersion && lossy {
+			fmt.Fprintf(e.stderr, "vcard: dropping %T, unsupported by version %s\n", f, version)
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return vcard.New(version, fields...)
+}
+
+func writeBytes(e *env, marshal func() ([]byte, error)) error {
+	b, err := marshal()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.stdout, "%s\n", b)
+	return err
+}
@@ -0,0 +1,163 @@
+// Command vcard creates, validates and converts vCards.
+//
+// Usage:
+//
+//	vcard <command> [flags] [files...]
+//
+// The commands are:
+//
+//	new       build a card from flags or a YAML/JSON spec
+//	validate  validate the cards in .vcf files
+//	convert   change the version or format of cards
+//	qr        write a QR code of a card as PNG, SVG or to the terminal
+//	split     write every card of a multi-card file to its own file
+//	merge     combine the cards of several files into one stream
+//
+// Commands read from stdin when no files are given and write to stdout,
+// so they can be combined in shell pipelines.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+// command is a vcard subcommand
+type command struct {
+	usage string
+	run   func(env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"new":      {"build a card from flags or a YAML/JSON spec", runNew},
+	"validate": {"validate the cards in .vcf files", runValidate},
+	"convert":  {"change the version or format of cards", runConvert},
+	"qr":       {"write a QR code of a card as PNG, SVG or to the terminal", runQR},
+	"split":    {"write every card of a multi-card file to its own file", runSplit},
+	"merge":    {"combine the cards of several files into one stream", runMerge},
+}
+
+// env holds the standard streams of a command, so commands can be tested without a process
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errSilent is returned by commands which already reported their errors
+var errSilent = errors.New("silent")
+
+func main() {
+	err := run(&env{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:])
+	if err == errSilent {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vcard: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(e *env, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(e.stderr)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(e.stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(e, args[1:])
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "usage: vcard <command> [flags] [files...]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s%s\n", name, commands[name].usage)
+	}
+}
+
+// input is a named source of cards
+type input struct {
+	name string
+	r    io.ReadCloser
+}
+
+// inputs opens the given files, or stdin when no files or "-" are given
+func (e *env) inputs(files []string) ([]input, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var in []input
+	for _, name := range files {
+		if name == "-" {
+			in = append(in, input{"<stdin>", io.NopCloser(e.stdin)})
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			for _, i := range in {
+				i.r.Close()
+			}
+			return nil, err
+		}
+		in = append(in, input{name, f})
+	}
+	return in, nil
+}
+
+// readCards parses all cards from the given files or stdin
+func (e *env) readCards(files []string) ([]*vcard.VCard, error) {
+	in, err := e.inputs(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*vcard.VCard
+	for _, i := range in {
+		c, err := vcard.Parse(i.r)
+		i.r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", i.name, err)
+		}
+		cards = append(cards, c...)
+	}
+	return cards, nil
+}
+
+// writeCard writes a card followed by a line break
+func writeCard(w io.Writer, card *vcard.VCard) error {
+	s, err := card.Generate()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", s)
+	return err
+}
+
+// multiFlag is a repeatable string flag
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
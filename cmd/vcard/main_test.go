@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err := run(&env{strings.NewReader(stdin), &out, &errOut}, args)
+	return out.String(), err
+}
+
+func TestNew(t *testing.T) {
+	got, err := runCmd(t, "", "new", "-family", "Person", "-given", "Test", "-email", "test@example.com", "-tel", "+3701234567890")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected := "BEGIN:VCARD\nVERSION:4.0\nN:Person;Test;;;\nFN:Test Person\nTEL;TYPE=voice;VALUE=uri:tel:+3701234567890\nEMAIL:test@example.com\nEND:VCARD\n"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+}
+
+func TestNewSpec(t *testing.T) {
+	spec := `
+version: "3.0"
+n: {family: Gump, given: Forrest, prefixes: Mr.}
+org: Bubba Gump Shrimp Co.
+email:
+  - {email: forrest@example.com, types: [internet]}
+`
+	got, err := runCmd(t, spec, "new", "-spec", "-")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected := "BEGIN:VCARD\nVERSION:3.0\nN:Gump;Forrest;;Mr.;\nFN:Forrest Gump\nORG:Bubba Gump Shrimp Co.\nEMAIL;TYPE=internet:forrest@example.com\nEND:VCARD\n"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	if _, err := runCmd(t, `{"version": "3.0", "fn": "Test Person"}`, "new", "-spec", "-"); err == nil {
+		t.Fatalf("expected 3.0 card without N to fail validation")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:4.0\nFN:Test Person\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:3.0\nFN:Test Person\nEND:VCARD\n"
+
+	got, err := runCmd(t, input, "validate", "-v")
+	if err != errSilent {
+		t.Fatalf("expected validation to fail, but got: %v", err)
+	}
+
+	expected := "<stdin>: card 1: ok\n<stdin>: card 2: vcard.N is a required field for vCard version 3.0\n"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:4.0\nN:Person;Test;;;\nFN:Test Person\nGENDER:F\nEND:VCARD\n"
+
+	if _, err := runCmd(t, input, "convert", "-to", "3.0"); err == nil {
+		t.Fatalf("expected conversion of GENDER to 3.0 to fail")
+	}
+
+	got, err := runCmd(t, input, "convert", "-to", "3.0", "-lossy")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if expected := "BEGIN:VCARD\nVERSION:3.0\nN:Person;Test;;;\nFN:Test Person\nEND:VCARD\n"; got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	got, err = runCmd(t, input, "convert", "-format", "jcard")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if !strings.HasPrefix(got, `["vcard",[["version",{},"text","4.0"]`) {
+		t.Fatalf("expected jCard output, but got %q", got)
+	}
+}
+
+func TestQR(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:4.0\nFN:Test Person\nEND:VCARD\n"
+
+	for _, format := range []string{"png", "svg", "term"} {
+		got, err := runCmd(t, input, "qr", "-format", format)
+		if err != nil {
+			t.Fatalf("expected %s to pass, but got: %v", format, err)
+		}
+		if len(got) == 0 {
+			t.Fatalf("expected %s output, but got nothing", format)
+		}
+	}
+}
+
+func TestSplitMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcard")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := "BEGIN:VCARD\nVERSION:4.0\nFN:Test Person\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:4.0\nFN:Test Person\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:4.0\nFN:../Other\nEND:VCARD\n"
+
+	got, err := runCmd(t, input, "split", "-dir", dir)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	files := []string{
+		filepath.Join(dir, "Test_Person.vcf"),
+		filepath.Join(dir, "Test_Person-2.vcf"),
+		filepath.Join(dir, "Other.vcf"),
+	}
+	if expected := strings.Join(files, "\n") + "\n"; got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	got, err = runCmd(t, "", append([]string{"merge"}, files...)...)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got != input {
+		t.Fatalf("expected %q, but got %q", input, got)
+	}
+	// existing files are only overwritten with -f
+	if _, err := runCmd(t, input, "split", "-dir", dir); err == nil {
+		t.Fatalf("expected split to refuse overwriting files")
+	}
+	if _, err := runCmd(t, input, "split", "-f", "-dir", dir); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runCmd(t, "", "frobnicate"); err == nil {
+		t.Fatalf("expected an error for an unknown command")
+	}
+}
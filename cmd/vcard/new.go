@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+	"gopkg.in/yaml.v3"
+)
+
+// spec describes a card in YAML or JSON, JSON being a subset of YAML
+type spec struct {
+	Version string      `yaml:"version"`
+	N       *specName   `yaml:"n"`
+	FN      string      `yaml:"fn"`
+	Org     string      `yaml:"org"`
+	Units   []string    `yaml:"units"`
+	Title   string      `yaml:"title"`
+	Role    string      `yaml:"role"`
+	Photo   string      `yaml:"photo"`
+	Tel     []specTel   `yaml:"tel"`
+	Email   []specEmail `yaml:"email"`
+	Adr     []specAdr   `yaml:"adr"`
+	Bday    string      `yaml:"bday"`
+	Geo     *vcard.Geo  `yaml:"geo"`
+	IMPP    []specIMPP  `yaml:"impp"`
+	Gender  string      `yaml:"gender"`
+	Kind    string      `yaml:"kind"`
+}
+
+type specName struct {
+	Family     string `yaml:"family"`
+	Given      string `yaml:"given"`
+	Additional string `yaml:"additional"`
+	Prefixes   string `yaml:"prefixes"`
+	Suffixes   string `yaml:"suffixes"`
+}
+
+type specTel struct {
+	Number string   `yaml:"number"`
+	Types  []string `yaml:"types"`
+}
+
+type specEmail struct {
+	Email string   `yaml:"email"`
+	Types []string `yaml:"types"`
+}
+
+type specAdr struct {
+	Types           []string `yaml:"types"`
+	PostOfficeBox   string   `yaml:"pobox"`
+	ExtendedAddress string   `yaml:"ext"`
+	StreetAddress   string   `yaml:"street"`
+	Locality        string   `yaml:"locality"`
+	Region          string   `yaml:"region"`
+	PostalCode      string   `yaml:"code"`
+	CountryName     string   `yaml:"country"`
+}
+
+type specIMPP struct {
+	Platform string `yaml:"platform"`
+	Handle   string `yaml:"handle"`
+}
+
+// fields turns the spec into vCard fields
+func (s *spec) fields() ([]vcard.FieldFormatter, error) {
+	var fields []vcard.FieldFormatter
+	if s.N != nil {
+		fields = append(fields, vcard.N{
+			FamilyName:        s.N.Family,
+			GivenName:         s.N.Given,
+			AdditionalNames:   s.N.Additional,
+			HonorificPrefixes: s.N.Prefixes,
+			HonorificSuffixes: s.N.Suffixes,
+		})
+	}
+	if s.FN != "" {
+		fields = append(fields, vcard.FN{FormattedName: s.FN})
+	}
+	if s.Org != "" {
+		fields = append(fields, vcard.Org{Name: s.Org, Units: s.Units})
+	}
+	if s.Title != "" {
+		fields = append(fields, vcard.Title{Title: s.Title})
+	}
+	if s.Role != "" {
+		fields = append(fields, vcard.Role{Role: s.Role})
+	}
+	if s.Photo != "" {
+		u, err := url.Parse(s.Photo)
+		if err != nil {
+			return nil, fmt.Errorf("photo: %v", err)
+		}
+		fields = append(fields, vcard.Photo{URI: u})
+	}
+	for _, t := range s.Tel {
+		fields = append(fields, vcard.Tel{Number: t.Number, Types: t.Types})
+	}
+	for _, e := range s.Email {
+		fields = append(fields, vcard.Email{Email: e.Email, Types: e.Types})
+	}
+	for _, a := range s.Adr {
+		fields = append(fields, vcard.Adr{
+			Types:           a.Types,
+			PostOfficeBox:   a.PostOfficeBox,
+			ExtendedAddress: a.ExtendedAddress,
+			StreetAddress:   a.StreetAddress,
+			Locality:        a.Locality,
+			Region:          a.Region,
+			PostalCode:      a.PostalCode,
+			CountryName:     a.CountryName,
+		})
+	}
+	if s.Bday != "" {
+		t, err := time.Parse("2006-01-02", s.Bday)
+		if err != nil {
+			return nil, fmt.Errorf("bday: %v", err)
+		}
+		fields = append(fields, vcard.Bday{Timestamp: t})
+	}
+	if s.Geo != nil {
+		fields = append(fields, *s.Geo)
+	}
+	for _, i := range s.IMPP {
+		fields = append(fields, vcard.IMPP{Platform: i.Platform, Handle: i.Handle})
+	}
+	if s.Gender != "" {
+		fields = append(fields, vcard.Gender{Val: s.Gender})
+	}
+	if s.Kind != "" {
+		fields = append(fields, vcard.Kind{Text: s.Kind})
+	}
+	return fields, nil
+}
+
+func runNew(e *env, args []string) error {
+	var (
+		fs     = flag.NewFlagSet("new", flag.ContinueOnError)
+		s      spec
+		emails multiFlag
+		tels   multiFlag
+	)
+	fs.SetOutput(e.stderr)
+	specFile := fs.String("spec", "", "read the card from a YAML or JSON `file`, - for stdin")
+	version := fs.String("version", "", "vCard version (default 4.0, or the version of the spec)")
+	family := fs.String("family", "", "family name")
+	given := fs.String("given", "", "given name")
+	fs.StringVar(&s.FN, "fn", "", "formatted name")
+	fs.StringVar(&s.Org, "org", "", "organization")
+	fs.StringVar(&s.Title, "title", "", "job title")
+	fs.Var(&emails, "email", "email address, may be repeated")
+	fs.Var(&tels, "tel", "telephone number, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *specFile != "" {
+		var r io.Reader = e.stdin
+		if *specFile != "-" {
+			f, err := os.Open(*specFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		// flags override the spec
+		fromFlags := s
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(b, &s); err != nil {
+			return fmt.Errorf("%s: %v", *specFile, err)
+		}
+		if fromFlags.FN != "" {
+			s.FN = fromFlags.FN
+		}
+		if fromFlags.Org != "" {
+			s.Org = fromFlags.Org
+		}
+		if fromFlags.Title != "" {
+			s.Title = fromFlags.Title
+		}
+	}
+
+	if *family != "" || *given != "" {
+		if s.N == nil {
+			s.N = &specName{}
+		}
+		if *family != "" {
+			s.N.Family = *family
+		}
+		if *given != "" {
+			s.N.Given = *given
+		}
+	}
+	if s.FN == "" && s.N != nil {
+		s.FN = strings.TrimSpace(s.N.Given + " " + s.N.Family)
+	}
+	for _, addr := range emails {
+		s.Email = append(s.Email, specEmail{Email: addr})
+	}
+	for _, number := range tels {
+		s.Tel = append(s.Tel, specTel{Number: number})
+	}
+
+	if *version != "" {
+		s.Version = *version
+	}
+	if s.Version == "" {
+		s.Version = "4.0"
+	}
+
+	fields, err := s.fields()
+	if err != nil {
+		return err
+	}
+	card, err := vcard.New(s.Version, fields...)
+	if err != nil {
+		return err
+	}
+	return writeCard(e.stdout, card)
+}
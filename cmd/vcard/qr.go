@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+func runQR(e *env, args []string) (err error) {
+	fs := flag.NewFlagSet("qr", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	format := fs.String("format", "png", "output format: png, svg or term")
+	size := fs.Int("size", 256, "width and height in pixels for png and svg")
+	out := fs.String("o", "", "write to `file` instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cards, err := e.readCards(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(cards) != 1 {
+		return fmt.Errorf("expected exactly one card, got %d", len(cards))
+	}
+	card := cards[0]
+
+	var w io.Writer = e.stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		// a failing close can lose the end of the image
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+
+	switch *format {
+	case "png":
+		code, err := card.QR(*size, *size)
+		if err != nil {
+			return err
+		}
+		return png.Encode(w, code)
+	case "svg", "term":
+		if err := card.Validate(); err != nil {
+			return err
+		}
+		s, err := card.Generate()
+		if err != nil {
+			return err
+		}
+		code, err := qr.Encode(s, qr.M, qr.Auto)
+		if err != nil {
+			return err
+		}
+		if *format == "svg" {
+			return writeSVG(w, code, *size)
+		}
+		return writeTerminal(w, code)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// writeSVG writes the unscaled code as an SVG image with one rectangle per dark module
+func writeSVG(w io.Writer, code barcode.Barcode, size int) error {
+	var (
+		b      bytes.Buffer
+		bounds = code.Bounds()
+		// quiet zone of 4 modules around the code
+		dim = bounds.Dx() + 8
+	)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, dim, dim)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="`)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if dark(code, x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\"/></svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeTerminal draws the code with unicode half blocks, two modules per character
+func writeTerminal(w io.Writer, code barcode.Barcode) error {
+	var (
+		b      bytes.Buffer
+		bounds = code.Bounds()
+	)
+	// the quiet zone is drawn too, terminals are usually dark so modules are inverted
+	for y := bounds.Min.Y - 2; y < bounds.Max.Y+2; y += 2 {
+		for x := bounds.Min.X - 2; x < bounds.Max.X+2; x++ {
+			top, bottom := dark(code, x, y), dark(code, x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString(" ")
+			case top:
+				b.WriteString("▄")
+			case bottom:
+				b.WriteString("▀")
+			default:
+				b.WriteString("█")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func dark(code barcode.Barcode, x, y int) bool {
+	bounds := code.Bounds()
+	if x < bounds.Min.X || y < bounds.Min.Y || x >= bounds.Max.X || y >= bounds.Max.Y {
+		return false
+	}
+	r, _, _, _ := code.At(x, y).RGBA()
+	return r == 0
+}
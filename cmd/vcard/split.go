@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func runSplit(e *env, args []string) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	dir := fs.String("dir", ".", "output `directory`")
+	force := fs.Bool("f", false, "overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cards, err := e.readCards(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool, len(cards))
+	for n, card := range cards {
+		name := fileName(card, n+1)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", fileName(card, n+1), i)
+		}
+		used[name] = true
+
+		var b bytes.Buffer
+		if err := writeCard(&b, card); err != nil {
+			return fmt.Errorf("card %d: %v", n+1, err)
+		}
+		path := filepath.Join(*dir, name+".vcf")
+		if err := writeFile(path, b.Bytes(), *force); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, path)
+	}
+	return nil
+}
+
+// writeFile writes a new file, existing files are only overwritten when force is set
+func writeFile(path string, data []byte, force bool) error {
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, mode, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists, use -f to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fileName derives a safe file name from the formatted name of a card, falling back to its position
+func fileName(card *vcard.VCard, n int) string {
+	for _, f := range card.Fields {
+		fn, ok := f.(vcard.FN)
+		if !ok {
+			continue
+		}
+		name := strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_', r == '.':
+				return r
+			case unicode.IsSpace(r):
+				return '_'
+			}
+			return -1
+		}, fn.FormattedName)
+		if name = strings.Trim(name, "._"); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("card-%d", n)
+}
+
+func runMerge(e *env, args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cards, err := e.readCards(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		if err := writeCard(e.stdout, card); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func runValidate(e *env, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	verbose := fs.Bool("v", false, "also report valid cards")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in, err := e.inputs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, i := range in {
+		d := vcard.NewDecoder(i.r)
+		for n := 1; ; n++ {
+			card, err := d.Decode()
+			if err != nil {
+				if err != io.EOF {
+					fmt.Fprintf(e.stdout, "%s: card %d: %v\n", i.name, n, err)
+					failed = true
+				}
+				break
+			}
+
+			if err := card.Validate(); err != nil {
+				fmt.Fprintf(e.stdout, "%s: card %d: %v\n", i.name, n, err)
+				failed = true
+				continue
+			}
+			if *verbose {
+				fmt.Fprintf(e.stdout, "%s: card %d: ok\n", i.name, n)
+			}
+		}
+		i.r.Close()
+	}
+
+	if failed {
+		return errSilent
+	}
+	return nil
+}
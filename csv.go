@@ -0,0 +1,689 @@
+package vcard
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Components of a CSVColumn, the value of single valued fields and the name of an ORG have no component
+const (
+	// CSVType marks a column holding the label of a field, like Home or Mobile
+	CSVType = "Type"
+
+	CSVFamilyName        = "FamilyName"
+	CSVGivenName         = "GivenName"
+	CSVAdditionalNames   = "AdditionalNames"
+	CSVHonorificPrefixes = "HonorificPrefixes"
+	CSVHonorificSuffixes = "HonorificSuffixes"
+
+	// CSVUnit is an organizational unit of an ORG
+	CSVUnit = "Unit"
+
+	CSVPostOfficeBox   = "PostOfficeBox"
+	CSVExtendedAddress = "ExtendedAddress"
+	CSVStreetAddress   = "StreetAddress"
+	CSVLocality        = "Locality"
+	CSVRegion          = "Region"
+	CSVPostalCode      = "PostalCode"
+	CSVCountryName     = "CountryName"
+)
+
+// csvFields are the properties a CSVColumn can belong to
+var csvFields = map[string]bool{
+	"N": true, "FN": true, "ORG": true, "TITLE": true, "ROLE": true,
+	"EMAIL": true, "TEL": true, "ADR": true, "BDAY": true, "NOTE": true,
+}
+
+// CSVColumn binds a column of a CSV file to a component of a field
+type CSVColumn struct {
+	Header string
+	// Field is the property the column belongs to: N, FN, ORG, TITLE, ROLE, EMAIL, TEL, ADR, BDAY or NOTE
+	Field     string
+	Component string
+	// Index distinguishes repeated fields, the columns with the same Field and Index make up one field
+	Index int
+	// Types are implied by the column, like home for a Home Phone column
+	Types []string
+}
+
+// CSVLabel maps a label used in CSVType columns onto types
+type CSVLabel struct {
+	Label string
+	Types []string
+}
+
+// CSVProfile describes the columns of a CSV file of contacts
+type CSVProfile struct {
+	Columns []CSVColumn
+	Labels  []CSVLabel
+	// Separator separates multiple emails or phone numbers in a single cell
+	Separator string
+	// PrefMarker prefixes the label of a preferred email, phone number or address
+	PrefMarker string
+	// DateLayout is the layout of birthdays, ISO 8601 dates are accepted as well when reading
+	DateLayout string
+}
+
+// CSVError reports a row of a CSV file which couldn't be read, rows are numbered like in a
+// spreadsheet with the header as row 1
+type CSVError struct {
+	Row int
+	Err error
+}
+
+// Error implements the error interface
+func (err *CSVError) Error() string {
+	return fmt.Sprintf("row %d: %v", err.Row, err.Err)
+}
+
+// CSVErrors collects the errors of all rows which couldn't be read
+type CSVErrors []*CSVError
+
+// Error implements the error interface
+func (errs CSVErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+func googleCSVColumns() []CSVColumn {
+	cols := []CSVColumn{
+		{Header: "Name", Field: "FN"},
+		{Header: "Given Name", Field: "N", Component: CSVGivenName},
+		{Header: "Additional Name", Field: "N", Component: CSVAdditionalNames},
+		{Header: "Family Name", Field: "N", Component: CSVFamilyName},
+		{Header: "Name Prefix", Field: "N", Component: CSVHonorificPrefixes},
+		{Header: "Name Suffix", Field: "N", Component: CSVHonorificSuffixes},
+		{Header: "Birthday", Field: "BDAY"},
+		{Header: "Notes", Field: "NOTE"},
+	}
+	for i := 1; i <= 3; i++ {
+		cols = append(cols,
+			CSVColumn{Header: fmt.Sprintf("E-mail %d - Type", i), Field: "EMAIL", Component: CSVType, Index: i},
+			CSVColumn{Header: fmt.Sprintf("E-mail %d - Value", i), Field: "EMAIL", Index: i},
+		)
+	}
+	for i := 1; i <= 4; i++ {
+		cols = append(cols,
+			CSVColumn{Header: fmt.Sprintf("Phone %d - Type", i), Field: "TEL", Component: CSVType, Index: i},
+			CSVColumn{Header: fmt.Sprintf("Phone %d - Value", i), Field: "TEL", Index: i},
+		)
+	}
+	for i := 1; i <= 2; i++ {
+		for _, c := range []struct{ name, component string }{
+			{"Type", CSVType},
+			{"Street", CSVStreetAddress},
+			{"City", CSVLocality},
+			{"PO Box", CSVPostOfficeBox},
+			{"Region", CSVRegion},
+			{"Postal Code", CSVPostalCode},
+			{"Country", CSVCountryName},
+			{"Extended Address", CSVExtendedAddress},
+		} {
+			cols = append(cols, CSVColumn{Header: fmt.Sprintf("Address %d - %s", i, c.name), Field: "ADR", Component: c.component, Index: i})
+		}
+	}
+	return append(cols,
+		CSVColumn{Header: "Organization 1 - Name", Field: "ORG", Index: 1},
+		CSVColumn{Header: "Organization 1 - Title", Field: "TITLE", Index: 1},
+		CSVColumn{Header: "Organization 1 - Department", Field: "ORG", Component: CSVUnit, Index: 1},
+	)
+}
+
+func outlookCSVColumns() []CSVColumn {
+	cols := []CSVColumn{
+		{Header: "Title", Field: "N", Component: CSVHonorificPrefixes},
+		{Header: "First Name", Field: "N", Component: CSVGivenName},
+		{Header: "Middle Name", Field: "N", Component: CSVAdditionalNames},
+		{Header: "Last Name", Field: "N", Component: CSVFamilyName},
+		{Header: "Suffix", Field: "N", Component: CSVHonorificSuffixes},
+		{Header: "Company", Field: "ORG"},
+		{Header: "Department", Field: "ORG", Component: CSVUnit},
+		{Header: "Job Title", Field: "TITLE"},
+	}
+	for i, adr := range []struct {
+		prefix string
+		types  []string
+	}{
+		{"Business", []string{AdrWork}},
+		{"Home", []string{AdrHome}},
+		{"Other", nil},
+	} {
+		for _, c := range []struct{ name, component string }{
+			{"Street", CSVStreetAddress},
+			{"Street 2", CSVStreetAddress},
+			{"Street 3", CSVStreetAddress},
+			{"City", CSVLocality},
+			{"State", CSVRegion},
+			{"Postal Code", CSVPostalCode},
+			{"Country/Region", CSVCountryName},
+		} {
+			cols = append(cols, CSVColumn{Header: adr.prefix + " " + c.name, Field: "ADR", Component: c.component, Index: i + 1, Types: adr.types})
+		}
+	}
+	for i, tel := range []struct {
+		header string
+		types  []string
+	}{
+		{"Business Fax", []string{TelWork, TelFax}},
+		{"Business Phone", []string{TelWork}},
+		{"Business Phone 2", []string{TelWork}},
+		{"Home Fax", []string{TelHome, TelFax}},
+		{"Home Phone", []string{TelHome}},
+		{"Home Phone 2", []string{TelHome}},
+		{"Mobile Phone", []string{TelCell}},
+		{"Other Phone", nil},
+		{"Pager", []string{TelPager}},
+	} {
+		cols = append(cols, CSVColumn{Header: tel.header, Field: "TEL", Index: i + 1, Types: tel.types})
+	}
+	return append(cols,
+		CSVColumn{Header: "Birthday", Field: "BDAY"},
+		CSVColumn{Header: "E-mail Address", Field: "EMAIL", Index: 1},
+		CSVColumn{Header: "E-mail 2 Address", Field: "EMAIL", Index: 2},
+		CSVColumn{Header: "E-mail 3 Address", Field: "EMAIL", Index: 3},
+		CSVColumn{Header: "Notes", Field: "NOTE"},
+	)
+}
+
+var (
+	// GoogleCSV is the profile of the Google Contacts CSV export
+	GoogleCSV = CSVProfile{
+		Columns: googleCSVColumns(),
+		Labels: []CSVLabel{
+			{"Home", []string{TelHome}},
+			{"Work", []string{TelWork}},
+			{"Mobile", []string{TelCell}},
+			{"Home Fax", []string{TelHome, TelFax}},
+			{"Work Fax", []string{TelWork, TelFax}},
+			{"Pager", []string{TelPager}},
+			{"Other", nil},
+		},
+		Separator:  " ::: ",
+		PrefMarker: "* ",
+		DateLayout: "2006-01-02",
+	}
+
+	// OutlookCSV is the profile of the Outlook CSV export
+	OutlookCSV = CSVProfile{
+		Columns:    outlookCSVColumns(),
+		DateLayout: "1/2/2006",
+	}
+)
+
+// csvGroup holds the columns making up a single field
+type csvGroup struct {
+	field string
+	cols  []int
+	types []string
+}
+
+// groups returns the fields of the profile in the order of their first column
+func (p CSVProfile) groups() ([]csvGroup, error) {
+	var (
+		groups []csvGroup
+		index  = make(map[string]int)
+	)
+	for i, c := range p.Columns {
+		if !csvFields[c.Field] {
+			return nil, fmt.Errorf("column %q: unsupported field %q", c.Header, c.Field)
+		}
+		key := fmt.Sprintf("%s/%d", c.Field, c.Index)
+		g, ok := index[key]
+		if !ok {
+			g = len(groups)
+			index[key] = g
+			groups = append(groups, csvGroup{field: c.Field})
+		}
+		groups[g].cols = append(groups[g].cols, i)
+		groups[g].types = appendTypes(groups[g].types, c.Types...)
+	}
+	return groups, nil
+}
+
+// appendTypes appends the types which aren't in t yet
+func appendTypes(t []string, types ...string) []string {
+	for _, tp := range types {
+		if !hasType(t, tp) {
+			t = append(t, tp)
+		}
+	}
+	return t
+}
+
+func hasType(t []string, tp string) bool {
+	for _, v := range t {
+		if strings.EqualFold(v, tp) {
+			return true
+		}
+	}
+	return false
+}
+
+// subset reports whether all types of sub are in t
+func subset(sub, t []string) bool {
+	for _, tp := range sub {
+		if !hasType(t, tp) {
+			return false
+		}
+	}
+	return true
+}
+
+// types returns the types of a label
+func (p CSVProfile) types(label string) []string {
+	var t []string
+	if p.PrefMarker != "" && strings.HasPrefix(label, p.PrefMarker) {
+		t = append(t, TelPref)
+		label = strings.TrimSpace(label[len(p.PrefMarker):])
+	}
+	for _, l := range p.Labels {
+		if strings.EqualFold(l.Label, label) {
+			return appendTypes(t, l.Types...)
+		}
+	}
+	if label != "" {
+		t = appendTypes(t, strings.ToLower(label))
+	}
+	return t
+}
+
+// label returns the most specific label of the types
+func (p CSVProfile) label(t []string) string {
+	var plain []string
+	for _, tp := range t {
+		if !strings.EqualFold(tp, TelPref) {
+			plain = append(plain, strings.ToLower(tp))
+		}
+	}
+
+	label, best := strings.Join(plain, ","), -1
+	for _, l := range p.Labels {
+		if len(l.Types) > best && subset(l.Types, plain) {
+			label, best = l.Label, len(l.Types)
+		}
+	}
+	if p.PrefMarker != "" && len(plain) < len(t) {
+		label = p.PrefMarker + label
+	}
+	return label
+}
+
+func (p CSVProfile) parseDate(s string) (Bday, error) {
+	if p.DateLayout != "" {
+		if t, err := time.Parse(p.DateLayout, s); err == nil {
+			return Bday{Timestamp: t}, nil
+		}
+	}
+	t, format, ok := parseTime(s, dateFormat)
+	if !ok {
+		return Bday{}, fmt.Errorf("invalid birthday %q", s)
+	}
+	return Bday{Timestamp: t, TimeFormat: format}, nil
+}
+
+// emptyDate reports whether a date is a placeholder like Outlook's 0/0/00
+func emptyDate(s string) bool {
+	return strings.Trim(s, "0/-. ") == ""
+}
+
+// card turns a row into a version 4.0 VCard, rows without values return nil
+func (p CSVProfile) card(groups []csvGroup, cols []int, row []string) (*VCard, error) {
+	var (
+		fields []FieldFormatter
+		fn     string
+		n      *N
+		org    string
+		email  string
+	)
+	for _, g := range groups {
+		values := make(map[string][]string)
+		types := append([]string(nil), g.types...)
+		for _, c := range g.cols {
+			i := cols[c]
+			if i < 0 || i >= len(row) {
+				continue
+			}
+			v := strings.TrimSpace(row[i])
+			if v == "" {
+				continue
+			}
+			comp := p.Columns[c].Component
+			if comp == CSVType {
+				types = appendTypes(types, p.types(v)...)
+				continue
+			}
+			values[comp] = append(values[comp], v)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		get := func(comp string) string {
+			return strings.Join(values[comp], ", ")
+		}
+
+		switch g.field {
+		case "N":
+			f := N{
+				FamilyName:        get(CSVFamilyName),
+				GivenName:         get(CSVGivenName),
+				AdditionalNames:   get(CSVAdditionalNames),
+				HonorificPrefixes: get(CSVHonorificPrefixes),
+				HonorificSuffixes: get(CSVHonorificSuffixes),
+			}
+			if n == nil {
+				n = &f
+			}
+			fields = append(fields, f)
+		case "FN":
+			if fn == "" {
+				fn = get("")
+			}
+			fields = append(fields, FN{get("")})
+		case "ORG":
+			if org == "" {
+				org = get("")
+			}
+			fields = append(fields, Org{Name: get(""), Units: values[CSVUnit]})
+		case "TITLE":
+			fields = append(fields, Title{get("")})
+		case "ROLE":
+			fields = append(fields, Role{get("")})
+		case "EMAIL", "TEL":
+			for _, cell := range values[""] {
+				vals := []string{cell}
+				if p.Separator != "" {
+					vals = strings.Split(cell, strings.TrimSpace(p.Separator))
+				}
+				for _, v := range vals {
+					if v = strings.TrimSpace(v); v == "" {
+						continue
+					}
+					if g.field == "TEL" {
+						fields = append(fields, Tel{Types: types, Number: v})
+						continue
+					}
+					if email == "" {
+						email = v
+					}
+					fields = append(fields, Email{Types: types, Email: v})
+				}
+			}
+		case "ADR":
+			fields = append(fields, Adr{
+				Types:           types,
+				PostOfficeBox:   get(CSVPostOfficeBox),
+				ExtendedAddress: get(CSVExtendedAddress),
+				StreetAddress:   get(CSVStreetAddress),
+				Locality:        get(CSVLocality),
+				Region:          get(CSVRegion),
+				PostalCode:      get(CSVPostalCode),
+				CountryName:     get(CSVCountryName),
+			})
+		case "BDAY":
+			if emptyDate(get("")) {
+				continue
+			}
+			f, err := p.parseDate(values[""][0])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		case "NOTE":
+			fields = append(fields, Property{Name: "NOTE", Value: escapeText(strings.Join(values[""], "\n"))})
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	if fn == "" {
+		if n != nil {
+			fn = joinNonEmpty(" ", n.HonorificPrefixes, n.GivenName, n.AdditionalNames, n.FamilyName, n.HonorificSuffixes)
+		}
+		for _, s := range []string{org, email} {
+			if fn == "" {
+				fn = s
+			}
+		}
+		if fn == "" {
+			return nil, errors.New("no name, organization or email")
+		}
+		fields = append([]FieldFormatter{FN{fn}}, fields...)
+	}
+	return New("4.0", fields...)
+}
+
+func joinNonEmpty(sep string, s ...string) string {
+	var l []string
+	for _, v := range s {
+		if v != "" {
+			l = append(l, v)
+		}
+	}
+	return strings.Join(l, sep)
+}
+
+// ReadCSV reads the rows of a CSV file with a header row as version 4.0 VCards. Columns are matched
+// to the profile by their header, columns which aren't in the profile are ignored. Rows which
+// can't be read are skipped and reported in a CSVErrors, along with the cards of the other rows.
+func ReadCSV(r io.Reader, p CSVProfile) ([]*VCard, error) {
+	groups, err := p.groups()
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// cols holds the index in the file of every column of the profile
+	cols := make([]int, len(p.Columns))
+	for i, c := range p.Columns {
+		cols[i] = -1
+		for j, h := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), c.Header) {
+				cols[i] = j
+				break
+			}
+		}
+	}
+
+	var (
+		cards []*VCard
+		errs  CSVErrors
+	)
+	for row := 2; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return cards, err
+			}
+			errs = append(errs, &CSVError{Row: row, Err: err})
+			continue
+		}
+
+		card, err := p.card(groups, cols, rec)
+		if err != nil {
+			errs = append(errs, &CSVError{Row: row, Err: err})
+			continue
+		}
+		if card != nil {
+			cards = append(cards, card)
+		}
+	}
+	if len(errs) > 0 {
+		return cards, errs
+	}
+	return cards, nil
+}
+
+// csvRow fills the columns of a row for a card
+type csvRow struct {
+	p      CSVProfile
+	groups []csvGroup
+	used   []bool
+	row    []string
+}
+
+// group returns a free group for the field with the most specific types the value has
+func (r *csvRow) group(field string, types []string) int {
+	g, best := -1, -1
+	for i, grp := range r.groups {
+		if grp.field == field && !r.used[i] && len(grp.types) > best && subset(grp.types, types) {
+			g, best = i, len(grp.types)
+		}
+	}
+	if g >= 0 {
+		r.used[g] = true
+	}
+	return g
+}
+
+// set writes a value into the first column of the group with the component
+func (r *csvRow) set(g int, comp, v string) {
+	for _, c := range r.groups[g].cols {
+		if r.p.Columns[c].Component == comp {
+			if r.row[c] == "" {
+				r.row[c] = v
+			}
+			return
+		}
+	}
+}
+
+// add writes an email or phone number, appending it to a group with the same label when all
+// groups are used and the profile has a separator
+func (r *csvRow) add(field, v string, types []string) {
+	if g := r.group(field, types); g >= 0 {
+		r.set(g, "", v)
+		r.set(g, CSVType, r.p.label(types))
+		return
+	}
+	if r.p.Separator == "" {
+		return
+	}
+	label := r.p.label(types)
+	for _, grp := range r.groups {
+		if grp.field != field || !r.hasLabel(grp, label) {
+			continue
+		}
+		for _, c := range grp.cols {
+			if r.p.Columns[c].Component == "" && r.row[c] != "" {
+				r.row[c] += r.p.Separator + v
+				return
+			}
+		}
+	}
+}
+
+// hasLabel reports whether the type column of the group holds the label
+func (r *csvRow) hasLabel(g csvGroup, label string) bool {
+	for _, c := range g.cols {
+		if r.p.Columns[c].Component == CSVType && r.row[c] == label {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteCSV writes the cards as CSV with a header row, fields the profile has no column for are left out
+func WriteCSV(w io.Writer, p CSVProfile, cards ...*VCard) error {
+	groups, err := p.groups()
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		header[i] = c.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	dateLayout := p.DateLayout
+	if dateLayout == "" {
+		dateLayout = "2006-01-02"
+	}
+	for _, card := range cards {
+		r := csvRow{p: p, groups: groups, used: make([]bool, len(groups)), row: make([]string, len(p.Columns))}
+		for _, f := range card.Fields {
+			switch f := f.(type) {
+			case N:
+				if g := r.group("N", nil); g >= 0 {
+					r.set(g, CSVFamilyName, f.FamilyName)
+					r.set(g, CSVGivenName, f.GivenName)
+					r.set(g, CSVAdditionalNames, f.AdditionalNames)
+					r.set(g, CSVHonorificPrefixes, f.HonorificPrefixes)
+					r.set(g, CSVHonorificSuffixes, f.HonorificSuffixes)
+				}
+			case FN:
+				if g := r.group("FN", nil); g >= 0 {
+					r.set(g, "", f.FormattedName)
+				}
+			case Org:
+				if g := r.group("ORG", nil); g >= 0 {
+					r.set(g, "", f.Name)
+					r.set(g, CSVUnit, strings.Join(f.Units, ", "))
+				}
+			case Title:
+				if g := r.group("TITLE", nil); g >= 0 {
+					r.set(g, "", f.Title)
+				}
+			case Role:
+				if g := r.group("ROLE", nil); g >= 0 {
+					r.set(g, "", f.Role)
+				}
+			case Email:
+				r.add("EMAIL", f.Email, f.Types)
+			case Tel:
+				r.add("TEL", f.Number, f.Types)
+			case Adr:
+				if g := r.group("ADR", f.Types); g >= 0 {
+					r.set(g, CSVType, p.label(f.Types))
+					r.set(g, CSVPostOfficeBox, f.PostOfficeBox)
+					r.set(g, CSVExtendedAddress, f.ExtendedAddress)
+					r.set(g, CSVStreetAddress, f.StreetAddress)
+					r.set(g, CSVLocality, f.Locality)
+					r.set(g, CSVRegion, f.Region)
+					r.set(g, CSVPostalCode, f.PostalCode)
+					r.set(g, CSVCountryName, f.CountryName)
+				}
+			case Bday:
+				if g := r.group("BDAY", nil); g >= 0 {
+					r.set(g, "", f.Timestamp.Format(dateLayout))
+				}
+			case Property:
+				if f.Name != "NOTE" || f.Group != "" {
+					continue
+				}
+				if g := r.group("NOTE", nil); g >= 0 {
+					r.set(g, "", f.Text())
+				}
+			}
+		}
+		if err := cw.Write(r.row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
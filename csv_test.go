@@ -0,0 +1,152 @@
+package vcard_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestReadCSVGoogle(t *testing.T) {
+	in := "Name,Given Name,Family Name,Birthday,E-mail 1 - Type,E-mail 1 - Value,Phone 1 - Type,Phone 1 - Value,Organization 1 - Name,Group Membership\n" +
+		"Forrest Gump,Forrest,Gump,1944-06-06,* Work,forrest@example.com ::: gump@example.com,Mobile,+1-111-555-1213,Bubba Gump Shrimp Co.,* myContacts\n" +
+		"Broken,,,yesterday,,,,,,\n" +
+		",,,,,,Home,+1-111-555-1212,,\n" +
+		",,,,,,,,,\n" +
+		",,,,Other,jenny@example.org,,,,\n"
+
+	cards, err := vcard.ReadCSV(strings.NewReader(in), vcard.GoogleCSV)
+	errs, ok := err.(vcard.CSVErrors)
+	if !ok || len(errs) != 2 || errs[0].Row != 3 || errs[1].Row != 4 {
+		t.Fatalf("expected errors for rows 3 and 4, but got: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, but got %d", len(cards))
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+		vcard.Email{Types: []string{vcard.EmailPref, vcard.TelWork}, Email: "forrest@example.com"},
+		vcard.Email{Types: []string{vcard.EmailPref, vcard.TelWork}, Email: "gump@example.com"},
+		vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1-111-555-1213"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[0].Fields)
+	}
+
+	// the email is used as name when there is nothing else
+	expected = []vcard.FieldFormatter{vcard.FN{"jenny@example.org"}, vcard.Email{Email: "jenny@example.org"}}
+	if !reflect.DeepEqual(cards[1].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[1].Fields)
+	}
+}
+
+func TestReadCSVOutlook(t *testing.T) {
+	in := "\ufeffFirst Name,Last Name,Company,Business Street,Business Street 2,Business City,Business Phone,Home Fax,Mobile Phone,E-mail Address,E-mail 2 Address,Birthday,Notes\n" +
+		`Jenny,Curran,,1 Main St,Apt 2,Greenbow,+1 555 0100,+1 555 0101,,jenny@example.org,jenny@example.com,0/0/00,"Likes
+running, shrimp"` + "\n" +
+		",,Bubba Gump Shrimp Co.,,,,,,+1 555 0102,,,7/4/1976,\n"
+
+	cards, err := vcard.ReadCSV(strings.NewReader(in), vcard.OutlookCSV)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, but got %d", len(cards))
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Jenny Curran"},
+		vcard.N{FamilyName: "Curran", GivenName: "Jenny"},
+		vcard.Adr{Types: []string{vcard.AdrWork}, StreetAddress: "1 Main St, Apt 2", Locality: "Greenbow"},
+		vcard.Tel{Types: []string{vcard.TelWork}, Number: "+1 555 0100"},
+		vcard.Tel{Types: []string{vcard.TelHome, vcard.TelFax}, Number: "+1 555 0101"},
+		vcard.Email{Email: "jenny@example.org"},
+		vcard.Email{Email: "jenny@example.com"},
+		vcard.Property{Name: "NOTE", Value: `Likes\nrunning\, shrimp`},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[0].Fields)
+	}
+
+	expected = []vcard.FieldFormatter{
+		vcard.FN{"Bubba Gump Shrimp Co."},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1 555 0102"},
+		vcard.Bday{Timestamp: time.Date(1976, 7, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(cards[1].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[1].Fields)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.FN{"Forrest Gump"},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelVoice}, Number: "+1-111-555-1212"},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelFax}, Number: "+1-111-555-1214"},
+		vcard.Email{Types: []string{vcard.TelHome, vcard.EmailPref}, Email: "forrest@example.com"},
+		vcard.Email{Email: "gump1@example.com"},
+		vcard.Email{Email: "gump2@example.com"},
+		vcard.Email{Email: "gump3@example.com"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	profile := vcard.CSVProfile{
+		Columns: []vcard.CSVColumn{
+			{Header: "Name", Field: "FN"},
+			{Header: "Phone", Field: "TEL"},
+			{Header: "Fax", Field: "TEL", Index: 1, Types: []string{vcard.TelFax}},
+			{Header: "E-mail 1 - Type", Field: "EMAIL", Component: vcard.CSVType, Index: 1},
+			{Header: "E-mail 1 - Value", Field: "EMAIL", Index: 1},
+			{Header: "E-mail 2 - Type", Field: "EMAIL", Component: vcard.CSVType, Index: 2},
+			{Header: "E-mail 2 - Value", Field: "EMAIL", Index: 2},
+			{Header: "Birthday", Field: "BDAY"},
+		},
+		Labels:     vcard.GoogleCSV.Labels,
+		Separator:  vcard.GoogleCSV.Separator,
+		PrefMarker: vcard.GoogleCSV.PrefMarker,
+		DateLayout: "02.01.2006",
+	}
+
+	var b bytes.Buffer
+	if err := vcard.WriteCSV(&b, profile, card); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := "Name,Phone,Fax,E-mail 1 - Type,E-mail 1 - Value,E-mail 2 - Type,E-mail 2 - Value,Birthday\n" +
+		"Forrest Gump,+1-111-555-1212,+1-111-555-1214,* Home,forrest@example.com,Other,gump1@example.com ::: gump2@example.com ::: gump3@example.com,06.06.1944\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, b.String())
+	}
+
+	cards, err := vcard.ReadCSV(&b, profile)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got := cards[0].Fields[len(cards[0].Fields)-1]; got != card.Fields[len(card.Fields)-1] {
+		t.Fatalf("expected %v, but got %v", card.Fields[len(card.Fields)-1], got)
+	}
+	if len(cards[0].Fields) != 8 {
+		t.Fatalf("expected 8 fields, but got %v", cards[0].Fields)
+	}
+}
+
+func TestCSVProfileErrors(t *testing.T) {
+	profile := vcard.CSVProfile{Columns: []vcard.CSVColumn{{Header: "Photo", Field: "PHOTO"}}}
+	if _, err := vcard.ReadCSV(strings.NewReader("Photo\n"), profile); err == nil {
+		t.Fatalf("expected an unsupported field to fail")
+	}
+	if err := vcard.WriteCSV(&bytes.Buffer{}, profile); err == nil {
+		t.Fatalf("expected an unsupported field to fail")
+	}
+}
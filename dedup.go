@@ -0,0 +1,476 @@
+package vcard
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultThreshold is the score from which Dedup considers two cards to be the same
+const DefaultThreshold = 0.75
+
+// weights of the evidence that two cards describe the same person, combined by Score
+const (
+	emailWeight = 0.95
+	phoneWeight = 0.9
+	nameWeight  = 0.7
+	orgWeight   = 0.2
+)
+
+// Match is a pair of cards which likely describe the same person
+type Match struct {
+	A, B  int
+	Score float64
+	// Reasons lists the evidence, such as "email forrest@example.com"
+	Reasons []string
+}
+
+// emailKeys returns the normalized email addresses of a card
+func emailKeys(v *VCard) []string {
+	var k []string
+	for _, e := range All[Email](v) {
+		if s := strings.ToLower(strings.TrimSpace(e.Email)); s != "" {
+			k = append(k, s)
+		}
+	}
+	return k
+}
+
+// phoneKeys returns the keys of the phone numbers of a card with at least seven digits, see phoneKey
+func phoneKeys(v *VCard) []string {
+	var k []string
+	for _, t := range All[Tel](v) {
+		if s := phoneKey(t.Number); len(phoneDigits(s)) >= 7 {
+			k = append(k, s)
+		}
+	}
+	return k
+}
+
+// phoneDigits returns the digits of a phone key without extension
+func phoneDigits(key string) string {
+	number, _, _ := strings.Cut(key, ";ext=")
+	return strings.TrimPrefix(number, "+")
+}
+
+// nameKey returns the words of the name of a card sorted, so the order of the components doesn't matter
+func nameKey(v *VCard) string {
+	var words []string
+	if n, ok := First[N](v); ok {
+		for _, s := range []string{n.GivenName, n.AdditionalNames, n.FamilyName} {
+			words = append(words, nameTokens(s)...)
+		}
+	}
+	if fn, ok := First[FN](v); ok && len(words) == 0 {
+		words = nameTokens(fn.FormattedName)
+	}
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// similarity returns how similar two strings are, from 0 to 1
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	l := len([]rune(a))
+	if lb := len([]rune(b)); lb > l {
+		l = lb
+	}
+	return 1 - float64(distance(a, b))/float64(l)
+}
+
+// orgKey returns the normalized organization name of a card
+func orgKey(v *VCard) string {
+	if o, ok := First[Org](v); ok {
+		return strings.Join(nameTokens(o.Name), " ")
+	}
+	return ""
+}
+
+// Score returns how likely two cards describe the same person, from 0 to 1, and the evidence for it.
+// Equal email addresses and phone numbers, similar names, regardless of the order of the N components,
+// and equal organizations all add to the score.
+func Score(a, b *VCard) (float64, []string) {
+	var (
+		miss    = 1.0
+		reasons []string
+	)
+	add := func(weight float64, reason string) {
+		miss *= 1 - weight
+		reasons = append(reasons, reason)
+	}
+
+	emails := make(map[string]bool)
+	for _, e := range emailKeys(a) {
+		emails[e] = true
+	}
+	for _, e := range emailKeys(b) {
+		if emails[e] {
+			add(emailWeight, "email "+e)
+			break
+		}
+	}
+
+phones:
+	for _, pa := range phoneKeys(a) {
+		for _, pb := range phoneKeys(b) {
+			if phoneMatch(pa, pb) {
+				add(phoneWeight, "phone "+pb)
+				break phones
+			}
+		}
+	}
+
+	na, nb := nameKey(a), nameKey(b)
+	if s := similarity(na, nb); s >= 0.8 {
+		add(nameWeight*s, fmt.Sprintf("name %q ~ %q", na, nb))
+	}
+	if oa := orgKey(a); oa != "" && oa == orgKey(b) {
+		add(orgWeight, "organization "+oa)
+	}
+	return 1 - miss, reasons
+}
+
+// FindDuplicates returns the pairs of cards scoring at least the threshold, sorted by descending score.
+// Only cards sharing an email address, phone number or word of their name are compared.
+func FindDuplicates(cards []*VCard, threshold float64) []Match {
+	blocks := make(map[string][]int)
+	for i, c := range cards {
+		keys := make(map[string]bool)
+		for _, e := range emailKeys(c) {
+			keys["e:"+e] = true
+		}
+		for _, p := range phoneKeys(c) {
+			// the last seven digits are the same with and without country code or trunk prefix
+			d := phoneDigits(p)
+			keys["p:"+d[len(d)-7:]] = true
+		}
+		for _, w := range strings.Fields(nameKey(c)) {
+			keys["n:"+w] = true
+		}
+		for k := range keys {
+			blocks[k] = append(blocks[k], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+	seen := make(map[pair]bool)
+	var matches []Match
+	for _, block := range blocks {
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				p := pair{block[x], block[y]}
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				if s, reasons := Score(cards[p.a], cards[p.b]); s >= threshold {
+					matches = append(matches, Match{A: p.a, B: p.b, Score: s, Reasons: reasons})
+				}
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].A != matches[j].A {
+			return matches[i].A < matches[j].A
+		}
+		return matches[i].B < matches[j].B
+	})
+	return matches
+}
+
+// MergePolicy decides which card wins when merged cards have different values for a field
+// which can occur only once, such as N or BDAY. Empty values never win from non-empty ones.
+type MergePolicy int
+
+const (
+	// NewestWins prefers the card with the newest REV, cards without REV come last
+	NewestWins MergePolicy = iota
+	// FirstWins prefers the cards in the order they are given
+	FirstWins
+)
+
+// MergeAction describes what happened to a field of a merged card
+type MergeAction string
+
+const (
+	// MergeKept indicates a field taken into the merged card
+	MergeKept MergeAction = "kept"
+	// MergeCombined indicates a field equal to a kept field, its types were added to the kept field
+	MergeCombined MergeAction = "combined"
+	// MergeDropped indicates a field which lost from a field of another card
+	MergeDropped MergeAction = "dropped"
+	// MergeUnsupported indicates a field which isn't supported by the version of the merged card
+	MergeUnsupported MergeAction = "unsupported"
+)
+
+// MergeEntry records what happened to a single field
+type MergeEntry struct {
+	// Source is the index of the card the field came from
+	Source int
+	Field  string
+	Action MergeAction
+}
+
+// MergeAudit records how a merged card came about
+type MergeAudit struct {
+	// Sources are the indexes of the merged cards
+	Sources []int
+	Entries []MergeEntry
+}
+
+// singletons are the field types which can occur only once in a merged card
+var singletons = map[reflect.Type]bool{
+	reflect.TypeOf(N{}):           true,
+	reflect.TypeOf(FN{}):          true,
+	reflect.TypeOf(Org{}):         true,
+	reflect.TypeOf(Title{}):       true,
+	reflect.TypeOf(Role{}):        true,
+	reflect.TypeOf(Photo{}):       true,
+	reflect.TypeOf(Rev{}):         true,
+	reflect.TypeOf(Agent{}):       true,
+	reflect.TypeOf(Anniversary{}): true,
+	reflect.TypeOf(Bday{}):        true,
+	reflect.TypeOf(FbURL{}):       true,
+	reflect.TypeOf(Gender{}):      true,
+	reflect.TypeOf(Geo{}):         true,
+	reflect.TypeOf(Kind{}):        true,
+}
+
+// singletonProperties are the generic properties which can occur only once in a merged card
+var singletonProperties = map[string]bool{
+	"UID":    true,
+	"PRODID": true,
+}
+
+// singleton reports whether a field can occur only once in a merged card
+func singleton(f FieldFormatter) bool {
+	if p, ok := f.(Property); ok {
+		return p.Group == "" && singletonProperties[strings.ToUpper(p.Name)]
+	}
+	return singletons[reflect.TypeOf(f)]
+}
+
+// mergeKey returns the key of a field, fields with the same key are the same and fields of a singleton
+// kind share a key
+func mergeKey(f FieldFormatter) string {
+	if singleton(f) {
+		if p, ok := f.(Property); ok {
+			return "Property:" + strings.ToUpper(p.Name)
+		}
+		return reflect.TypeOf(f).String()
+	}
+	switch f := f.(type) {
+	case Tel:
+		return "TEL:" + phoneKey(f.Number)
+	case Email:
+		return "EMAIL:" + strings.ToLower(strings.TrimSpace(f.Email))
+	case Adr:
+		f.Types = nil
+		return fmt.Sprintf("%#v", f)
+	case Property:
+		return fmt.Sprintf("Property:%s.%s:%s", f.Group, strings.ToUpper(f.Name), f.Value)
+	}
+	return fmt.Sprintf("%#v", f)
+}
+
+// withTypes returns the field with the types of other added, for fields with types
+func withTypes(f, other FieldFormatter) FieldFormatter {
+	union := func(a, b []string) []string {
+		for _, t := range b {
+			if !hasType(a, t) {
+				a = append(append([]string(nil), a...), t)
+			}
+		}
+		return a
+	}
+	switch f := f.(type) {
+	case Tel:
+		if o, ok := other.(Tel); ok {
+			f.Types = union(f.Types, o.Types)
+		}
+		return f
+	case Email:
+		if o, ok := other.(Email); ok {
+			f.Types = union(f.Types, o.Types)
+		}
+		return f
+	case Adr:
+		if o, ok := other.(Adr); ok {
+			f.Types = union(f.Types, o.Types)
+		}
+		return f
+	}
+	return f
+}
+
+// describe returns a field as it appears in a content line, for the audit
+func describe(f FieldFormatter, version string) string {
+	l, err := f.Format(version)
+	if err == ErrVersion {
+		l, err = f.Format("4.0")
+	}
+	if err != nil {
+		return fmt.Sprintf("%T", f)
+	}
+	return l
+}
+
+// Merge combines cards describing the same person. Fields which can occur more than once, such as
+// TEL, EMAIL and ADR, are united with equal values combined, fields which can occur only once are
+// taken from the card preferred by the policy which has a non-empty value. The merged card has the
+// version of the preferred card and the newest REV of all cards. Values are kept as they are, only
+// the required fields of the merged card are checked.
+func Merge(policy MergePolicy, cards ...*VCard) (*VCard, MergeAudit, error) {
+	audit := MergeAudit{}
+	if len(cards) == 0 {
+		return nil, audit, fmt.Errorf("nothing to merge")
+	}
+
+	order := make([]int, len(cards))
+	for i := range order {
+		order[i] = i
+		audit.Sources = append(audit.Sources, i)
+	}
+	revs := make([]time.Time, len(cards))
+	for i, c := range cards {
+		if r, ok := First[Rev](c); ok {
+			revs[i] = r.Timestamp
+		}
+	}
+	if policy == NewestWins {
+		sort.SliceStable(order, func(i, j int) bool { return revs[order[i]].After(revs[order[j]]) })
+	}
+
+	merged := &VCard{Version: cards[order[0]].Version}
+	index := make(map[string]int)
+	// from holds the card each merged field was taken from
+	var from []int
+	var newest Rev
+	for _, src := range order {
+		for _, f := range cards[src].Fields {
+			if r, ok := f.(Rev); ok {
+				if r.Timestamp.After(newest.Timestamp) {
+					newest = r
+				}
+				continue
+			}
+			entry := MergeEntry{Source: src, Field: describe(f, merged.Version)}
+			if _, err := f.Format(merged.Version); err == ErrVersion {
+				entry.Action = MergeUnsupported
+				audit.Entries = append(audit.Entries, entry)
+				continue
+			}
+
+			key := mergeKey(f)
+			i, ok := index[key]
+			if t, isTel := f.(Tel); isTel && !ok {
+				// numbers with and without country code or trunk prefix are the same
+				for j, m := range merged.Fields {
+					if o, isTel := m.(Tel); isTel && phoneMatch(phoneKey(o.Number), phoneKey(t.Number)) {
+						i, ok = j, true
+						break
+					}
+				}
+			}
+			switch {
+			case !ok:
+				index[key] = len(merged.Fields)
+				merged.Fields = append(merged.Fields, f)
+				from = append(from, src)
+				entry.Action = MergeKept
+			case reflect.ValueOf(merged.Fields[i]).IsZero() && !reflect.ValueOf(f).IsZero():
+				// an empty singleton loses from a non-empty one
+				audit.Entries = append(audit.Entries, MergeEntry{Source: from[i], Field: describe(merged.Fields[i], merged.Version), Action: MergeDropped})
+				merged.Fields[i], from[i] = f, src
+				entry.Action = MergeKept
+			case singleton(f):
+				if reflect.DeepEqual(merged.Fields[i], f) {
+					entry.Action = MergeCombined
+				} else {
+					entry.Action = MergeDropped
+				}
+			default:
+				merged.Fields[i] = withTypes(merged.Fields[i], f)
+				entry.Action = MergeCombined
+			}
+			audit.Entries = append(audit.Entries, entry)
+		}
+	}
+	if !newest.Timestamp.IsZero() {
+		merged.Fields = append(merged.Fields, newest)
+	}
+
+	if err := merged.validateRequired(); err != nil {
+		return nil, audit, err
+	}
+	return merged, audit, nil
+}
+
+// Dedup finds the duplicates among the cards, scoring at least the threshold, and merges them with
+// the policy. It returns the cards in their original order with every group of duplicates replaced by
+// the merged card at the place of the first, and an audit for every merged card.
+func Dedup(cards []*VCard, threshold float64, policy MergePolicy) ([]*VCard, []MergeAudit, error) {
+	// union-find over the matches
+	parent := make([]int, len(cards))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, m := range FindDuplicates(cards, threshold) {
+		a, b := find(m.A), find(m.B)
+		if a > b {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+
+	groups := make(map[int][]int)
+	for i := range cards {
+		groups[find(i)] = append(groups[find(i)], i)
+	}
+
+	var (
+		result []*VCard
+		audits []MergeAudit
+	)
+	for i, c := range cards {
+		g := groups[i]
+		switch {
+		case find(i) != i:
+			continue
+		case len(g) == 1:
+			result = append(result, c)
+			continue
+		}
+
+		group := make([]*VCard, len(g))
+		for j, k := range g {
+			group[j] = cards[k]
+		}
+		merged, audit, err := Merge(policy, group...)
+		if err != nil {
+			return nil, nil, err
+		}
+		// refer to the positions in cards instead of the group
+		audit.Sources = g
+		for j := range audit.Entries {
+			audit.Entries[j].Source = g[audit.Entries[j].Source]
+		}
+		result = append(result, merged)
+		audits = append(audits, audit)
+	}
+	return result, audits, nil
+}
@@ -0,0 +1,201 @@
+package vcard_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func dedupCards(t *testing.T) []*vcard.VCard {
+	cards := [][]vcard.FieldFormatter{
+		{
+			vcard.FN{"Forrest Gump"},
+			vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+			vcard.Email{Types: []string{vcard.TelHome}, Email: "forrest@example.com"},
+			vcard.Tel{Types: []string{vcard.TelHome}, Number: "404-555-1212"},
+			vcard.Title{"Shrimp Man"},
+			vcard.Rev{Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			vcard.FN{"Jenny Curran"},
+			vcard.Email{Email: "jenny@example.com"},
+		},
+		{
+			vcard.FN{"Gump, Forrest"},
+			vcard.N{FamilyName: "Forrest", GivenName: "Gump"},
+			vcard.Email{Types: []string{vcard.TelWork}, Email: "Forrest@Example.com"},
+			vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1 (404) 555-1212"},
+			vcard.Title{"Captain"},
+			vcard.Org{Name: "Bubba Gump Shrimp Co."},
+			vcard.Rev{Timestamp: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			vcard.FN{"Forrest Gump"},
+			vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		},
+	}
+	var result []*vcard.VCard
+	for _, fields := range cards {
+		card, err := vcard.New("4.0", fields...)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		result = append(result, card)
+	}
+	return result
+}
+
+func TestScore(t *testing.T) {
+	cards := dedupCards(t)
+	tests := []struct {
+		a, b     int
+		min, max float64
+	}{
+		{0, 2, 0.99, 1},
+		{0, 1, 0, 0},
+		{2, 3, vcard.DefaultThreshold, 0.99},
+		// the same name alone isn't enough
+		{0, 3, 0.5, vcard.DefaultThreshold - 0.01},
+	}
+	for _, test := range tests {
+		if s, reasons := vcard.Score(cards[test.a], cards[test.b]); s < test.min || s > test.max {
+			t.Fatalf("expected a score of %d and %d from %.2f to %.2f, but got %.2f for %v", test.a, test.b, test.min, test.max, s, reasons)
+		}
+	}
+
+	matches := vcard.FindDuplicates(cards, vcard.DefaultThreshold)
+	if len(matches) != 2 || matches[0].A != 0 || matches[0].B != 2 || matches[1].A != 2 || matches[1].B != 3 {
+		t.Fatalf("expected 0-2 and 2-3 to match, but got %+v", matches)
+	}
+	if len(matches[0].Reasons) != 3 {
+		t.Fatalf("expected email, phone and name as reasons, but got %q", matches[0].Reasons)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cards := dedupCards(t)
+	tests := []struct {
+		policy vcard.MergePolicy
+		title  string
+	}{
+		{vcard.NewestWins, "Captain"},
+		{vcard.FirstWins, "Shrimp Man"},
+	}
+	for _, test := range tests {
+		merged, audit, err := vcard.Merge(test.policy, cards[0], cards[2])
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if title, _ := vcard.First[vcard.Title](merged); title.Title != test.title {
+			t.Fatalf("expected %q, but got %q", test.title, title.Title)
+		}
+		if tels := vcard.All[vcard.Tel](merged); len(tels) != 1 || len(tels[0].Types) != 2 {
+			t.Fatalf("expected a single phone with both types, but got %v", tels)
+		}
+		if emails := vcard.All[vcard.Email](merged); len(emails) != 1 {
+			t.Fatalf("expected a single email, but got %v", emails)
+		}
+		if org, _ := vcard.First[vcard.Org](merged); org.Name != "Bubba Gump Shrimp Co." {
+			t.Fatalf("expected the organization of the second card, but got %q", org.Name)
+		}
+		if revs := vcard.All[vcard.Rev](merged); len(revs) != 1 || revs[0].Timestamp.Year() != 2021 {
+			t.Fatalf("expected the newest REV, but got %v", revs)
+		}
+
+		dropped := 0
+		for _, e := range audit.Entries {
+			if e.Action == vcard.MergeDropped {
+				dropped++
+			}
+		}
+		// FN, N and TITLE differ
+		if dropped != 3 {
+			t.Fatalf("expected 3 dropped fields, but got %+v", audit.Entries)
+		}
+	}
+
+	// an empty value doesn't win
+	merged, _, err := vcard.Merge(vcard.FirstWins, cards[3], &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{}, vcard.Title{"Runner"}}})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if fn, _ := vcard.First[vcard.FN](merged); fn.FormattedName != "Forrest Gump" {
+		t.Fatalf("expected %q, but got %q", "Forrest Gump", fn.FormattedName)
+	}
+}
+
+func TestMergePhones(t *testing.T) {
+	card := func(numbers ...string) *vcard.VCard {
+		c := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{"Forrest Gump"}}}
+		for _, n := range numbers {
+			c.Fields = append(c.Fields, vcard.Tel{Number: n})
+		}
+		return c
+	}
+
+	// the extensions of a switchboard number are different numbers
+	merged, _, err := vcard.Merge(vcard.FirstWins, card("+1 404 555 1212 x12"), card("+1-404-555-1212;ext=13", "(404) 555-1212 x12"))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if tels := vcard.All[vcard.Tel](merged); len(tels) != 2 || tels[0].Number != "+1 404 555 1212 x12" || tels[1].Number != "+1-404-555-1212;ext=13" {
+		t.Fatalf("expected both extensions, but got %v", tels)
+	}
+
+	// national numbers with trunk prefix are the same as their international form
+	tests := []struct {
+		international string
+		national      string
+	}{
+		{"+370 612 34567", "8 612 34567"},
+		{"+31 6 12345678", "06 12345678"},
+	}
+	for _, test := range tests {
+		a, b := card(test.international), card(test.national)
+		if s, reasons := vcard.Score(a, b); len(reasons) != 2 || s < vcard.DefaultThreshold {
+			t.Fatalf("expected %q and %q to match by phone and name, but got %.2f for %v", test.international, test.national, s, reasons)
+		}
+		merged, _, err := vcard.Merge(vcard.FirstWins, a, b)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if tels := vcard.All[vcard.Tel](merged); len(tels) != 1 {
+			t.Fatalf("expected a single phone, but got %v", tels)
+		}
+		if matches := vcard.FindDuplicates([]*vcard.VCard{a, b}, vcard.DefaultThreshold); len(matches) != 1 {
+			t.Fatalf("expected %q and %q to be duplicates, but got %+v", test.international, test.national, matches)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	cards := dedupCards(t)
+	result, audits, err := vcard.Dedup(cards, vcard.DefaultThreshold, vcard.NewestWins)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(result) != 2 || len(audits) != 1 {
+		t.Fatalf("expected 2 cards and 1 audit, but got %d and %d", len(result), len(audits))
+	}
+	if fn, _ := vcard.First[vcard.FN](result[1]); fn.FormattedName != "Jenny Curran" {
+		t.Fatalf("expected Jenny to stay second, but got %q", fn.FormattedName)
+	}
+	if s := audits[0].Sources; len(s) != 3 || s[0] != 0 || s[1] != 2 || s[2] != 3 {
+		t.Fatalf("expected cards 0, 2 and 3 to be merged, but got %v", s)
+	}
+	for _, e := range audits[0].Entries {
+		if e.Source == 1 {
+			t.Fatalf("expected no fields of Jenny, but got %+v", e)
+		}
+	}
+	// invalid values don't fail the merge
+	cards[2].Fields = append(cards[2].Fields, vcard.Email{Email: "forrest at home"})
+	result, _, err = vcard.Dedup(cards, vcard.DefaultThreshold, vcard.NewestWins)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(result) != 2 || len(vcard.All[vcard.Email](result[0])) != 2 {
+		t.Fatalf("expected 2 cards with the invalid email kept, but got %d", len(result))
+	}
+}
@@ -0,0 +1,345 @@
+package vcard
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChangeOp is the kind of a Change
+type ChangeOp string
+
+const (
+	// ChangeAdded indicates a property which is only in the new card
+	ChangeAdded ChangeOp = "add"
+	// ChangeRemoved indicates a property which is only in the old card
+	ChangeRemoved ChangeOp = "remove"
+	// ChangeModified indicates a property with a different value or parameters in the new card
+	ChangeModified ChangeOp = "change"
+)
+
+// Change is a property level difference between two VCards. Old is nil for added properties
+// and New is nil for removed properties.
+type Change struct {
+	Op  ChangeOp
+	Old *Property
+	New *Property
+}
+
+// jsonChange is the JSON representation of a Change, with the properties as content lines
+type jsonChange struct {
+	Op       ChangeOp `json:"op"`
+	Property string   `json:"property"`
+	Old      string   `json:"old,omitempty"`
+	New      string   `json:"new,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (c Change) MarshalJSON() ([]byte, error) {
+	j := jsonChange{Op: c.Op}
+	for _, p := range []struct {
+		prop *Property
+		line *string
+	}{{c.Old, &j.Old}, {c.New, &j.New}} {
+		if p.prop == nil {
+			continue
+		}
+		j.Property = propertyName(*p.prop)
+		*p.line, _ = p.prop.Format("4.0")
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (c *Change) UnmarshalJSON(b []byte) error {
+	var j jsonChange
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*c = Change{Op: j.Op}
+	for _, l := range []struct {
+		line string
+		prop **Property
+	}{{j.Old, &c.Old}, {j.New, &c.New}} {
+		if l.line == "" {
+			continue
+		}
+		p, err := parseProperty(l.line)
+		if err != nil {
+			return err
+		}
+		*l.prop = &p
+	}
+	switch {
+	case c.Op == ChangeAdded && c.New != nil,
+		c.Op == ChangeRemoved && c.Old != nil,
+		c.Op == ChangeModified && c.Old != nil && c.New != nil:
+		return nil
+	}
+	return fmt.Errorf("invalid %q change", c.Op)
+}
+
+// propertyName returns the name of a property including its group
+func propertyName(p Property) string {
+	if p.Group != "" {
+		return strings.ToUpper(p.Group) + "." + p.Name
+	}
+	return p.Name
+}
+
+// singletonProperty reports whether a property can occur only once in a card
+func singletonProperty(version string, p Property) bool {
+	f, err := decodeField(version, p)
+	if err != nil {
+		return false
+	}
+	return singleton(f)
+}
+
+// Diff returns the property level differences between two VCards, regardless of the order of the
+// properties and of their parameters. Properties are compared as they are formatted in the version
+// of b. Properties with the same name are reported as changed when they have the same value or the
+// same parameters, or when they can occur only once, such as N or BDAY.
+func Diff(a, b *VCard) ([]Change, error) {
+	pa, err := a.properties(b.Version)
+	if err != nil {
+		return nil, err
+	}
+	pb, err := b.Properties()
+	if err != nil {
+		return nil, err
+	}
+
+	// equal properties aren't changed
+	unmatched := make(map[string][]int)
+	for j, p := range pb {
+		c := canonical(p)
+		unmatched[c] = append(unmatched[c], j)
+	}
+	var removed []int
+	for i, p := range pa {
+		c := canonical(p)
+		if js := unmatched[c]; len(js) > 0 {
+			unmatched[c] = js[1:]
+			continue
+		}
+		removed = append(removed, i)
+	}
+	added := make(map[int]bool)
+	for _, js := range unmatched {
+		for _, j := range js {
+			added[j] = true
+		}
+	}
+
+	// pair the remaining properties with the same name, first by value, then by parameters and
+	// finally for properties which can occur only once
+	pairs := make(map[int]int)
+	for _, same := range []func(x, y Property) bool{
+		func(x, y Property) bool { return x.Value == y.Value },
+		func(x, y Property) bool { return x.Params.String() == y.Params.String() },
+		func(x, y Property) bool { return singletonProperty(b.Version, x) },
+	} {
+		for _, i := range removed {
+			if _, ok := pairs[i]; ok {
+				continue
+			}
+			for j := range pb {
+				if added[j] && propertyName(pa[i]) == propertyName(pb[j]) && same(pa[i], pb[j]) {
+					pairs[i] = j
+					delete(added, j)
+					break
+				}
+			}
+		}
+	}
+
+	var changes []Change
+	for _, i := range removed {
+		old := pa[i]
+		if j, ok := pairs[i]; ok {
+			changes = append(changes, Change{Op: ChangeModified, Old: &old, New: &pb[j]})
+		} else {
+			changes = append(changes, Change{Op: ChangeRemoved, Old: &old})
+		}
+	}
+	for j := range pb {
+		if added[j] {
+			changes = append(changes, Change{Op: ChangeAdded, New: &pb[j]})
+		}
+	}
+	return changes, nil
+}
+
+// Conflict is a change which couldn't be applied by Patch
+type Conflict struct {
+	Change Change
+	// Current is the property of the card which conflicts with the change, it is nil when
+	// the property to change was removed from the card
+	Current *Property
+}
+
+// Error returns a description of the conflict
+func (c Conflict) Error() string {
+	p := c.Change.New
+	if p == nil {
+		p = c.Change.Old
+	}
+	if c.Current == nil {
+		return fmt.Sprintf("%s of %s conflicts with its removal", c.Change.Op, propertyName(*p))
+	}
+	return fmt.Sprintf("%s of %s conflicts with %s", c.Change.Op, propertyName(*p), c.Current.Value)
+}
+
+// patchEntry is a property of a card being patched, along with its field
+type patchEntry struct {
+	prop  Property
+	canon string
+	field FieldFormatter
+}
+
+// Patch applies changes, as returned by Diff, to a copy of a card and returns the copy. A change which
+// no longer fits the card, because the card changed the same property differently, isn't applied but
+// returned as a Conflict. Changes which are already present in the card are skipped. Conflicting REV
+// properties are resolved by keeping the newest. Values are applied as they are, only the required
+// fields of the patched card are checked.
+func Patch(card *VCard, changes []Change) (*VCard, []Conflict, error) {
+	props, err := card.Properties()
+	if err != nil {
+		return nil, nil, err
+	}
+	// the fields are decoded from the properties, as labels of 2.1 and 3.0 are properties of their own
+	entry := func(p Property) (patchEntry, error) {
+		f, err := decodeField(card.Version, p)
+		if err != nil {
+			return patchEntry{}, err
+		}
+		if _, err := f.Format(card.Version); err == ErrVersion {
+			return patchEntry{}, fmt.Errorf("%T is an unsupported field for vCard version %s", f, card.Version)
+		}
+		return patchEntry{prop: p, canon: canonical(p), field: f}, nil
+	}
+
+	entries := make([]patchEntry, len(props))
+	for i, p := range props {
+		if entries[i], err = entry(p); err != nil {
+			return nil, nil, err
+		}
+	}
+	find := func(p Property) int {
+		c := canonical(p)
+		for i, e := range entries {
+			if e.canon == c {
+				return i
+			}
+		}
+		return -1
+	}
+	// current returns the property of the same name if it can occur only once
+	current := func(p Property) int {
+		if !singletonProperty(card.Version, p) {
+			return -1
+		}
+		for i, e := range entries {
+			if propertyName(e.prop) == propertyName(p) {
+				return i
+			}
+		}
+		return -1
+	}
+	var conflicts []Conflict
+	conflict := func(c Change, i int) error {
+		if i < 0 {
+			conflicts = append(conflicts, Conflict{Change: c})
+			return nil
+		}
+		cur := entries[i].prop
+		if r, ok := entries[i].field.(Rev); ok && c.New != nil {
+			e, err := entry(*c.New)
+			if err != nil {
+				return err
+			}
+			if n, ok := e.field.(Rev); ok {
+				if n.Timestamp.After(r.Timestamp) {
+					entries[i] = e
+				}
+				return nil
+			}
+		}
+		conflicts = append(conflicts, Conflict{Change: c, Current: &cur})
+		return nil
+	}
+
+	for _, c := range changes {
+		switch c.Op {
+		case ChangeAdded:
+			if find(*c.New) >= 0 {
+				continue
+			}
+			if i := current(*c.New); i >= 0 {
+				if err := conflict(c, i); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			e, err := entry(*c.New)
+			if err != nil {
+				return nil, nil, err
+			}
+			// keep properties with the same name together
+			at := len(entries)
+			for i := len(entries) - 1; i >= 0; i-- {
+				if propertyName(entries[i].prop) == propertyName(e.prop) {
+					at = i + 1
+					break
+				}
+			}
+			entries = append(entries[:at], append([]patchEntry{e}, entries[at:]...)...)
+		case ChangeRemoved:
+			if i := find(*c.Old); i >= 0 {
+				entries = append(entries[:i], entries[i+1:]...)
+			} else if i := current(*c.Old); i >= 0 {
+				if err := conflict(c, i); err != nil {
+					return nil, nil, err
+				}
+			}
+		case ChangeModified:
+			if i := find(*c.Old); i >= 0 {
+				e, err := entry(*c.New)
+				if err != nil {
+					return nil, nil, err
+				}
+				entries[i] = e
+				continue
+			}
+			if find(*c.New) >= 0 {
+				continue
+			}
+			if err := conflict(c, current(*c.Old)); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid %q change", c.Op)
+		}
+	}
+
+	patched := &VCard{Version: card.Version, Fields: make([]FieldFormatter, len(entries))}
+	for i, e := range entries {
+		patched.Fields[i] = e.field
+	}
+	patched.Fields = attachLabels(patched.Fields)
+	if err := patched.validateRequired(); err != nil {
+		return nil, nil, err
+	}
+	return patched, conflicts, nil
+}
+
+// MergeChanges merges the changes made to base by theirs into ours, which is a three-way merge. The
+// changes which conflict with changes in ours aren't applied and are returned instead.
+func MergeChanges(base, ours, theirs *VCard) (*VCard, []Conflict, error) {
+	changes, err := Diff(base, theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Patch(ours, changes)
+}
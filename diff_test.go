@@ -0,0 +1,152 @@
+package vcard_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func diffCard(t *testing.T, fields ...vcard.FieldFormatter) *vcard.VCard {
+	card, err := vcard.New("4.0", fields...)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return card
+}
+
+func TestDiff(t *testing.T) {
+	a := diffCard(t,
+		vcard.FN{"Forrest Gump"},
+		vcard.Tel{Types: []string{vcard.TelHome, vcard.TelVoice}, Number: "+1-404-555-1212"},
+		vcard.Email{Types: []string{vcard.TelHome}, Email: "forrest@example.com"},
+		vcard.Title{"Shrimp Man"},
+		vcard.Property{Name: "X-SHOE-SIZE", Value: "11"},
+	)
+	b := diffCard(t,
+		vcard.Title{"Captain"},
+		vcard.Tel{Types: []string{vcard.TelVoice, vcard.TelHome}, Number: "+1-404-555-1212"},
+		vcard.FN{"Forrest Gump"},
+		vcard.Email{Types: []string{vcard.TelWork}, Email: "forrest@example.com"},
+		vcard.Email{Email: "gump@example.com"},
+	)
+
+	changes, err := vcard.Diff(a, b)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := []string{
+		"change EMAIL;TYPE=home:forrest@example.com EMAIL;TYPE=work:forrest@example.com",
+		"change TITLE:Shrimp Man TITLE:Captain",
+		"remove X-SHOE-SIZE:11",
+		"add EMAIL:gump@example.com",
+	}
+	if got := describeChanges(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	if changes, _ := vcard.Diff(a, a.Clone()); len(changes) != 0 {
+		t.Fatalf("expected no changes, but got %q", describeChanges(changes))
+	}
+
+	j, err := json.Marshal(changes[1])
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if s := `{"op":"change","property":"TITLE","old":"TITLE:Shrimp Man","new":"TITLE:Captain"}`; string(j) != s {
+		t.Fatalf("expected %s, but got %s", s, j)
+	}
+	var c vcard.Change
+	if err := json.Unmarshal(j, &c); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if !reflect.DeepEqual(c, changes[1]) {
+		t.Fatalf("expected %+v, but got %+v", changes[1], c)
+	}
+	if err := json.Unmarshal([]byte(`{"op":"add","old":"TITLE:Captain"}`), &c); err == nil {
+		t.Fatalf("expected an add without new property to fail")
+	}
+}
+
+// describeChanges returns the changes as strings for comparison
+func describeChanges(changes []vcard.Change) []string {
+	var s []string
+	for _, c := range changes {
+		d := string(c.Op)
+		for _, p := range []*vcard.Property{c.Old, c.New} {
+			if p != nil {
+				l, _ := p.Format("4.0")
+				d += " " + l
+			}
+		}
+		s = append(s, d)
+	}
+	return s
+}
+
+func TestPatch(t *testing.T) {
+	base := diffCard(t,
+		vcard.FN{"Forrest Gump"},
+		vcard.Title{"Shrimp Man"},
+		vcard.Email{Email: "forrest@example.com"},
+		vcard.Rev{Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	ours := diffCard(t,
+		vcard.FN{"Forrest Gump"},
+		vcard.Title{"Runner"},
+		vcard.Email{Email: "forrest@example.com"},
+		vcard.Tel{Number: "+1-404-555-1212"},
+		vcard.Rev{Timestamp: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	theirs := diffCard(t,
+		vcard.FN{"Forrest Gump"},
+		vcard.Title{"Captain"},
+		vcard.Email{Email: "gump@example.com"},
+		vcard.Rev{Timestamp: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	)
+
+	merged, conflicts, err := vcard.MergeChanges(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Current == nil || conflicts[0].Current.Value != "Runner" {
+		t.Fatalf("expected the title to conflict, but got %+v", conflicts)
+	}
+	expected := "BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nTITLE:Runner\nEMAIL:gump@example.com\nTEL;TYPE=voice;VALUE=uri:tel:+14045551212\nREV:20220101T000000Z\nEND:VCARD"
+	if got, _ := merged.Generate(); got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	// patching the old card with its diff gives the new card
+	changes, err := vcard.Diff(base, theirs)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	patched, conflicts, err := vcard.Patch(base, changes)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected to pass, but got %v: %v", conflicts, err)
+	}
+	if changes, _ := vcard.Diff(patched, theirs); len(changes) != 0 {
+		t.Fatalf("expected no changes, but got %q", describeChanges(changes))
+	}
+	// applying it again changes nothing
+	if again, conflicts, _ := vcard.Patch(patched, changes); len(conflicts) != 0 || !reflect.DeepEqual(again, patched) {
+		t.Fatalf("expected the patch to be idempotent, but got %v", conflicts)
+	}
+
+	if _, _, err := vcard.Patch(base, []vcard.Change{{Op: vcard.ChangeRemoved, Old: &vcard.Property{Name: "FN", Params: vcard.Params{}, Value: "Forrest Gump"}}}); err == nil {
+		t.Fatalf("expected removing FN to fail")
+	}
+
+	// an invalid value of theirs doesn't fail the merge
+	theirs.Fields = append(theirs.Fields, vcard.Email{Email: "forrest at home"})
+	merged, _, err = vcard.MergeChanges(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if emails := vcard.All[vcard.Email](merged); len(emails) != 2 {
+		t.Fatalf("expected the invalid email to be added, but got %v", emails)
+	}
+}
@@ -0,0 +1,14 @@
+package vcard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// ETag returns a strong entity tag for a card as it is served or stored, such as the body of a
+// download or the content of a file. Unlike Hash it changes with every byte of the data.
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return strconv.Quote(hex.EncodeToString(sum[:16]))
+}
@@ -241,6 +241,10 @@ func (f Tel) Format(v string) (string, error) {
 		if t == "" {
 			t = TelVoice
 		}
+		// 4.0 prefers tel URIs, which require international numbers
+		if p, err := ParsePhone(f.Number, ""); err == nil && v == "4.0" {
+			return fmt.Sprintf("TEL;TYPE=%s;VALUE=uri:%s", t, p.URI()), nil
+		}
 		return fmt.Sprintf("TEL;TYPE=%s:%s", t, f.Number), nil
 	}
 	return "", ErrVersion
@@ -269,7 +273,16 @@ const (
 	AdrPref = "pref"
 )
 
+// adrTypes contains the TYPE values of ADR per version, other values except x-names are left out
+var adrTypes = map[string][]string{
+	"2.1": {AdrDom, AdrIntl, AdrPostal, AdrParcel, AdrHome, AdrWork, AdrPref},
+	"3.0": {AdrDom, AdrIntl, AdrPostal, AdrParcel, AdrHome, AdrWork, AdrPref},
+	"4.0": {AdrHome, AdrWork},
+}
+
 // Adr type definition to specify the components of the delivery address for the vCard object.
+// Label is the formatted address, see LabelText. Geo is a geo URI, TZ a time zone name or UTC offset
+// and CC the ISO 3166 country code of the address, which are only written for version 4.0.
 type Adr struct {
 	Types           []string
 	PostOfficeBox   string
@@ -279,18 +292,55 @@ type Adr struct {
 	Region          string
 	PostalCode      string
 	CountryName     string
+	Label           string
+	Geo             string
+	TZ              string
+	CC              string
+}
+
+// versionTypes returns the types valid for the version, reporting whether pref was among them
+func (f Adr) versionTypes(v string) (t []string, pref bool) {
+	for _, typ := range f.Types {
+		l := strings.ToLower(typ)
+		switch {
+		case l == AdrPref && v == "4.0":
+			pref = true
+		case hasType(adrTypes[v], l), strings.HasPrefix(l, "x-"):
+			t = append(t, l)
+		}
+	}
+	return t, pref
 }
 
-// Format implements the FieldFormatter interface
+// Format implements the FieldFormatter interface. For versions 2.1 and 3.0 the label is written as
+// a separate LABEL property on the next line.
 func (f Adr) Format(v string) (string, error) {
 	switch v {
 	case "2.1", "3.0", "4.0":
-		t := strings.Join(f.Types, ",")
-		if t == "" {
-			t = "intl,postal,parcel,work"
+		t, pref := f.versionTypes(v)
+		if len(f.Types) == 0 && v != "4.0" {
+			t = []string{AdrIntl, AdrPostal, AdrParcel, AdrWork}
 		}
-		return fmt.Sprintf("ADR;TYPE=%s:%s;%s;%s;%s;%s;%s;%s",
-			t,
+		p := Property{Name: "ADR", Params: Params{}}
+		if len(t) > 0 {
+			p.Params["TYPE"] = t
+		}
+		if v == "4.0" {
+			if pref {
+				p.Params.Add("PREF", "1")
+			}
+			for _, param := range []struct{ name, value string }{
+				{"LABEL", encodeParamValue(f.Label)},
+				{"GEO", f.Geo},
+				{"TZ", f.TZ},
+				{"CC", f.CC},
+			} {
+				if param.value != "" {
+					p.Params.Add(param.name, param.value)
+				}
+			}
+		}
+		p.Value = strings.Join([]string{
 			f.PostOfficeBox,
 			f.ExtendedAddress,
 			f.StreetAddress,
@@ -298,7 +348,21 @@ func (f Adr) Format(v string) (string, error) {
 			f.Region,
 			f.PostalCode,
 			f.CountryName,
-		), nil
+		}, ";")
+		l, err := p.Format(v)
+		if err != nil || v == "4.0" || f.Label == "" {
+			return l, err
+		}
+
+		p.Name = "LABEL"
+		if v == "2.1" {
+			p.Params.Add("ENCODING", "QUOTED-PRINTABLE")
+			p.Value = encodeQuotedPrintable(f.Label)
+		} else {
+			p.Value = escapeText(f.Label)
+		}
+		label, err := p.Format(v)
+		return l + "\n" + label, err
 	}
 	return "", ErrVersion
 }
@@ -356,24 +420,39 @@ func (f Rev) Format(v string) (string, error) {
 
 // Agent type definition to specify information about another person who will
 // act on behalf of the individual or resource associated with the
-// vCard. Can contain a VCard of the agent or a string.
+// vCard. Can contain a VCard of the agent, a URI referring to the agent or a string.
 type Agent struct {
 	VCard *VCard
 	Text  string
+	URI   *url.URL
 }
 
-// Format implements the FieldFormatter interface
+// Format implements the FieldFormatter interface. Version 2.1 embeds the VCard of the agent on the
+// following lines, version 3.0 as escaped text. Version 4.0 has no AGENT, the agent is written as
+// RELATED;TYPE=agent with the VCard embedded as data URI.
 func (f Agent) Format(v string) (string, error) {
 	switch v {
 	case "2.1", "3.0":
-		if f.VCard != nil {
-			vcard, err := f.VCard.Generate()
+		switch {
+		case f.VCard != nil:
+			nested, err := f.nested(v)
 			if err != nil {
 				return "", err
 			}
-			return fmt.Sprintf("AGENT:%s", vcard), nil
+			if v == "2.1" {
+				return fmt.Sprintf("AGENT:\n%s", nested), nil
+			}
+			return fmt.Sprintf("AGENT:%s", escapeText(nested)), nil
+		case f.URI != nil:
+			value := "uri"
+			if v == "2.1" {
+				value = "URL"
+			}
+			return fmt.Sprintf("AGENT;VALUE=%s:%s", value, f.URI), nil
 		}
-		return fmt.Sprintf("AGENT:%s", f.Text), nil
+		return fmt.Sprintf("AGENT:%s", escapeText(f.Text)), nil
+	case "4.0":
+		return f.related()
 	}
 	return "", ErrVersion
 }
@@ -446,42 +525,84 @@ func (f Gender) Format(v string) (string, error) {
 	return "", ErrVersion
 }
 
-// Geo type definition to specify a latitude and longitude.
-// For vcard version 4.0
+// Geo type definition to specify a latitude and longitude, optionally with the altitude,
+// uncertainty and coordinate reference system of an RFC 5870 geo URI. For vcard version 4.0
 type Geo struct {
 	Lat  float64
 	Long float64
-}
-
-// Format implements the FieldFormatter interface
+	// Alt is the altitude in meters, nil if it is unknown
+	Alt *float64
+	// Uncertainty is the uncertainty in meters, 0 if it is unknown
+	Uncertainty float64
+	// CRS is the coordinate reference system, empty for the default wgs84
+	CRS string
+	// Precision is the maximum number of decimals written, 0 writes the shortest exact value
+	Precision int
+}
+
+// Format implements the FieldFormatter interface. Versions 2.1 and 3.0 only have the latitude and
+// longitude, which 3.0 separates by a semicolon.
 func (f Geo) Format(v string) (string, error) {
 	switch v {
-	case "2.1", "3.0":
-		return fmt.Sprintf("GEO:%f,%f", f.Lat, f.Long), nil
+	case "2.1":
+		return fmt.Sprintf("GEO:%s,%s", f.decimal(f.Lat), f.decimal(f.Long)), nil
+	case "3.0":
+		return fmt.Sprintf("GEO:%s;%s", f.decimal(f.Lat), f.decimal(f.Long)), nil
 	case "4.0":
-		return fmt.Sprintf("GEO:geo:%f,%f", f.Lat, f.Long), nil
+		return fmt.Sprintf("GEO:%s", f.URI()), nil
 	}
 	return "", ErrVersion
 }
 
-// IMPP type definition to specify instant messenger handle.
+const (
+	// IMPPHome indicates an instant messenger handle for personal use
+	IMPPHome = "home"
+	// IMPPWork indicates an instant messenger handle for work
+	IMPPWork = "work"
+	// IMPPMobile indicates an instant messenger handle used on a mobile device
+	IMPPMobile = "mobile"
+	// IMPPPref indicates a preferred instant messenger handle
+	IMPPPref = "pref"
+)
+
+// IMPP type definition to specify instant messenger handle. The platform is a name, alias or URI
+// scheme from IMPPPlatforms, see LookupIMPP.
 type IMPP struct {
+	Types    []string
 	Platform string
 	Handle   string
 }
 
-// Format implements the FieldFormatter interface
+// Format implements the FieldFormatter interface. Version 2.1 has no IMPP property, the handle is
+// written as extension property such as X-SKYPE if the platform has one.
 func (f IMPP) Format(v string) (string, error) {
-	switch v {
-	case "3.0", "4.0":
-		return fmt.Sprintf("IMPP:%s:%s", strings.ToLower(f.Platform), f.Handle), nil
+	p := Property{Name: "IMPP", Params: Params{}, Value: f.URI()}
+	var t []string
+	for _, typ := range f.Types {
+		if l := strings.ToLower(typ); l == IMPPPref && v == "4.0" {
+			p.Params.Add("PREF", "1")
+		} else {
+			t = append(t, l)
+		}
 	}
-	return "", ErrVersion
+	if len(t) > 0 {
+		p.Params["TYPE"] = t
+	}
+	if v == "2.1" {
+		platform, ok := LookupIMPP(f.Platform)
+		if !ok || platform.Property == "" {
+			return "", ErrVersion
+		}
+		p.Name, p.Value = platform.Property, f.Handle
+	}
+	return p.Format(v)
 }
 
 // Key type definition to specify a key associated with the vCard object.
 // It may point to an external URL, may be plain text, or may be embedded
-// in the vCard as a Base64 encoded block of text.
+// in the vCard as a Base64 encoded block of text. Type is PGP or X509 as in
+// versions 2.1 and 3.0, or a media type such as KeyPGP or KeyX509; see
+// NewPGPKey and NewX509Key.
 type Key struct {
 	Type   string
 	URI    *url.URL
@@ -489,8 +610,10 @@ type Key struct {
 	Binary bool
 }
 
-// Format implements the FieldFormatter interface
+// Format implements the FieldFormatter interface. The PGP and X509 types of versions 2.1 and 3.0
+// are written as the media types KeyPGP and KeyX509 for version 4.0 and vice versa.
 func (f Key) Format(v string) (string, error) {
+	f.Type = f.versionType(v)
 	var b bytes.Buffer
 	fmt.Fprintf(&b, "KEY")
 
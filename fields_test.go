@@ -1,8 +1,10 @@
 package vcard_test
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -174,8 +176,9 @@ func TestTel(t *testing.T) {
 		field    vcard.Tel
 		expected string
 	}{
-		{vcard.Tel{Number: "+3701234567890"}, "TEL;TYPE=voice:+3701234567890"},
-		{vcard.Tel{Number: "+3701234567890", Types: []string{vcard.TelVoice, vcard.TelFax}}, "TEL;TYPE=voice,fax:+3701234567890"},
+		{vcard.Tel{Number: "+3701234567890"}, "TEL;TYPE=voice;VALUE=uri:tel:+3701234567890"},
+		{vcard.Tel{Number: "(404) 555-1212 x12"}, "TEL;TYPE=voice:(404) 555-1212 x12"},
+		{vcard.Tel{Number: "+3701234567890", Types: []string{vcard.TelVoice, vcard.TelFax}}, "TEL;TYPE=voice,fax;VALUE=uri:tel:+3701234567890"},
 	}
 
 	for _, tc := range tt {
@@ -202,7 +205,7 @@ func TestAdr(t *testing.T) {
 				Locality:      "Testius",
 				CountryName:   "Testland",
 			},
-			"ADR;TYPE=intl,postal,parcel,work:;;Bandymus gatve 1-1;Testius;;10000;Testland",
+			"ADR:;;Bandymus gatve 1-1;Testius;;10000;Testland",
 		},
 		{
 			vcard.Adr{
@@ -228,6 +231,60 @@ func TestAdr(t *testing.T) {
 	}
 }
 
+func TestAdrVersions(t *testing.T) {
+	adr := vcard.Adr{
+		Types:         []string{vcard.AdrWork, vcard.AdrPostal, vcard.AdrPref},
+		StreetAddress: "100 Waters Edge",
+		Locality:      "Baytown",
+		Region:        "LA",
+		PostalCode:    "30314",
+		CountryName:   "United States of America",
+		Label:         "100 Waters Edge\nBaytown, LA 30314\nUnited States of America",
+		Geo:           "geo:29.7,-94.9",
+		TZ:            "America/Chicago",
+		CC:            "US",
+	}
+	tt := []struct {
+		version  string
+		expected string
+	}{
+		{"4.0", `ADR;CC=US;GEO="geo:29.7,-94.9";LABEL="100 Waters Edge^nBaytown, LA 30314^nUnited States of America";PREF=1;TYPE=work;TZ=America/Chicago:;;100 Waters Edge;Baytown;LA;30314;United States of America`},
+		{"3.0", "ADR;TYPE=work,postal,pref:;;100 Waters Edge;Baytown;LA;30314;United States of America\n" +
+			`LABEL;TYPE=work,postal,pref:100 Waters Edge\nBaytown\, LA 30314\nUnited States of America`},
+		{"2.1", "ADR;TYPE=work,postal,pref:;;100 Waters Edge;Baytown;LA;30314;United States of America\n" +
+			"LABEL;ENCODING=QUOTED-PRINTABLE;TYPE=work,postal,pref:100 Waters Edge=0D=0ABaytown, LA 30314=0D=0AUnited States of America"},
+	}
+	for _, tc := range tt {
+		got, err := adr.Format(tc.version)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got != tc.expected {
+			t.Fatalf("expected %q, but got %q", tc.expected, got)
+		}
+
+		card := &vcard.VCard{Version: tc.version, Fields: []vcard.FieldFormatter{vcard.N{FamilyName: "Gump"}, vcard.FN{"Forrest Gump"}, adr}}
+		s, err := card.Generate()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		cards, err := vcard.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if len(cards[0].Fields) != 3 {
+			t.Fatalf("expected the label to be part of the address, but got %+v", cards[0].Fields)
+		}
+		parsed := cards[0].Fields[2].(vcard.Adr)
+		if parsed.Label != adr.Label {
+			t.Fatalf("expected label %q, but got %q", adr.Label, parsed.Label)
+		}
+		if tc.version != "4.0" && strings.Join(parsed.Types, " ") != "work postal pref" {
+			t.Fatalf("expected separate types, but got %q", parsed.Types)
+		}
+	}
+}
+
 func TestEmail(t *testing.T) {
 	tt := []struct {
 		field    vcard.Email
@@ -305,12 +362,22 @@ func TestAgent(t *testing.T) {
 		{
 			version:  "3.0",
 			vcard:    card,
-			expected: fmt.Sprintf("AGENT:%s", cardTxt),
+			expected: `AGENT:BEGIN:VCARD\nVERSION:3.0\nN:Person\;Test\;\;\;\nFN:Test Person\nEND:VCARD`,
 		},
 		{
-			version:     "4.0",
-			vcard:       card,
-			expectedErr: vcard.ErrVersion,
+			version:  "2.1",
+			vcard:    card,
+			expected: "AGENT:\n" + strings.Replace(cardTxt, "3.0", "2.1", 1),
+		},
+		{
+			version:  "4.0",
+			vcard:    card,
+			expected: "RELATED;TYPE=agent:data:text/vcard;base64," + base64.StdEncoding.EncodeToString([]byte(strings.Replace(cardTxt, "3.0", "4.0", 1))),
+		},
+		{
+			version:  "4.0",
+			text:     "Test Person",
+			expected: "RELATED;TYPE=agent;VALUE=text:Test Person",
 		},
 	}
 
@@ -483,24 +550,24 @@ func TestGeo(t *testing.T) {
 			version:  "4.0",
 			lat:      39.95,
 			long:     -75.1667,
-			expected: "GEO:geo:39.950000,-75.166700",
+			expected: "GEO:geo:39.95,-75.1667",
 		},
 		{
 			version:  "3.0",
 			lat:      39.95,
 			long:     -75.1667,
-			expected: "GEO:39.950000,-75.166700",
+			expected: "GEO:39.95;-75.1667",
 		},
 		{
 			version:  "2.1",
 			lat:      39.95,
 			long:     -75.1667,
-			expected: "GEO:39.950000,-75.166700",
+			expected: "GEO:39.95,-75.1667",
 		},
 	}
 
 	for _, tc := range tt {
-		got, err := vcard.Geo{tc.lat, tc.long}.Format(tc.version)
+		got, err := vcard.Geo{Lat: tc.lat, Long: tc.long}.Format(tc.version)
 		if err != nil {
 			t.Fatalf("expected to pass, but got %v", err)
 		}
@@ -523,18 +590,30 @@ func TestIMPP(t *testing.T) {
 			version:  "4.0",
 			platform: "AIM",
 			handle:   "test@example.com",
-			expected: "IMPP:aim:test@example.com",
+			expected: "IMPP:aim:goim?screenname=test%40example.com",
+		},
+		{
+			version:  "2.1",
+			platform: "AIM",
+			handle:   "test@example.com",
+			expected: "X-AIM:test@example.com",
+		},
+		{
+			version:  "4.0",
+			platform: "Jabber",
+			handle:   "test@example.com",
+			expected: "IMPP:xmpp:test@example.com",
 		},
 		{
 			version:     "2.1",
-			platform:    "AIM",
+			platform:    "FaceTime",
 			handle:      "test@example.com",
 			expectedErr: vcard.ErrVersion,
 		},
 	}
 
 	for _, tc := range tt {
-		got, err := vcard.IMPP{tc.platform, tc.handle}.Format(tc.version)
+		got, err := vcard.IMPP{Platform: tc.platform, Handle: tc.handle}.Format(tc.version)
 		if err != tc.expectedErr {
 			t.Fatalf("expected err %v, but got: %v", tc.expectedErr, err)
 		}
@@ -0,0 +1,164 @@
+package vcard
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// decimal formats a coordinate with at most the precision of the Geo as decimals, without
+// trailing zeros
+func (f Geo) decimal(x float64) string {
+	if f.Precision <= 0 {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(x, 'f', f.Precision, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// URI returns the RFC 5870 geo URI of the Geo, such as geo:39.95,-75.1667;u=35
+func (f Geo) URI() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "geo:%s,%s", f.decimal(f.Lat), f.decimal(f.Long))
+	if f.Alt != nil {
+		fmt.Fprintf(&b, ",%s", f.decimal(*f.Alt))
+	}
+	if f.CRS != "" && !strings.EqualFold(f.CRS, "wgs84") {
+		fmt.Fprintf(&b, ";crs=%s", strings.ToLower(f.CRS))
+	}
+	if f.Uncertainty > 0 {
+		fmt.Fprintf(&b, ";u=%s", f.decimal(f.Uncertainty))
+	}
+	return b.String()
+}
+
+// ParseGeo parses an RFC 5870 geo URI as written by version 4.0, or the lat;long and lat,long forms
+// of versions 3.0 and 2.1. Parameters of the geo URI other than crs and u are ignored.
+func ParseGeo(s string) (Geo, error) {
+	var f Geo
+	s = strings.TrimSpace(s)
+	var coordinates []string
+	if len(s) >= 4 && strings.EqualFold(s[:4], "geo:") {
+		params := strings.Split(s[4:], ";")
+		coordinates = strings.Split(params[0], ",")
+		for _, param := range params[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				return f, fmt.Errorf("invalid geo URI parameter %q", param)
+			}
+			switch strings.ToLower(kv[0]) {
+			case "crs":
+				f.CRS = strings.ToLower(kv[1])
+			case "u":
+				u, err := strconv.ParseFloat(kv[1], 64)
+				if err != nil {
+					return f, fmt.Errorf("invalid geo URI uncertainty %q", kv[1])
+				}
+				f.Uncertainty = u
+			}
+		}
+	} else {
+		coordinates = strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' })
+		if len(coordinates) != 2 {
+			return f, fmt.Errorf("invalid geographic position %q", s)
+		}
+	}
+	if len(coordinates) < 2 || len(coordinates) > 3 {
+		return f, fmt.Errorf("invalid geographic position %q", s)
+	}
+	values := make([]float64, len(coordinates))
+	for i, c := range coordinates {
+		x, err := strconv.ParseFloat(strings.TrimSpace(c), 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid geographic position %q", s)
+		}
+		values[i] = x
+	}
+	f.Lat, f.Long = values[0], values[1]
+	if len(values) == 3 {
+		f.Alt = &values[2]
+	}
+	if f.CRS == "wgs84" {
+		f.CRS = ""
+	}
+	return f, f.Validate()
+}
+
+// Validate returns an error if the latitude or longitude is out of range or the uncertainty is
+// negative. The ranges are only checked for the default wgs84 reference system.
+func (f Geo) Validate() error {
+	if f.Uncertainty < 0 || math.IsNaN(f.Uncertainty) {
+		return fmt.Errorf("invalid uncertainty %v", f.Uncertainty)
+	}
+	if f.CRS != "" && !strings.EqualFold(f.CRS, "wgs84") {
+		return nil
+	}
+	if !(f.Lat >= -90 && f.Lat <= 90) {
+		return fmt.Errorf("latitude %v out of range", f.Lat)
+	}
+	if !(f.Long >= -180 && f.Long <= 180) {
+		return fmt.Errorf("longitude %v out of range", f.Long)
+	}
+	return nil
+}
+
+// Distance returns the great circle distance in meters between two wgs84 positions, ignoring
+// their altitude
+func (f Geo) Distance(g Geo) float64 {
+	rad := math.Pi / 180
+	dLat := (g.Lat - f.Lat) * rad
+	dLong := (g.Long - f.Long) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(f.Lat*rad)*math.Cos(g.Lat*rad)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// positions returns the positions of the VCard, from its GEO properties and the GEO parameters
+// of its addresses
+func positions(v *VCard) []Geo {
+	var g []Geo
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case Geo:
+			g = append(g, f)
+		case Adr:
+			if p, err := ParseGeo(f.Geo); f.Geo != "" && err == nil {
+				g = append(g, p)
+			}
+		}
+	}
+	return g
+}
+
+// Nearby returns the cards with a position within radius meters of the center, nearest first. The
+// positions are taken from GEO properties and the GEO parameters of addresses.
+func Nearby(cards []*VCard, center Geo, radius float64) []*VCard {
+	type near struct {
+		card     *VCard
+		distance float64
+	}
+	var found []near
+	for _, card := range cards {
+		d := math.Inf(1)
+		for _, p := range positions(card) {
+			d = math.Min(d, center.Distance(p))
+		}
+		if d <= radius {
+			found = append(found, near{card, d})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	result := make([]*VCard, len(found))
+	for i, n := range found {
+		result[i] = n.card
+	}
+	return result
+}
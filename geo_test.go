@@ -0,0 +1,82 @@
+package vcard_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestGeoURI(t *testing.T) {
+	alt := 120.5
+	tests := []struct {
+		geo      vcard.Geo
+		expected string
+	}{
+		{vcard.Geo{Lat: 39.95, Long: -75.1667}, "geo:39.95,-75.1667"},
+		{vcard.Geo{Lat: 39.950001, Long: -75.166701, Precision: 4}, "geo:39.95,-75.1667"},
+		{vcard.Geo{Lat: 48.2010, Long: 16.3695, Alt: &alt, Uncertainty: 66}, "geo:48.201,16.3695,120.5;u=66"},
+		{vcard.Geo{Lat: 48.2010, Long: 16.3695, CRS: "WGS84"}, "geo:48.201,16.3695"},
+		{vcard.Geo{Lat: 12, Long: 34, CRS: "Moon-2011", Uncertainty: 1.5}, "geo:12,34;crs=moon-2011;u=1.5"},
+	}
+	for _, test := range tests {
+		if got := test.geo.URI(); got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+	}
+}
+
+func TestParseGeo(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"geo:39.95,-75.1667", "geo:39.95,-75.1667"},
+		{"GEO:48.2010,16.3695,183;CRS=wgs84;u=40;foo=bar", "geo:48.201,16.3695,183;u=40"},
+		{"39.95;-75.1667", "geo:39.95,-75.1667"},
+		{"39.95,-75.1667", "geo:39.95,-75.1667"},
+		{"geo:500,500;crs=local", "geo:500,500;crs=local"},
+	}
+	for _, test := range tests {
+		g, err := vcard.ParseGeo(test.value)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got := g.URI(); got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+	}
+
+	for _, value := range []string{"", "geo:39.95", "39.95;-75.1667;10", "geo:91,0", "geo:0,181", "geo:0,0;u=-1", "geo:0,0;u", "forrest"} {
+		if _, err := vcard.ParseGeo(value); err == nil {
+			t.Fatalf("expected %q to fail", value)
+		}
+	}
+}
+
+func TestGeoDistance(t *testing.T) {
+	paris := vcard.Geo{Lat: 48.8566, Long: 2.3522}
+	london := vcard.Geo{Lat: 51.5074, Long: -0.1278}
+	if d := paris.Distance(london); math.Abs(d-343500) > 1000 {
+		t.Fatalf("expected about 343.5 km, but got %v", d)
+	}
+	if d := paris.Distance(paris); d != 0 {
+		t.Fatalf("expected 0, but got %v", d)
+	}
+}
+
+func TestNearby(t *testing.T) {
+	card := func(name string, f vcard.FieldFormatter) *vcard.VCard {
+		return &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{name}, f}}
+	}
+	cards := []*vcard.VCard{
+		card("London", vcard.Geo{Lat: 51.5074, Long: -0.1278}),
+		card("Versailles", vcard.Adr{Locality: "Versailles", Geo: "geo:48.8049,2.1204"}),
+		card("Nowhere", vcard.Email{Email: "nowhere@example.com"}),
+		card("Paris", vcard.Geo{Lat: 48.8566, Long: 2.3522}),
+	}
+	got := vcard.Nearby(cards, vcard.Geo{Lat: 48.86, Long: 2.35}, 50000)
+	if len(got) != 2 || got[0] != cards[3] || got[1] != cards[1] {
+		t.Fatalf("expected Paris and Versailles, but got %d cards", len(got))
+	}
+}
@@ -0,0 +1,720 @@
+package vcard
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// hcardTemplate renders the items of an h-card, values without a visible representation are written
+// as data elements
+var hcardTemplate = template.Must(template.New("h-card").Parse(`
+{{- define "item" -}}
+{{- if .Parts -}}
+<span class="{{.Class}}">{{range $i, $p := .Parts}}{{if $i}} {{end}}{{template "item" $p}}{{end}}</span>
+{{- else if .Src -}}
+<img class="{{.Class}}" src="{{.Src}}" alt="{{.Text}}">
+{{- else if .Href -}}
+<a class="{{.Class}}" href="{{.Href}}">{{.Text}}</a>
+{{- else if .Datetime -}}
+<time class="{{.Class}}" datetime="{{.Datetime}}">{{.Text}}</time>
+{{- else if .Hidden -}}
+<data class="{{.Class}}" value="{{.Text}}"></data>
+{{- else -}}
+<span class="{{.Class}}">{{.Text}}</span>
+{{- end -}}
+{{- end -}}
+<div class="h-card">
+{{- range .}}
+  {{template "item" .}}
+{{- end}}
+</div>`))
+
+// hcardItem is a property of an h-card
+type hcardItem struct {
+	Class    string
+	Text     string
+	Href     template.URL
+	Src      template.URL
+	Datetime string
+	Hidden   bool
+	Parts    []hcardItem
+}
+
+// unsafeSchemes are the URL schemes which are never written into links
+var unsafeSchemes = map[string]bool{"javascript": true, "vbscript": true, "data": true}
+
+// safeURL returns u for use as a link, or an empty URL when it is relative or has an unsafe scheme
+func safeURL(u string) template.URL {
+	p, err := url.Parse(u)
+	if err != nil || p.Scheme == "" || unsafeSchemes[strings.ToLower(p.Scheme)] {
+		return ""
+	}
+	return template.URL(u)
+}
+
+// parts returns the items for the non-empty values, which are given as class and value pairs
+func parts(hidden bool, kv ...string) []hcardItem {
+	var items []hcardItem
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			items = append(items, hcardItem{Class: kv[i], Text: kv[i+1], Hidden: hidden})
+		}
+	}
+	return items
+}
+
+func dateItem(class string, t time.Time) hcardItem {
+	s := t.Format("2006-01-02")
+	if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+		s = t.Format(time.RFC3339)
+	}
+	return hcardItem{Class: class, Text: s, Datetime: s}
+}
+
+func (v *VCard) hcardItems() []hcardItem {
+	var fn string
+	for _, f := range v.Fields {
+		if f, ok := f.(FN); ok && fn == "" {
+			fn = f.FormattedName
+		}
+	}
+
+	var items []hcardItem
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case FN:
+			items = append(items, hcardItem{Class: "p-name", Text: f.FormattedName})
+		case N:
+			p := parts(fn != "",
+				"p-honorific-prefix", f.HonorificPrefixes,
+				"p-given-name", f.GivenName,
+				"p-additional-name", f.AdditionalNames,
+				"p-family-name", f.FamilyName,
+				"p-honorific-suffix", f.HonorificSuffixes,
+			)
+			if fn != "" {
+				items = append(items, p...)
+			} else if len(p) > 0 {
+				// without an FN the components make up the visible name
+				items = append(items, hcardItem{Class: "p-name", Parts: p})
+			}
+		case Org:
+			items = append(items, hcardItem{Class: "p-org", Text: f.Name})
+		case Title:
+			items = append(items, hcardItem{Class: "p-job-title", Text: f.Title})
+		case Role:
+			items = append(items, hcardItem{Class: "p-role", Text: f.Role})
+		case Photo:
+			item := hcardItem{Class: "u-photo", Text: fn}
+			switch {
+			case f.Base64Data != "":
+				mt := strings.ToLower(f.Type)
+				if mt == "" {
+					mt = "image/jpeg"
+				} else if !strings.Contains(mt, "/") {
+					mt = "image/" + mt
+				}
+				if !strings.HasPrefix(mt, "image/") {
+					continue
+				}
+				item.Src = template.URL("data:" + mt + ";base64," + strings.Join(strings.Fields(f.Base64Data), ""))
+			case f.URI != nil:
+				if item.Src = safeURL(f.URI.String()); item.Src == "" {
+					continue
+				}
+			default:
+				continue
+			}
+			items = append(items, item)
+		case Tel:
+			items = append(items, hcardItem{Class: "p-tel", Text: f.Number, Href: template.URL("tel:" + strings.Join(strings.Fields(f.Number), ""))})
+		case Email:
+			items = append(items, hcardItem{Class: "u-email", Text: f.Email, Href: template.URL("mailto:" + f.Email)})
+		case Adr:
+			p := parts(false,
+				"p-post-office-box", f.PostOfficeBox,
+				"p-extended-address", f.ExtendedAddress,
+				"p-street-address", f.StreetAddress,
+				"p-locality", f.Locality,
+				"p-region", f.Region,
+				"p-postal-code", f.PostalCode,
+				"p-country-name", f.CountryName,
+			)
+			if len(p) > 0 {
+				items = append(items, hcardItem{Class: "p-adr h-adr", Parts: p})
+			}
+		case Bday:
+			items = append(items, dateItem("dt-bday", f.Timestamp))
+		case Anniversary:
+			items = append(items, dateItem("dt-anniversary", f.Date))
+		case Geo:
+			items = append(items, hcardItem{Class: "p-geo h-geo", Parts: parts(false,
+				"p-latitude", strconv.FormatFloat(f.Lat, 'f', -1, 64),
+				"p-longitude", strconv.FormatFloat(f.Long, 'f', -1, 64),
+			)})
+		case IMPP:
+			items = append(items, hcardItem{Class: "u-impp", Text: f.Handle, Href: safeURL(f.URI())})
+		case Gender:
+			c := strings.SplitN(f.Val, ";", 2)
+			for len(c) < 2 {
+				c = append(c, "")
+			}
+			items = append(items, parts(false, "p-sex", c[0], "p-gender-identity", c[1])...)
+		case Key:
+			if f.URI != nil {
+				if u := safeURL(f.URI.String()); u != "" {
+					items = append(items, hcardItem{Class: "u-key", Text: f.URI.String(), Href: u})
+				}
+			}
+		case Property:
+			if f.Group != "" {
+				continue
+			}
+			switch f.Name {
+			case "NOTE":
+				items = append(items, hcardItem{Class: "p-note", Text: f.Text()})
+			case "NICKNAME":
+				items = append(items, hcardItem{Class: "p-nickname", Text: f.Text()})
+			case "CATEGORIES":
+				for _, c := range splitComponents(f.Value, ',') {
+					items = append(items, hcardItem{Class: "p-category", Text: c})
+				}
+			case "URL":
+				if u := safeURL(f.Value); u != "" {
+					items = append(items, hcardItem{Class: "u-url", Text: f.Value, Href: u})
+				}
+			case "UID":
+				items = append(items, hcardItem{Class: "u-uid", Text: f.Value, Hidden: true})
+			}
+		}
+	}
+	return items
+}
+
+// HCard renders the VCard as an h-card microformat, fields without an h-card property are left out
+func (v *VCard) HCard() (template.HTML, error) {
+	var b bytes.Buffer
+	if err := hcardTemplate.Execute(&b, v.hcardItems()); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
+
+// legacyClasses maps the class names of the original hCard onto their h-card properties
+var legacyClasses = map[string]string{
+	"fn":                "p-name",
+	"honorific-prefix":  "p-honorific-prefix",
+	"given-name":        "p-given-name",
+	"additional-name":   "p-additional-name",
+	"family-name":       "p-family-name",
+	"honorific-suffix":  "p-honorific-suffix",
+	"nickname":          "p-nickname",
+	"org":               "p-org",
+	"organization-name": "p-organization-name",
+	"organization-unit": "p-organization-unit",
+	"title":             "p-job-title",
+	"role":              "p-role",
+	"photo":             "u-photo",
+	"tel":               "p-tel",
+	"email":             "u-email",
+	"bday":              "dt-bday",
+	"url":               "u-url",
+	"uid":               "u-uid",
+	"key":               "u-key",
+	"note":              "p-note",
+	"category":          "p-category",
+	"post-office-box":   "p-post-office-box",
+	"extended-address":  "p-extended-address",
+	"street-address":    "p-street-address",
+	"locality":          "p-locality",
+	"region":            "p-region",
+	"postal-code":       "p-postal-code",
+	"country-name":      "p-country-name",
+	"latitude":          "p-latitude",
+	"longitude":         "p-longitude",
+}
+
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func classes(n *html.Node) []string {
+	c, _ := attr(n, "class")
+	return strings.Fields(c)
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range classes(n) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the text of a node with whitespace collapsed, images are replaced by their alt text
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		case n.Type == html.ElementNode && n.Data == "img":
+			alt, _ := attr(n, "alt")
+			b.WriteString(" " + alt + " ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// propertyValue parses the value of an element for a property with the given prefix
+func propertyValue(n *html.Node, prefix string) string {
+	var names []string
+	switch prefix {
+	case "u":
+		switch n.Data {
+		case "a", "area", "link":
+			names = []string{"href"}
+		case "img", "audio", "video", "source", "iframe":
+			names = []string{"src"}
+		case "object":
+			names = []string{"data"}
+		}
+	case "dt":
+		switch n.Data {
+		case "time", "ins", "del":
+			names = []string{"datetime"}
+		}
+	}
+	switch n.Data {
+	case "abbr", "link":
+		names = append(names, "title")
+	case "data", "input":
+		names = append(names, "value")
+	case "img", "area":
+		names = append(names, "alt")
+	}
+	for _, name := range names {
+		if v, ok := attr(n, name); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return textContent(n)
+}
+
+// hcardParser collects the properties of a single h-card
+type hcardParser struct {
+	legacy bool
+	props  map[string][]string
+	adrs   []map[string]string
+	types  map[string][][]string
+}
+
+// properties returns the properties an element has, as prefix and name
+func (p *hcardParser) properties(n *html.Node) [][2]string {
+	var props [][2]string
+	for _, c := range classes(n) {
+		if p.legacy {
+			if c = legacyClasses[c]; c == "" {
+				continue
+			}
+		}
+		i := strings.IndexByte(c, '-')
+		if i < 0 {
+			continue
+		}
+		switch c[:i] {
+		case "p", "u", "dt", "e":
+			props = append(props, [2]string{c[:i], c[i+1:]})
+		}
+	}
+	return props
+}
+
+// root reports whether an element is the root of a nested microformat, named by its h-* class
+// and its legacy class
+func (p *hcardParser) root(n *html.Node, class, legacy string) bool {
+	if p.legacy {
+		return hasClass(n, legacy)
+	}
+	return hasClass(n, class)
+}
+
+// legacyValue applies the type and value sub-elements of a legacy tel or email
+func legacyValue(n *html.Node) (string, []string) {
+	var (
+		values []string
+		types  []string
+	)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch {
+			case hasClass(c, "value"):
+				values = append(values, propertyValue(c, "p"))
+			case hasClass(c, "type"):
+				types = append(types, strings.ToLower(propertyValue(c, "p")))
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return strings.Join(values, ""), types
+}
+
+func (p *hcardParser) add(name, value string, types []string) {
+	if value == "" {
+		return
+	}
+	p.props[name] = append(p.props[name], value)
+	p.types[name] = append(p.types[name], types)
+}
+
+// structure collects the properties of a nested h-adr or h-geo
+func (p *hcardParser) structure(n *html.Node) map[string]string {
+	s := make(map[string]string)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			for _, prop := range p.properties(c) {
+				if _, ok := s[prop[1]]; !ok {
+					s[prop[1]] = propertyValue(c, prop[0])
+				}
+			}
+			if p.legacy && hasClass(c, "type") {
+				s["type"] = strings.ToLower(propertyValue(c, "p"))
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return s
+}
+
+func (p *hcardParser) walk(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		props := p.properties(c)
+
+		switch {
+		case p.root(c, "h-card", "vcard") || hasClass(c, "vcard"):
+			// a nested card is the value of a property like p-org, its properties aren't ours
+			for _, prop := range props {
+				s := p.structure(c)
+				v := s["name"]
+				if v == "" {
+					v = textContent(c)
+				}
+				p.add(prop[1], v, nil)
+			}
+			continue
+		case p.root(c, "h-adr", "adr"):
+			s := p.structure(c)
+			if len(s) > 0 {
+				p.adrs = append(p.adrs, s)
+			}
+			continue
+		case p.root(c, "h-geo", "geo"):
+			s := p.structure(c)
+			if s["latitude"] == "" && s["longitude"] == "" {
+				p.add("geo", propertyValue(c, "p"), nil)
+			} else {
+				p.add("latitude", s["latitude"], nil)
+				p.add("longitude", s["longitude"], nil)
+			}
+			continue
+		}
+
+		for _, prop := range props {
+			if p.legacy && (prop[1] == "tel" || prop[1] == "email") {
+				if v, types := legacyValue(c); v != "" || len(types) > 0 {
+					if v == "" {
+						v = propertyValue(c, prop[0])
+						for _, t := range types {
+							v = strings.TrimSpace(strings.TrimPrefix(v, t))
+						}
+					}
+					p.add(prop[1], v, types)
+					continue
+				}
+			}
+			p.add(prop[1], propertyValue(c, prop[0]), nil)
+		}
+		p.walk(c)
+	}
+}
+
+func (p *hcardParser) first(name string) string {
+	if v := p.props[name]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// date parses a dt- property, dates without a time use the default layout of the field
+func date(s string) (time.Time, string, bool) {
+	t, format, ok := parseTime(s, dateFormat)
+	if format == "2006-01-02" {
+		format = ""
+	}
+	return t, format, ok
+}
+
+// card turns the collected properties into a version 4.0 VCard, nil if the h-card has no name
+func (p *hcardParser) card(root *html.Node) (*VCard, error) {
+	var fields []FieldFormatter
+
+	n := N{
+		HonorificPrefixes: p.first("honorific-prefix"),
+		GivenName:         p.first("given-name"),
+		AdditionalNames:   p.first("additional-name"),
+		FamilyName:        p.first("family-name"),
+		HonorificSuffixes: p.first("honorific-suffix"),
+	}
+	org := p.first("organization-name")
+	if org == "" {
+		org = p.first("org")
+	}
+
+	fn := p.first("name")
+	if fn == "" {
+		fn = joinNonEmpty(" ", n.HonorificPrefixes, n.GivenName, n.AdditionalNames, n.FamilyName, n.HonorificSuffixes)
+	}
+	if fn == "" {
+		fn = org
+	}
+	if fn == "" && len(p.props) == 0 && len(p.adrs) == 0 {
+		// the implied name of a card without properties, like <a class="h-card" href="...">Name</a>
+		fn = propertyValue(root, "p")
+	}
+	if fn == "" {
+		return nil, nil
+	}
+
+	fields = append(fields, FN{fn})
+	if n != (N{}) {
+		fields = append(fields, n)
+	}
+	if org != "" || len(p.props["organization-unit"]) > 0 {
+		fields = append(fields, Org{Name: org, Units: p.props["organization-unit"]})
+	}
+	for _, t := range p.props["job-title"] {
+		fields = append(fields, Title{t})
+	}
+	for _, r := range p.props["role"] {
+		fields = append(fields, Role{r})
+	}
+	for _, s := range p.props["photo"] {
+		if strings.HasPrefix(s, "data:") {
+			if i := strings.Index(s, ";base64,"); i > 0 {
+				fields = append(fields, Photo{Type: s[len("data:"):i], Base64Data: s[i+len(";base64,"):]})
+			}
+			continue
+		}
+		if u, err := url.Parse(s); err == nil {
+			fields = append(fields, Photo{URI: u})
+		}
+	}
+	for i, s := range p.props["tel"] {
+		fields = append(fields, Tel{Types: p.types["tel"][i], Number: strings.TrimPrefix(s, "tel:")})
+	}
+	for i, s := range p.props["email"] {
+		s = strings.TrimPrefix(s, "mailto:")
+		if j := strings.IndexByte(s, '?'); j >= 0 {
+			s = s[:j]
+		}
+		fields = append(fields, Email{Types: p.types["email"][i], Email: s})
+	}
+
+	adrs := p.adrs
+	// address components may be properties of the card itself
+	top := make(map[string]string)
+	for _, k := range []string{"post-office-box", "extended-address", "street-address", "locality", "region", "postal-code", "country-name"} {
+		if v := p.first(k); v != "" {
+			top[k] = v
+		}
+	}
+	if len(top) > 0 {
+		adrs = append(adrs, top)
+	}
+	if len(adrs) == 0 {
+		for _, s := range p.props["adr"] {
+			adrs = append(adrs, map[string]string{"street-address": s})
+		}
+	}
+	for _, a := range adrs {
+		var types []string
+		if a["type"] != "" {
+			types = []string{a["type"]}
+		}
+		fields = append(fields, Adr{
+			Types:           types,
+			PostOfficeBox:   a["post-office-box"],
+			ExtendedAddress: a["extended-address"],
+			StreetAddress:   a["street-address"],
+			Locality:        a["locality"],
+			Region:          a["region"],
+			PostalCode:      a["postal-code"],
+			CountryName:     a["country-name"],
+		})
+	}
+
+	if t, format, ok := date(p.first("bday")); ok {
+		fields = append(fields, Bday{Timestamp: t, TimeFormat: format})
+	}
+	if t, format, ok := date(p.first("anniversary")); ok {
+		fields = append(fields, Anniversary{Date: t, TimeFormat: format})
+	}
+
+	lat, long := p.first("latitude"), p.first("longitude")
+	if g, err := ParseGeo(p.first("geo")); err == nil && lat == "" {
+		fields = append(fields, g)
+	} else if g, err := ParseGeo(lat + ";" + long); err == nil {
+		fields = append(fields, g)
+	}
+
+	for _, s := range p.props["impp"] {
+		if f, err := ParseIMPP(s); err == nil {
+			fields = append(fields, f)
+		}
+	}
+	if sex, identity := p.first("sex"), p.first("gender-identity"); sex != "" || identity != "" {
+		g := sex
+		if identity != "" {
+			g += ";" + identity
+		}
+		fields = append(fields, Gender{g})
+	}
+	for _, s := range p.props["key"] {
+		if u, err := url.Parse(s); err == nil && u.Scheme != "" {
+			fields = append(fields, Key{URI: u})
+		}
+	}
+
+	urls := p.props["url"]
+	if len(urls) == 0 && root.Data == "a" {
+		// the implied url of a card on a link
+		if href, ok := attr(root, "href"); ok && href != "" {
+			urls = []string{href}
+		}
+	}
+	for _, s := range urls {
+		fields = append(fields, Property{Name: "URL", Value: s})
+	}
+	for _, s := range p.props["note"] {
+		fields = append(fields, Property{Name: "NOTE", Value: escapeText(s)})
+	}
+	for _, s := range p.props["nickname"] {
+		fields = append(fields, Property{Name: "NICKNAME", Value: escapeText(s)})
+	}
+	if c := p.props["category"]; len(c) > 0 {
+		escaped := make([]string, len(c))
+		for i, s := range c {
+			escaped[i] = escapeText(s)
+		}
+		fields = append(fields, Property{Name: "CATEGORIES", Value: strings.Join(escaped, ",")})
+	}
+	if uid := p.first("uid"); uid != "" {
+		fields = append(fields, Property{Name: "UID", Value: uid})
+	}
+
+	return New("4.0", fields...)
+}
+
+// HCardError reports an h-card which couldn't be read as VCard, cards are numbered in document
+// order starting at 1
+type HCardError struct {
+	Card int
+	Err  error
+}
+
+// Error implements the error interface
+func (err *HCardError) Error() string {
+	return fmt.Sprintf("h-card %d: %v", err.Card, err.Err)
+}
+
+// HCardErrors collects the errors of all h-cards which couldn't be read
+type HCardErrors []*HCardError
+
+// Error implements the error interface
+func (errs HCardErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// ParseHCard extracts the h-cards and legacy hCards of an HTML document as version 4.0 VCards.
+// Properties without a matching field are ignored, as are cards without a name. Cards which aren't
+// valid are skipped and reported in an HCardErrors, along with the other cards.
+func ParseHCard(r io.Reader) ([]*VCard, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cards []*VCard
+		errs  HCardErrors
+		count int
+		find  func(*html.Node)
+	)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && (hasClass(n, "h-card") || hasClass(n, "vcard")) {
+			count++
+			p := &hcardParser{
+				legacy: !hasClass(n, "h-card"),
+				props:  make(map[string][]string),
+				types:  make(map[string][][]string),
+			}
+			p.walk(n)
+			card, err := p.card(n)
+			if err != nil {
+				errs = append(errs, &HCardError{Card: count, Err: err})
+				return
+			}
+			if card != nil {
+				cards = append(cards, card)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if len(errs) > 0 {
+		return cards, errs
+	}
+	return cards, nil
+}
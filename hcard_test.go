@@ -0,0 +1,148 @@
+package vcard_test
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestHCard(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.FN{"Forrest Gump"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Title{"Shrimp Man"},
+		vcard.Photo{URI: &url.URL{Scheme: "http", Host: "www.example.com", Path: "/forrest.gif"}},
+		vcard.Tel{Types: []string{vcard.TelWork}, Number: "+1 111 555 1212"},
+		vcard.Email{Email: "forrestgump@example.com"},
+		vcard.Adr{StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+		vcard.Geo{Lat: 37.386013, Long: -122.082932},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	got, err := card.HCard()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := `<div class="h-card">
+  <data class="p-given-name" value="Forrest"></data>
+  <data class="p-family-name" value="Gump"></data>
+  <span class="p-name">Forrest Gump</span>
+  <span class="p-org">Bubba Gump Shrimp Co.</span>
+  <span class="p-job-title">Shrimp Man</span>
+  <img class="u-photo" src="http://www.example.com/forrest.gif" alt="Forrest Gump">
+  <a class="p-tel" href="tel:&#43;11115551212">&#43;1 111 555 1212</a>
+  <a class="u-email" href="mailto:forrestgump@example.com">forrestgump@example.com</a>
+  <span class="p-adr h-adr"><span class="p-street-address">100 Waters Edge</span> <span class="p-locality">Baytown</span> <span class="p-region">LA</span> <span class="p-postal-code">30314</span></span>
+  <time class="dt-bday" datetime="1944-06-06">1944-06-06</time>
+  <span class="p-geo h-geo"><span class="p-latitude">37.386013</span> <span class="p-longitude">-122.082932</span></span>
+</div>`
+	if string(got) != expected {
+		t.Fatalf("expected %s, but got %s", expected, got)
+	}
+
+	cards, err := vcard.ParseHCard(strings.NewReader("<html><body>" + string(got) + "</body></html>"))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("expected 1 card, but got %d", len(cards))
+	}
+	fields := []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Title{"Shrimp Man"},
+		vcard.Photo{URI: &url.URL{Scheme: "http", Host: "www.example.com", Path: "/forrest.gif"}},
+		vcard.Tel{Number: "+1 111 555 1212"},
+		vcard.Email{Email: "forrestgump@example.com"},
+		vcard.Adr{StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+		vcard.Geo{Lat: 37.386013, Long: -122.082932},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, fields) {
+		t.Fatalf("expected %v, but got %v", fields, cards[0].Fields)
+	}
+}
+
+func TestHCardEscaping(t *testing.T) {
+	card := vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{`<script>alert("x")</script>`},
+		vcard.Property{Name: "URL", Value: "javascript:alert(1)"},
+		vcard.Email{Email: `a"onclick="x@example.com`},
+	}}
+	got, err := card.HCard()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	for _, s := range []string{"<script>", "javascript:", `"onclick`} {
+		if strings.Contains(string(got), s) {
+			t.Fatalf("expected %q to be escaped, but got %s", s, got)
+		}
+	}
+}
+
+func TestParseHCardLegacy(t *testing.T) {
+	in := `<div class="vcard">
+		<a class="url fn" href="http://example.com/jenny">Jenny Curran</a>
+		<div class="org"><span class="organization-name">Greenbow High</span> <span class="organization-unit">Track</span></div>
+		<div class="tel"><span class="type">home</span> <span class="value">+1 555 0100</span></div>
+		<a class="email" href="mailto:jenny@example.org">mail me</a>
+		<div class="adr"><span class="type">home</span> <span class="locality">Greenbow</span>, <abbr class="region" title="Alabama">AL</abbr></div>
+		<abbr class="geo" title="32.2;-86.1">Greenbow</abbr>
+		<span class="category">friend</span> <span class="category">runner</span>
+	</div>
+	<p>Also see <a class="h-card" href="https://example.com/bubba">Bubba Blue</a>.</p>
+	<div class="h-card"><span class="p-org">Empty</span><div class="p-job-title">no</div></div>`
+
+	cards, err := vcard.ParseHCard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("expected 3 cards, but got %d", len(cards))
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Jenny Curran"},
+		vcard.Org{Name: "Greenbow High", Units: []string{"Track"}},
+		vcard.Tel{Types: []string{"home"}, Number: "+1 555 0100"},
+		vcard.Email{Email: "jenny@example.org"},
+		vcard.Adr{Types: []string{"home"}, Locality: "Greenbow", Region: "Alabama"},
+		vcard.Geo{Lat: 32.2, Long: -86.1},
+		vcard.Property{Name: "URL", Value: "http://example.com/jenny"},
+		vcard.Property{Name: "CATEGORIES", Value: "friend,runner"},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[0].Fields)
+	}
+
+	expected = []vcard.FieldFormatter{vcard.FN{"Bubba Blue"}, vcard.Property{Name: "URL", Value: "https://example.com/bubba"}}
+	if !reflect.DeepEqual(cards[1].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[1].Fields)
+	}
+	if fn := cards[2].Fields[0]; fn != (vcard.FN{"Empty"}) {
+		t.Fatalf("expected the organization as name, but got %v", fn)
+	}
+}
+
+func TestParseHCardErrors(t *testing.T) {
+	in := `<div class="h-card"><span class="p-name">Forrest Gump</span> <a class="u-email" href="mailto:not an address">mail</a></div>
+	<div class="h-card"><span class="p-name">Jenny Curran</span></div>`
+
+	cards, err := vcard.ParseHCard(strings.NewReader(in))
+	errs, ok := err.(vcard.HCardErrors)
+	if !ok || len(errs) != 1 || errs[0].Card != 1 {
+		t.Fatalf("expected an error for the first card, but got: %v", err)
+	}
+	if len(cards) != 1 || cards[0].Fields[0] != (vcard.FN{"Jenny Curran"}) {
+		t.Fatalf("expected the valid card, but got %v", cards)
+	}
+}
@@ -0,0 +1,188 @@
+package vcard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// representation is a media type a VCard can be served as
+type representation struct {
+	mediaType   string
+	contentType string
+	extension   string
+	marshal     func(v *VCard) ([]byte, error)
+}
+
+// representations contains the media types supported by Serve, the first one is the default
+var representations = []representation{
+	{"text/vcard", "text/vcard; charset=utf-8", ".vcf", func(v *VCard) ([]byte, error) {
+		s, err := v.Generate()
+		return []byte(s), err
+	}},
+	{"application/vcard+json", "application/vcard+json", ".json", (*VCard).JCard},
+	{"application/vcard+xml", "application/vcard+xml; charset=utf-8", ".xml", (*VCard).XCard},
+}
+
+// Handler returns an http.Handler serving the VCard as a download, see Serve
+func Handler(v *VCard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, v)
+	})
+}
+
+// Serve writes the VCard as a download. The representation is negotiated through the Accept header,
+// vCard is served unless jCard (application/vcard+json) or xCard (application/vcard+xml) is preferred.
+// The filename is taken from FN, the ETag is a hash of the served content and Last-Modified is taken
+// from REV. Conditional requests with If-None-Match and If-Modified-Since are answered with 304 Not Modified.
+func Serve(w http.ResponseWriter, r *http.Request, v *VCard) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
+
+	body, err := rep.marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	etag := ETag(body)
+	h := w.Header()
+	h.Set("ETag", etag)
+	if rev, ok := v.rev(); ok {
+		h.Set("Last-Modified", rev.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, v) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", rep.contentType)
+	h.Set("Content-Disposition", contentDisposition(v, rep.extension))
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// rev returns the timestamp of the REV field, if any
+func (v *VCard) rev() (time.Time, bool) {
+	for i := range v.Fields {
+		if rev, ok := v.Fields[i].(Rev); ok {
+			return rev.Timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// notModified evaluates the conditional headers of a request, If-None-Match takes precedence
+func notModified(r *http.Request, etag string, v *VCard) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	rev, ok := v.rev()
+	return ok && !rev.Truncate(time.Second).After(ims)
+}
+
+// negotiate picks the representation with the highest quality from an Accept header
+func negotiate(accept string) (representation, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return representations[0], true
+	}
+
+	var (
+		best  representation
+		bestQ float64
+	)
+	for _, rep := range representations {
+		if q := quality(accept, rep.mediaType); q > bestQ {
+			best, bestQ = rep, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// quality returns the quality value of the most specific Accept range matching the media type
+func quality(accept, mediaType string) float64 {
+	var (
+		q           float64
+		specificity = -1
+	)
+	for _, rng := range strings.Split(accept, ",") {
+		parts := strings.Split(rng, ";")
+		mr := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		s := -1
+		switch {
+		case mr == mediaType:
+			s = 2
+		case mr == "*/*":
+			s = 0
+		case strings.HasSuffix(mr, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mr, "*")):
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity, q = s, 1
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q
+}
+
+// contentDisposition returns an attachment disposition with a filename derived from FN,
+// with an ASCII fallback and the full name as RFC 5987 extended value
+func contentDisposition(v *VCard, ext string) string {
+	name := "contact"
+	for i := range v.Fields {
+		if fn, ok := v.Fields[i].(FN); ok && strings.TrimSpace(fn.FormattedName) != "" {
+			name = strings.TrimSpace(fn.FormattedName)
+			break
+		}
+	}
+
+	ascii := strings.Map(func(r rune) rune {
+		switch {
+		case r > unicode.MaxASCII, r < ' ', strings.ContainsRune(`"\/:*?<>|;%`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	return fmt.Sprintf(`attachment; filename="%s%s"; filename*=UTF-8''%s%s`,
+		ascii, ext, strings.Replace(url.QueryEscape(name), "+", "%20", -1), ext)
+}
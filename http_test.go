@@ -0,0 +1,156 @@
+package vcard_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func newServeCard(t *testing.T) *vcard.VCard {
+	t.Helper()
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.FN{"Forrest Gümp/\"Jr\""},
+		vcard.Rev{Timestamp: time.Date(2008, 4, 24, 19, 52, 43, 0, time.UTC)},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return card
+}
+
+func TestServe(t *testing.T) {
+	card := newServeCard(t)
+	srv := httptest.NewServer(vcard.Handler(card))
+	defer srv.Close()
+
+	expected, _ := card.Generate()
+	tt := []struct {
+		accept      string
+		status      int
+		contentType string
+		disposition string
+		body        string
+	}{
+		{
+			status:      http.StatusOK,
+			contentType: "text/vcard; charset=utf-8",
+			disposition: `attachment; filename="Forrest G_mp__Jr_.vcf"; filename*=UTF-8''Forrest%20G%C3%BCmp%2F%22Jr%22.vcf`,
+			body:        expected,
+		},
+		{
+			accept:      "application/vcard+json, text/vcard;q=0.5",
+			status:      http.StatusOK,
+			contentType: "application/vcard+json",
+			disposition: `attachment; filename="Forrest G_mp__Jr_.json"; filename*=UTF-8''Forrest%20G%C3%BCmp%2F%22Jr%22.json`,
+			body:        `["vcard"`,
+		},
+		{
+			accept:      "application/*;q=0.9, text/vcard;q=0.1",
+			status:      http.StatusOK,
+			contentType: "application/vcard+json",
+			body:        `["vcard"`,
+		},
+		{
+			accept:      "application/vcard+xml",
+			status:      http.StatusOK,
+			contentType: "application/vcard+xml; charset=utf-8",
+			body:        `<?xml`,
+		},
+		{
+			accept:      "*/*",
+			status:      http.StatusOK,
+			contentType: "text/vcard; charset=utf-8",
+			body:        expected,
+		},
+		{
+			accept: "image/png, text/vcard;q=0",
+			status: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, tc := range tt {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(resp.Body); err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.status {
+			t.Fatalf("accept %q: expected status %d, but got %d", tc.accept, tc.status, resp.StatusCode)
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); got != tc.contentType {
+			t.Fatalf("accept %q: expected content type %q, but got %q", tc.accept, tc.contentType, got)
+		}
+		if got := resp.Header.Get("Content-Disposition"); tc.disposition != "" && got != tc.disposition {
+			t.Fatalf("accept %q: expected disposition %q, but got %q", tc.accept, tc.disposition, got)
+		}
+		if !strings.HasPrefix(body.String(), tc.body) {
+			t.Fatalf("accept %q: expected body starting with %q, but got %q", tc.accept, tc.body, body.String())
+		}
+		if got, expected := resp.Header.Get("ETag"), vcard.ETag(body.Bytes()); got != expected {
+			t.Fatalf("accept %q: expected ETag %q, but got %q", tc.accept, expected, got)
+		}
+	}
+}
+
+func TestServeConditional(t *testing.T) {
+	card := newServeCard(t)
+	h := vcard.Handler(card)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := rec.Header().Get("ETag")
+	if expected := "Thu, 24 Apr 2008 19:52:43 GMT"; rec.Header().Get("Last-Modified") != expected {
+		t.Fatalf("expected Last-Modified %q, but got %q", expected, rec.Header().Get("Last-Modified"))
+	}
+
+	tt := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"If-None-Match", "*", http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", "Thu, 24 Apr 2008 19:52:43 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Wed, 23 Apr 2008 19:52:43 GMT", http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%s %q: expected status %d, but got %d", tc.header, tc.value, tc.status, rec.Code)
+		}
+		if tc.status == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Fatalf("%s %q: expected an empty body, but got %q", tc.header, tc.value, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, but got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
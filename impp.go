@@ -0,0 +1,119 @@
+package vcard
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// IMPPPlatform describes an instant messaging platform and how its handles are written as URI
+type IMPPPlatform struct {
+	// Name is the display name of the platform, such as "Skype"
+	Name string
+	// Scheme is the URI scheme of the platform
+	Scheme string
+	// Aliases are other names the platform is known by, matched case insensitively
+	Aliases []string
+	// Property is the extension property used instead of IMPP by version 2.1, such as X-SKYPE
+	Property string
+	// Handle matches the valid handles, nil accepts any handle without white space
+	Handle *regexp.Regexp
+	// Query is the part of URIs such as aim:goim?screenname=handle which precedes the handle, the
+	// handle is written in the query if it is set
+	Query string
+}
+
+// IMPPPlatforms is the registry of instant messaging platforms, it can be extended with other
+// platforms. Platforms which aren't registered are written with their name as scheme, they pass
+// validation if the name is a valid URI scheme.
+var IMPPPlatforms = []IMPPPlatform{
+	{Name: "XMPP", Scheme: "xmpp", Aliases: []string{"Jabber", "Google Talk", "GTalk"}, Property: "X-JABBER", Handle: regexp.MustCompile(`^[^@/\s]+@[^@/\s]+(/\S*)?$`)},
+	{Name: "SIP", Scheme: "sip", Aliases: []string{"sips"}, Property: "X-SIP", Handle: regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)},
+	{Name: "Skype", Scheme: "skype", Property: "X-SKYPE", Handle: regexp.MustCompile(`^(live:[\w.-]+|[A-Za-z][\w.,:-]{5,31})$`)},
+	{Name: "AIM", Scheme: "aim", Property: "X-AIM", Handle: regexp.MustCompile(`^[A-Za-z][\w.@]{2,}$`), Query: "goim?screenname="},
+	{Name: "MSN", Scheme: "msnim", Aliases: []string{"Windows Live", "Live Messenger"}, Property: "X-MSN", Handle: regexp.MustCompile(`^[^@\s]+@[^@\s]+$`), Query: "chat?contact="},
+	{Name: "Yahoo", Scheme: "ymsgr", Aliases: []string{"Yahoo Messenger"}, Property: "X-YAHOO", Query: "sendIM?"},
+	{Name: "ICQ", Scheme: "icq", Property: "X-ICQ", Handle: regexp.MustCompile(`^\d{5,10}$`)},
+	{Name: "QQ", Scheme: "qq", Property: "X-QQ", Handle: regexp.MustCompile(`^\d{5,12}$`)},
+	{Name: "Gadu-Gadu", Scheme: "gg", Aliases: []string{"GaduGadu"}, Property: "X-GADUGADU", Handle: regexp.MustCompile(`^\d+$`)},
+	{Name: "IRC", Scheme: "irc", Aliases: []string{"ircs"}, Property: "X-IRC"},
+	{Name: "WhatsApp", Scheme: "whatsapp", Property: "X-WHATSAPP", Handle: regexp.MustCompile(`^\+?\d{6,15}$`)},
+	{Name: "Telegram", Scheme: "tg", Property: "X-TELEGRAM", Handle: regexp.MustCompile(`^@?[A-Za-z]\w{4,31}$`), Query: "//resolve?domain="},
+	{Name: "Matrix", Scheme: "matrix", Handle: regexp.MustCompile(`^[@#!]?[^:\s]+:\S+$`)},
+	{Name: "FaceTime", Scheme: "facetime"},
+	{Name: "IM", Scheme: "im", Handle: regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)},
+}
+
+// LookupIMPP returns the platform with the name, scheme, alias or 2.1 property, matched case
+// insensitively, reporting whether it is registered
+func LookupIMPP(name string) (IMPPPlatform, bool) {
+	name = strings.TrimSpace(name)
+	for _, p := range IMPPPlatforms {
+		if strings.EqualFold(p.Name, name) || strings.EqualFold(p.Scheme, name) || p.Property != "" && strings.EqualFold(p.Property, name) {
+			return p, true
+		}
+		for _, a := range p.Aliases {
+			if strings.EqualFold(a, name) {
+				return p, true
+			}
+		}
+	}
+	return IMPPPlatform{}, false
+}
+
+// scheme returns the URI scheme of the platform of the IMPP
+func (f IMPP) scheme() string {
+	if p, ok := LookupIMPP(f.Platform); ok {
+		return p.Scheme
+	}
+	return strings.ToLower(f.Platform)
+}
+
+// URI returns the handle as URI with the scheme of its platform, such as xmpp:alice@example.com or
+// aim:goim?screenname=alice for platforms which pass the handle in a query
+func (f IMPP) URI() string {
+	if p, ok := LookupIMPP(f.Platform); ok && p.Query != "" {
+		return p.Scheme + ":" + p.Query + url.QueryEscape(f.Handle)
+	}
+	return f.scheme() + ":" + f.Handle
+}
+
+// ParseIMPP splits an IMPP URI into its platform, which is the scheme, and handle. The handle is
+// taken from the query of URIs such as aim:goim?screenname=alice.
+func ParseIMPP(uri string) (IMPP, error) {
+	i := strings.IndexByte(uri, ':')
+	if i < 1 {
+		return IMPP{}, fmt.Errorf("invalid IMPP URI %q", uri)
+	}
+	f := IMPP{Platform: strings.ToLower(uri[:i]), Handle: strings.TrimPrefix(uri[i+1:], "//")}
+	if p, ok := LookupIMPP(f.Platform); ok && p.Query != "" && strings.HasPrefix(f.Handle, strings.TrimPrefix(p.Query, "//")) {
+		f.Handle = f.Handle[len(strings.TrimPrefix(p.Query, "//")):]
+		if j := strings.IndexByte(f.Handle, '&'); j >= 0 {
+			f.Handle = f.Handle[:j]
+		}
+		if h, err := url.QueryUnescape(f.Handle); err == nil {
+			f.Handle = h
+		}
+	}
+	// actions such as skype:alice?chat aren't part of the handle
+	if j := strings.IndexByte(f.Handle, '?'); j >= 0 {
+		f.Handle = f.Handle[:j]
+	}
+	if f.Handle == "" {
+		return IMPP{}, fmt.Errorf("invalid IMPP URI %q", uri)
+	}
+	return f, nil
+}
+
+// imppProperty returns the IMPP of an extension property of version 2.1 such as X-SKYPE
+func imppProperty(p Property) (IMPP, bool) {
+	if !strings.HasPrefix(p.Name, "X-") || p.Group != "" {
+		return IMPP{}, false
+	}
+	platform, ok := LookupIMPP(p.Name)
+	if !ok || p.Value == "" {
+		return IMPP{}, false
+	}
+	return IMPP{Types: types(p.Params), Platform: platform.Scheme, Handle: p.Value}, true
+}
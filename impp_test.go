@@ -0,0 +1,174 @@
+package vcard_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestLookupIMPP(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+	}{
+		{"Skype", "skype"},
+		{"jabber", "xmpp"},
+		{"Google Talk", "xmpp"},
+		{"WhatsApp", "whatsapp"},
+		{"Telegram", "tg"},
+		{"msnim", "msnim"},
+		{"Yahoo", "ymsgr"},
+		{"X-ICQ", "icq"},
+	}
+	for _, test := range tests {
+		p, ok := vcard.LookupIMPP(test.name)
+		if !ok {
+			t.Fatalf("expected %q to be registered", test.name)
+		}
+		if p.Scheme != test.scheme {
+			t.Fatalf("expected %q, but got %q", test.scheme, p.Scheme)
+		}
+	}
+	if _, ok := vcard.LookupIMPP("carrier pigeon"); ok {
+		t.Fatalf("expected unknown platforms not to be registered")
+	}
+}
+
+func TestParseIMPP(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected vcard.IMPP
+	}{
+		{"xmpp:forrest@example.com", vcard.IMPP{Platform: "xmpp", Handle: "forrest@example.com"}},
+		{"SIP:forrest@example.com", vcard.IMPP{Platform: "sip", Handle: "forrest@example.com"}},
+		{"skype:forrest.gump?chat", vcard.IMPP{Platform: "skype", Handle: "forrest.gump"}},
+		{"aim:goim?screenname=ForrestGump&message=hi", vcard.IMPP{Platform: "aim", Handle: "ForrestGump"}},
+		{"msnim:chat?contact=forrest%40example.com", vcard.IMPP{Platform: "msnim", Handle: "forrest@example.com"}},
+		{"ymsgr:sendIM?forrestgump", vcard.IMPP{Platform: "ymsgr", Handle: "forrestgump"}},
+		{"irc://irc.example.com/forrest", vcard.IMPP{Platform: "irc", Handle: "irc.example.com/forrest"}},
+	}
+	for _, test := range tests {
+		got, err := vcard.ParseIMPP(test.uri)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got.Platform != test.expected.Platform || got.Handle != test.expected.Handle {
+			t.Fatalf("expected %+v, but got %+v", test.expected, got)
+		}
+	}
+
+	for _, uri := range []string{"", "forrest", ":forrest", "aim:goim?screenname="} {
+		if _, err := vcard.ParseIMPP(uri); err == nil {
+			t.Fatalf("expected %q to fail", uri)
+		}
+	}
+}
+
+func TestIMPPFormat(t *testing.T) {
+	f := vcard.IMPP{Types: []string{vcard.IMPPWork, vcard.IMPPPref}, Platform: "Skype", Handle: "forrest.gump"}
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"2.1", "X-SKYPE;TYPE=work,pref:forrest.gump"},
+		{"3.0", "IMPP;TYPE=work,pref:skype:forrest.gump"},
+		{"4.0", "IMPP;PREF=1;TYPE=work:skype:forrest.gump"},
+	}
+	for _, test := range tests {
+		got, err := f.Format(test.version)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+	}
+}
+
+func TestIMPPValidate(t *testing.T) {
+	tests := []struct {
+		field vcard.IMPP
+		valid bool
+	}{
+		{vcard.IMPP{Platform: "Jabber", Handle: "forrest@example.com/mobile"}, true},
+		{vcard.IMPP{Platform: "Jabber", Handle: "forrest"}, false},
+		{vcard.IMPP{Platform: "Skype", Handle: "live:forrest_1"}, true},
+		{vcard.IMPP{Platform: "Skype", Handle: "fg"}, false},
+		{vcard.IMPP{Platform: "ICQ", Handle: "12345678"}, true},
+		{vcard.IMPP{Platform: "ICQ", Handle: "forrest"}, false},
+		{vcard.IMPP{Platform: "WhatsApp", Handle: "+14045551212"}, true},
+		{vcard.IMPP{Platform: "carrier pigeon", Handle: "forrest"}, false},
+		{vcard.IMPP{Platform: "x-apple", Handle: "forrest@example.com"}, true},
+	}
+	for _, test := range tests {
+		if err := test.field.Validate(); (err == nil) != test.valid {
+			t.Fatalf("expected %+v to be valid %t, but got: %v", test.field, test.valid, err)
+		}
+	}
+
+	f, err := vcard.IMPP{Platform: "Google Talk", Handle: "forrest@Example.COM"}.Normalize()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if f.URI() != "xmpp:forrest@example.com" {
+		t.Fatalf("expected %q, but got %q", "xmpp:forrest@example.com", f.URI())
+	}
+}
+
+func TestDecodeIMPPFallback(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:2.1\nFN:Forrest Gump\nX-SKYPE;HOME:forrest.gump\nX-JABBER:forrest@example.com\nEND:VCARD\n"
+	card, err := vcard.NewDecoder(strings.NewReader(input)).Decode()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	var impp []vcard.IMPP
+	for _, f := range card.Fields {
+		if i, ok := f.(vcard.IMPP); ok {
+			impp = append(impp, i)
+		}
+	}
+	if len(impp) != 2 || impp[0].URI() != "skype:forrest.gump" || impp[1].URI() != "xmpp:forrest@example.com" {
+		t.Fatalf("expected the Skype and Jabber handles, but got %+v", impp)
+	}
+
+	card.Version = "4.0"
+	got, err := card.Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := "BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nIMPP;TYPE=home:skype:forrest.gump\nIMPP:xmpp:forrest@example.com\nEND:VCARD"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+}
+
+func TestIMPPURI(t *testing.T) {
+	tests := []struct {
+		field    vcard.IMPP
+		expected string
+	}{
+		{vcard.IMPP{Platform: "Jabber", Handle: "forrest@example.com"}, "xmpp:forrest@example.com"},
+		{vcard.IMPP{Platform: "AIM", Handle: "ForrestGump"}, "aim:goim?screenname=ForrestGump"},
+		{vcard.IMPP{Platform: "Telegram", Handle: "forrestgump"}, "tg://resolve?domain=forrestgump"},
+		{vcard.IMPP{Platform: "MSN", Handle: "forrest@example.com"}, "msnim:chat?contact=forrest%40example.com"},
+		{vcard.IMPP{Platform: "signal", Handle: "+123456789"}, "signal:+123456789"},
+	}
+	for _, test := range tests {
+		got := test.field.URI()
+		if got != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, got)
+		}
+		parsed, err := vcard.ParseIMPP(got)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if parsed.Handle != test.field.Handle || parsed.URI() != got {
+			t.Fatalf("expected %+v to round trip, but got %+v", test.field, parsed)
+		}
+	}
+
+	if _, err := vcard.New("4.0", vcard.FN{"Forrest Gump"}, vcard.IMPP{Platform: "signal", Handle: "+123456789"}); err != nil {
+		t.Fatalf("expected unregistered platforms to pass, but got: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package vcard
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// structuredProperties contains the properties whose value consists of semicolon separated components
+var structuredProperties = map[string]bool{
+	"N":      true,
+	"ADR":    true,
+	"ORG":    true,
+	"GENDER": true,
+}
+
+// multiValueProperties contains the properties whose value is a comma separated list
+var multiValueProperties = map[string]bool{
+	"CATEGORIES": true,
+	"NICKNAME":   true,
+}
+
+// valueTypes contains the default value type of properties which aren't text
+var valueTypes = map[string]string{
+	"PHOTO":       "uri",
+	"LOGO":        "uri",
+	"SOUND":       "uri",
+	"KEY":         "uri",
+	"FBURL":       "uri",
+	"CALURI":      "uri",
+	"CALADRURI":   "uri",
+	"GEO":         "uri",
+	"IMPP":        "uri",
+	"URL":         "uri",
+	"SOURCE":      "uri",
+	"MEMBER":      "uri",
+	"RELATED":     "uri",
+	"BDAY":        "date-and-or-time",
+	"ANNIVERSARY": "date-and-or-time",
+	"REV":         "timestamp",
+}
+
+// valueType returns the value type of a property and removes the VALUE parameter
+func valueType(p Property) string {
+	t := strings.ToLower(p.Params.Get("VALUE"))
+	delete(p.Params, "VALUE")
+	if t != "" {
+		return t
+	}
+	if t, ok := valueTypes[p.Name]; ok {
+		return t
+	}
+	return "text"
+}
+
+// values returns the value of a property as a list of components, structured
+// values are returned as one list of components
+func values(p Property, typ string) []interface{} {
+	switch {
+	case structuredProperties[p.Name]:
+		c := splitComponents(p.Value, ';')
+		if len(c) == 1 && p.Name != "N" && p.Name != "ADR" {
+			return []interface{}{c[0]}
+		}
+		comps := make([]interface{}, len(c))
+		for i := range c {
+			comps[i] = c[i]
+		}
+		return []interface{}{comps}
+	case multiValueProperties[p.Name]:
+		c := splitComponents(p.Value, ',')
+		vals := make([]interface{}, len(c))
+		for i := range c {
+			vals[i] = c[i]
+		}
+		return vals
+	case typ == "date-and-or-time" || typ == "timestamp":
+		return []interface{}{extendedTime(p.Value)}
+	case typ == "text":
+		return []interface{}{unescapeText(p.Value)}
+	}
+	return []interface{}{p.Value}
+}
+
+// extendedTime rewrites a basic format date or timestamp into the extended format used by jCard and xCard
+func extendedTime(s string) string {
+	for _, format := range timeFormats {
+		t, err := time.Parse(format, s)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(format, "T") {
+			return t.Format(time.RFC3339)
+		}
+		return t.Format("2006-01-02")
+	}
+	return s
+}
+
+// jcardProperty returns the jCard array of a property
+func jcardProperty(p Property) []interface{} {
+	typ := valueType(p)
+	params := make(map[string]interface{}, len(p.Params))
+	for k, vals := range p.Params {
+		if len(vals) == 1 {
+			params[strings.ToLower(k)] = vals[0]
+			continue
+		}
+		params[strings.ToLower(k)] = vals
+	}
+	if p.Group != "" {
+		params["group"] = p.Group
+	}
+
+	line := []interface{}{strings.ToLower(p.Name), params, typ}
+	return append(line, values(p, typ)...)
+}
+
+// JCard returns the jCard (RFC 7095) representation of the VCard. As jCard is
+// based upon vCard 4.0, all fields are formatted as version 4.0.
+func (v *VCard) JCard() ([]byte, error) {
+	props, err := v.properties("4.0")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []interface{}{
+		[]interface{}{"version", map[string]interface{}{}, "text", "4.0"},
+	}
+	for _, p := range props {
+		lines = append(lines, jcardProperty(p))
+	}
+
+	return json.Marshal([]interface{}{"vcard", lines})
+}
+
+// jcardValue returns a single jCard value as a property value
+func jcardValue(v interface{}, typ string) string {
+	switch v := v.(type) {
+	case string:
+		if typ == "text" {
+			return escapeText(v)
+		}
+		return v
+	case []interface{}:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = jcardValue(v[i], typ)
+		}
+		return strings.Join(s, ",")
+	case bool:
+		return strings.ToUpper(fmt.Sprint(v))
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// parseJCardProperty turns a jCard array as returned by jcardProperty back into a property
+func parseJCardProperty(line []interface{}) (Property, error) {
+	if len(line) < 4 {
+		return Property{}, fmt.Errorf("jCard property %v needs a name, parameters, type and value", line)
+	}
+	name, ok := line[0].(string)
+	params, pok := line[1].(map[string]interface{})
+	typ, tok := line[2].(string)
+	if !ok || !pok || !tok || name == "" {
+		return Property{}, fmt.Errorf("invalid jCard property %v", line)
+	}
+
+	p := Property{Name: strings.ToUpper(name), Params: Params{}}
+	for k, v := range params {
+		if k == "group" {
+			p.Group = fmt.Sprint(v)
+			continue
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			for _, s := range v {
+				p.Params.Add(k, fmt.Sprint(s))
+			}
+		default:
+			p.Params.Add(k, fmt.Sprint(v))
+		}
+	}
+	def, ok := valueTypes[p.Name]
+	if !ok {
+		def = "text"
+	}
+	if typ != def && typ != "unknown" {
+		p.Params.Add("VALUE", typ)
+	}
+
+	vals := line[3:]
+	switch {
+	case len(vals) == 1 && structuredProperties[p.Name]:
+		c, ok := vals[0].([]interface{})
+		if !ok {
+			c = vals
+		}
+		s := make([]string, len(c))
+		for i := range c {
+			s[i] = jcardValue(c[i], typ)
+		}
+		p.Value = strings.Join(s, ";")
+	default:
+		s := make([]string, len(vals))
+		for i := range vals {
+			s[i] = jcardValue(vals[i], typ)
+		}
+		p.Value = strings.Join(s, ",")
+	}
+	return p, nil
+}
@@ -0,0 +1,47 @@
+package vcard_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestJCard(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Person", GivenName: "Test"},
+		vcard.FN{"Test Person"},
+		vcard.Tel{Number: "+3701234567890", Types: []string{vcard.TelWork, vcard.TelVoice}},
+		vcard.Org{Name: "UAB Test Company"},
+		vcard.Bday{Timestamp: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC)},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	got, err := card.JCard()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected := `["vcard",[["version",{},"text","4.0"],` +
+		`["n",{},"text",["Person","Test","","",""]],` +
+		`["fn",{},"text","Test Person"],` +
+		`["tel",{"type":["work","voice"]},"uri","tel:+3701234567890"],` +
+		`["org",{},"text","UAB Test Company"],` +
+		`["bday",{},"date-and-or-time","1980-01-02"]]]`
+	if string(got) != expected {
+		t.Fatalf("expected %s, but got %s", expected, got)
+	}
+}
+
+func TestJCardUnsupportedField(t *testing.T) {
+	card := vcard.VCard{
+		Version: "3.0",
+		Fields:  []vcard.FieldFormatter{vcard.FN{"Test Person"}, legacyField{}},
+	}
+
+	if _, err := card.JCard(); err == nil {
+		t.Fatalf("expected an error for a field unsupported by 4.0, but got none")
+	}
+}
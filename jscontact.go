@@ -0,0 +1,745 @@
+package vcard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsCard is a JSContact (RFC 9553) Card
+type jsCard struct {
+	Type           string                     `json:"@type"`
+	Version        string                     `json:"version"`
+	UID            string                     `json:"uid"`
+	Kind           string                     `json:"kind,omitempty"`
+	Updated        string                     `json:"updated,omitempty"`
+	Name           *jsName                    `json:"name,omitempty"`
+	Nicknames      map[string]jsNickname      `json:"nicknames,omitempty"`
+	Organizations  map[string]jsOrganization  `json:"organizations,omitempty"`
+	Titles         map[string]jsTitle         `json:"titles,omitempty"`
+	Emails         map[string]jsEmail         `json:"emails,omitempty"`
+	OnlineServices map[string]jsOnlineService `json:"onlineServices,omitempty"`
+	Phones         map[string]jsPhone         `json:"phones,omitempty"`
+	Links          map[string]jsResource      `json:"links,omitempty"`
+	Media          map[string]jsResource      `json:"media,omitempty"`
+	Calendars      map[string]jsResource      `json:"calendars,omitempty"`
+	CryptoKeys     map[string]jsResource      `json:"cryptoKeys,omitempty"`
+	Addresses      map[string]jsAddress       `json:"addresses,omitempty"`
+	Anniversaries  map[string]jsAnniversary   `json:"anniversaries,omitempty"`
+	Keywords       map[string]bool            `json:"keywords,omitempty"`
+	Notes          map[string]jsNote          `json:"notes,omitempty"`
+	VCardProps     [][]interface{}            `json:"vCardProps,omitempty"`
+}
+
+type jsComponent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type jsName struct {
+	Type       string        `json:"@type"`
+	Components []jsComponent `json:"components,omitempty"`
+	Full       string        `json:"full,omitempty"`
+}
+
+type jsNickname struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsOrgUnit struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsOrganization struct {
+	Type  string      `json:"@type"`
+	Name  string      `json:"name,omitempty"`
+	Units []jsOrgUnit `json:"units,omitempty"`
+}
+
+type jsTitle struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// jsParams holds the contexts, preference and the vCard TYPE values without a JSContact
+// equivalent, which are kept in the vCardParams extension
+type jsParams struct {
+	Contexts    map[string]bool     `json:"contexts,omitempty"`
+	Pref        int                 `json:"pref,omitempty"`
+	VCardParams map[string][]string `json:"vCardParams,omitempty"`
+}
+
+type jsEmail struct {
+	Type    string `json:"@type"`
+	Address string `json:"address"`
+	jsParams
+}
+
+type jsPhone struct {
+	Type     string          `json:"@type"`
+	Number   string          `json:"number"`
+	Features map[string]bool `json:"features,omitempty"`
+	jsParams
+}
+
+type jsOnlineService struct {
+	Type    string `json:"@type"`
+	Service string `json:"service,omitempty"`
+	URI     string `json:"uri,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// jsResource is a Link, Media, Calendar or CryptoKey
+type jsResource struct {
+	Type      string `json:"@type"`
+	Kind      string `json:"kind,omitempty"`
+	URI       string `json:"uri"`
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+type jsAddress struct {
+	Type        string        `json:"@type"`
+	Components  []jsComponent `json:"components,omitempty"`
+	Full        string        `json:"full,omitempty"`
+	CountryCode string        `json:"countryCode,omitempty"`
+	Coordinates string        `json:"coordinates,omitempty"`
+	TimeZone    string        `json:"timeZone,omitempty"`
+	jsParams
+}
+
+type jsDate struct {
+	Type  string `json:"@type"`
+	Year  int    `json:"year,omitempty"`
+	Month int    `json:"month,omitempty"`
+	Day   int    `json:"day,omitempty"`
+	UTC   string `json:"utc,omitempty"`
+}
+
+type jsAnniversary struct {
+	Type string `json:"@type"`
+	Kind string `json:"kind"`
+	Date jsDate `json:"date"`
+}
+
+type jsNote struct {
+	Type string `json:"@type"`
+	Note string `json:"note"`
+}
+
+// nameComponents maps the components of N onto JSContact name component kinds
+var nameComponents = []string{"surname", "given", "given2", "title", "credential"}
+
+// adrComponents maps the components of ADR onto JSContact address component kinds
+var adrComponents = []string{"postOfficeBox", "apartment", "name", "locality", "region", "postcode", "country"}
+
+// telFeatures maps TEL types onto JSContact phone features
+var telFeatures = map[string]string{
+	TelVoice:      "voice",
+	TelFax:        "fax",
+	TelCell:       "mobile",
+	TelVideo:      "video",
+	TelPager:      "pager",
+	"text":        "text",
+	"textphone":   "textphone",
+	"main-number": "main-number",
+}
+
+// contexts maps TYPE values onto JSContact contexts
+var contexts = map[string]string{
+	TelHome: "private",
+	TelWork: "work",
+}
+
+// jsParamsOf splits the types of a field into contexts, preference and vCardParams, the
+// types found in features are returned as features instead
+func jsParamsOf(types []string, features map[string]string) (jsParams, map[string]bool) {
+	var (
+		p     jsParams
+		feats map[string]bool
+		rest  []string
+	)
+	for _, t := range types {
+		t = strings.ToLower(t)
+		switch {
+		case t == TelPref:
+			p.Pref = 1
+		case contexts[t] != "":
+			if p.Contexts == nil {
+				p.Contexts = make(map[string]bool)
+			}
+			p.Contexts[contexts[t]] = true
+		case features[t] != "":
+			if feats == nil {
+				feats = make(map[string]bool)
+			}
+			feats[features[t]] = true
+		default:
+			rest = append(rest, t)
+		}
+	}
+	if len(rest) > 0 {
+		p.VCardParams = map[string][]string{"type": rest}
+	}
+	return p, feats
+}
+
+// types reverses jsParamsOf
+func (p jsParams) types(feats map[string]bool, features map[string]string) []string {
+	var t []string
+	for _, k := range []string{TelHome, TelWork} {
+		if p.Contexts[contexts[k]] {
+			t = append(t, k)
+		}
+	}
+	var f []string
+	for typ, feat := range features {
+		if feats[feat] {
+			f = append(f, typ)
+		}
+	}
+	sort.Strings(f)
+	t = append(t, f...)
+	t = append(t, p.VCardParams["type"]...)
+	if p.Pref > 0 {
+		t = append(t, TelPref)
+	}
+	return t
+}
+
+// jsID returns the id of the n-th entry of a map with the prefix
+func jsID(prefix string, n int) string {
+	return prefix + strconv.Itoa(n+1)
+}
+
+// sortedIDs returns the keys of a map of JSContact objects in their natural order
+func sortedIDs(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.String()
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func jsDateOf(t time.Time) jsDate {
+	if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+		return jsDate{Type: "Timestamp", UTC: t.UTC().Format(time.RFC3339)}
+	}
+	return jsDate{Type: "PartialDate", Year: t.Year(), Month: int(t.Month()), Day: t.Day()}
+}
+
+// dataURI returns base64 data as a data: URI
+func dataURI(mediaType, b64 string) string {
+	return "data:" + mediaType + ";base64," + strings.Join(strings.Fields(b64), "")
+}
+
+// splitDataURI returns the media type and base64 data of a data: URI
+func splitDataURI(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "data:") {
+		return "", "", false
+	}
+	i := strings.Index(s, ";base64,")
+	if i < 0 {
+		return "", "", false
+	}
+	return s[len("data:"):i], s[i+len(";base64,"):], true
+}
+
+// jsProp appends a field without a JSContact equivalent to vCardProps
+func (c *jsCard) jsProp(f FieldFormatter, version string) error {
+	l, err := f.Format(version)
+	if err == ErrVersion {
+		l, err = f.Format("4.0")
+	}
+	if err != nil {
+		return fmt.Errorf("%T: %v", f, err)
+	}
+	p, err := parseProperty(l)
+	if err != nil {
+		return err
+	}
+	c.VCardProps = append(c.VCardProps, jcardProperty(p))
+	return nil
+}
+
+// JSContact returns the JSContact (RFC 9553) Card of the VCard, converted as described by RFC 9555.
+// Fields without a JSContact equivalent are kept in the vCardProps extension. A UID is generated
+// when the VCard has none.
+func (v *VCard) JSContact() ([]byte, error) {
+	c := jsCard{Type: "Card", Version: "1.0"}
+	name := &jsName{Type: "Name"}
+
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case FN:
+			if name.Full == "" {
+				name.Full = f.FormattedName
+				continue
+			}
+			if err := c.jsProp(f, v.Version); err != nil {
+				return nil, err
+			}
+		case N:
+			if len(name.Components) > 0 {
+				if err := c.jsProp(f, v.Version); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// the components of N are listed in the order of names in western cultures
+			values := []string{f.FamilyName, f.GivenName, f.AdditionalNames, f.HonorificPrefixes, f.HonorificSuffixes}
+			for _, i := range []int{3, 1, 2, 0, 4} {
+				for _, s := range strings.Split(values[i], ",") {
+					if s = strings.TrimSpace(s); s != "" {
+						name.Components = append(name.Components, jsComponent{Kind: nameComponents[i], Value: s})
+					}
+				}
+			}
+		case Kind:
+			c.Kind = strings.ToLower(f.Text)
+		case Rev:
+			c.Updated = f.Timestamp.UTC().Format(time.RFC3339)
+		case Org:
+			if c.Organizations == nil {
+				c.Organizations = make(map[string]jsOrganization)
+			}
+			o := jsOrganization{Type: "Organization", Name: f.Name}
+			for _, u := range f.Units {
+				o.Units = append(o.Units, jsOrgUnit{Type: "OrgUnit", Name: u})
+			}
+			c.Organizations[jsID("o", len(c.Organizations))] = o
+		case Title, Role:
+			if c.Titles == nil {
+				c.Titles = make(map[string]jsTitle)
+			}
+			t := jsTitle{Type: "Title"}
+			if r, ok := f.(Role); ok {
+				t.Name, t.Kind = r.Role, "role"
+			} else {
+				t.Name = f.(Title).Title
+			}
+			c.Titles[jsID("t", len(c.Titles))] = t
+		case Email:
+			if c.Emails == nil {
+				c.Emails = make(map[string]jsEmail)
+			}
+			p, _ := jsParamsOf(f.Types, nil)
+			c.Emails[jsID("e", len(c.Emails))] = jsEmail{Type: "EmailAddress", Address: f.Email, jsParams: p}
+		case Tel:
+			if c.Phones == nil {
+				c.Phones = make(map[string]jsPhone)
+			}
+			p, feats := jsParamsOf(f.Types, telFeatures)
+			c.Phones[jsID("p", len(c.Phones))] = jsPhone{Type: "Phone", Number: f.Number, Features: feats, jsParams: p}
+		case Adr:
+			if c.Addresses == nil {
+				c.Addresses = make(map[string]jsAddress)
+			}
+			p, _ := jsParamsOf(f.Types, nil)
+			a := jsAddress{Type: "Address", Full: f.Label, CountryCode: f.CC, Coordinates: f.Geo, TimeZone: f.TZ, jsParams: p}
+			for i, s := range []string{f.PostOfficeBox, f.ExtendedAddress, f.StreetAddress, f.Locality, f.Region, f.PostalCode, f.CountryName} {
+				if s != "" {
+					a.Components = append(a.Components, jsComponent{Kind: adrComponents[i], Value: s})
+				}
+			}
+			c.Addresses[jsID("a", len(c.Addresses))] = a
+		case Geo:
+			if c.Addresses == nil {
+				c.Addresses = make(map[string]jsAddress)
+			}
+			c.Addresses[jsID("a", len(c.Addresses))] = jsAddress{
+				Type:        "Address",
+				Coordinates: f.URI(),
+			}
+		case Bday, Anniversary:
+			if c.Anniversaries == nil {
+				c.Anniversaries = make(map[string]jsAnniversary)
+			}
+			a := jsAnniversary{Type: "Anniversary"}
+			if b, ok := f.(Bday); ok {
+				a.Kind, a.Date = "birth", jsDateOf(b.Timestamp)
+			} else {
+				a.Kind, a.Date = "wedding", jsDateOf(f.(Anniversary).Date)
+			}
+			c.Anniversaries[jsID("k", len(c.Anniversaries))] = a
+		case Photo:
+			r := jsResource{Type: "Media", Kind: "photo", MediaType: f.Type}
+			switch {
+			case f.Base64Data != "":
+				mt := f.Type
+				if !strings.Contains(mt, "/") {
+					mt = "image/" + strings.ToLower(mt)
+				}
+				r.URI, r.MediaType = dataURI(mt, f.Base64Data), mt
+			case f.URI != nil:
+				r.URI = f.URI.String()
+			default:
+				continue
+			}
+			if c.Media == nil {
+				c.Media = make(map[string]jsResource)
+			}
+			c.Media[jsID("m", len(c.Media))] = r
+		case IMPP:
+			if c.OnlineServices == nil {
+				c.OnlineServices = make(map[string]jsOnlineService)
+			}
+			c.OnlineServices[jsID("s", len(c.OnlineServices))] = jsOnlineService{
+				Type: "OnlineService",
+				URI:  f.URI(),
+			}
+		case FbURL:
+			if f.URL == nil {
+				continue
+			}
+			if c.Calendars == nil {
+				c.Calendars = make(map[string]jsResource)
+			}
+			c.Calendars[jsID("c", len(c.Calendars))] = jsResource{Type: "Calendar", Kind: "freeBusy", URI: f.URL.String()}
+		case Key:
+			r := jsResource{Type: "CryptoKey"}
+			switch {
+			case f.URI != nil:
+				r.URI = f.URI.String()
+			case f.Binary:
+				mt := f.Type
+				if !strings.Contains(mt, "/") {
+					mt = "application/" + strings.ToLower(mt)
+				}
+				r.URI, r.MediaType = dataURI(mt, f.Data), mt
+			default:
+				if err := c.jsProp(f, v.Version); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if c.CryptoKeys == nil {
+				c.CryptoKeys = make(map[string]jsResource)
+			}
+			c.CryptoKeys[jsID("k", len(c.CryptoKeys))] = r
+		case Property:
+			if !c.jsProperty(f) {
+				if err := c.jsProp(f, v.Version); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err := c.jsProp(f, v.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if name.Full != "" || len(name.Components) > 0 {
+		c.Name = name
+	}
+	if c.UID == "" {
+		uid, err := newUID()
+		if err != nil {
+			return nil, err
+		}
+		c.UID = uid
+	}
+	return json.Marshal(c)
+}
+
+// jsProperty maps a generic property onto the card, reporting whether it could be mapped
+func (c *jsCard) jsProperty(p Property) bool {
+	if p.Group != "" || len(p.Params) > 0 {
+		return false
+	}
+	switch p.Name {
+	case "UID":
+		if c.UID != "" {
+			return false
+		}
+		c.UID = p.Value
+	case "NOTE":
+		if c.Notes == nil {
+			c.Notes = make(map[string]jsNote)
+		}
+		c.Notes[jsID("n", len(c.Notes))] = jsNote{Type: "Note", Note: p.Text()}
+	case "NICKNAME":
+		if c.Nicknames == nil {
+			c.Nicknames = make(map[string]jsNickname)
+		}
+		for _, n := range splitComponents(p.Value, ',') {
+			c.Nicknames[jsID("k", len(c.Nicknames))] = jsNickname{Type: "Nickname", Name: n}
+		}
+	case "CATEGORIES":
+		if c.Keywords == nil {
+			c.Keywords = make(map[string]bool)
+		}
+		for _, k := range splitComponents(p.Value, ',') {
+			c.Keywords[k] = true
+		}
+	case "URL":
+		if c.Links == nil {
+			c.Links = make(map[string]jsResource)
+		}
+		c.Links[jsID("l", len(c.Links))] = jsResource{Type: "Link", URI: p.Value}
+	default:
+		return false
+	}
+	return true
+}
+
+// parseJSDate returns the time of a PartialDate or Timestamp
+func parseJSDate(d jsDate) (time.Time, bool) {
+	if d.UTC != "" {
+		t, err := time.Parse(time.RFC3339, d.UTC)
+		return t, err == nil
+	}
+	if d.Year == 0 || d.Month == 0 || d.Day == 0 {
+		return time.Time{}, false
+	}
+	return time.Date(d.Year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC), true
+}
+
+// ParseJSContact reads a JSContact (RFC 9553) Card as a version 4.0 VCard, converted as described
+// by RFC 9555. The vCardProps extension is restored as the fields it holds.
+func ParseJSContact(b []byte) (*VCard, error) {
+	var c jsCard
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.Type != "Card" {
+		return nil, fmt.Errorf("expected a JSContact Card, got @type %q", c.Type)
+	}
+
+	var fields []FieldFormatter
+	if c.Name != nil {
+		if c.Name.Full != "" {
+			fields = append(fields, FN{c.Name.Full})
+		}
+		if len(c.Name.Components) > 0 {
+			values := make([][]string, len(nameComponents))
+			for _, comp := range c.Name.Components {
+				switch comp.Kind {
+				case "surname", "surname2":
+					values[0] = append(values[0], comp.Value)
+				case "given":
+					values[1] = append(values[1], comp.Value)
+				case "given2":
+					values[2] = append(values[2], comp.Value)
+				case "title":
+					values[3] = append(values[3], comp.Value)
+				case "credential", "generation":
+					values[4] = append(values[4], comp.Value)
+				}
+			}
+			n := N{
+				FamilyName:        strings.Join(values[0], ","),
+				GivenName:         strings.Join(values[1], ","),
+				AdditionalNames:   strings.Join(values[2], ","),
+				HonorificPrefixes: strings.Join(values[3], ","),
+				HonorificSuffixes: strings.Join(values[4], ","),
+			}
+			if c.Name.Full == "" {
+				fields = append(fields, FN{joinNonEmpty(" ", n.HonorificPrefixes, n.GivenName, n.AdditionalNames, n.FamilyName, n.HonorificSuffixes)})
+			}
+			fields = append(fields, n)
+		}
+	}
+	if c.Kind != "" {
+		fields = append(fields, Kind{c.Kind})
+	}
+	for _, id := range sortedIDs(c.Nicknames) {
+		fields = append(fields, Property{Name: "NICKNAME", Value: escapeText(c.Nicknames[id].Name)})
+	}
+	for _, id := range sortedIDs(c.Organizations) {
+		o := c.Organizations[id]
+		org := Org{Name: o.Name}
+		for _, u := range o.Units {
+			org.Units = append(org.Units, u.Name)
+		}
+		fields = append(fields, org)
+	}
+	for _, id := range sortedIDs(c.Titles) {
+		if t := c.Titles[id]; t.Kind == "role" {
+			fields = append(fields, Role{t.Name})
+		} else {
+			fields = append(fields, Title{t.Name})
+		}
+	}
+	for _, id := range sortedIDs(c.Media) {
+		m := c.Media[id]
+		if m.Kind != "" && m.Kind != "photo" {
+			fields = append(fields, Property{Name: strings.ToUpper(m.Kind), Value: m.URI})
+			continue
+		}
+		if mt, data, ok := splitDataURI(m.URI); ok {
+			fields = append(fields, Photo{Type: mt, Base64Data: data})
+			continue
+		}
+		u, err := url.Parse(m.URI)
+		if err != nil {
+			return nil, fmt.Errorf("media %s: %v", id, err)
+		}
+		fields = append(fields, Photo{Type: m.MediaType, URI: u})
+	}
+	for _, id := range sortedIDs(c.Phones) {
+		p := c.Phones[id]
+		fields = append(fields, Tel{Types: p.types(p.Features, telFeatures), Number: strings.TrimPrefix(p.Number, "tel:")})
+	}
+	for _, id := range sortedIDs(c.Emails) {
+		e := c.Emails[id]
+		fields = append(fields, Email{Types: e.types(nil, nil), Email: e.Address})
+	}
+	for _, id := range sortedIDs(c.Addresses) {
+		a := c.Addresses[id]
+		if len(a.Components) > 0 {
+			values := make([][]string, len(adrComponents))
+			for _, comp := range a.Components {
+				switch comp.Kind {
+				case "postOfficeBox":
+					values[0] = append(values[0], comp.Value)
+				case "apartment", "room", "floor", "building":
+					values[1] = append(values[1], comp.Value)
+				case "name", "number", "block", "direction", "landmark":
+					values[2] = append(values[2], comp.Value)
+				case "locality", "district", "subdistrict":
+					values[3] = append(values[3], comp.Value)
+				case "region":
+					values[4] = append(values[4], comp.Value)
+				case "postcode":
+					values[5] = append(values[5], comp.Value)
+				case "country":
+					values[6] = append(values[6], comp.Value)
+				}
+			}
+			fields = append(fields, Adr{
+				Types:           a.types(nil, nil),
+				PostOfficeBox:   strings.Join(values[0], " "),
+				ExtendedAddress: strings.Join(values[1], " "),
+				StreetAddress:   strings.Join(values[2], " "),
+				Locality:        strings.Join(values[3], " "),
+				Region:          strings.Join(values[4], " "),
+				PostalCode:      strings.Join(values[5], " "),
+				CountryName:     strings.Join(values[6], " "),
+				Label:           a.Full,
+				Geo:             a.Coordinates,
+				TZ:              a.TimeZone,
+				CC:              a.CountryCode,
+			})
+			continue
+		}
+		if a.Coordinates != "" {
+			g, err := ParseGeo(a.Coordinates)
+			if err != nil {
+				return nil, fmt.Errorf("address %s: %v", id, err)
+			}
+			fields = append(fields, g)
+		}
+	}
+	for _, id := range sortedIDs(c.Anniversaries) {
+		a := c.Anniversaries[id]
+		t, ok := parseJSDate(a.Date)
+		if !ok {
+			return nil, fmt.Errorf("anniversary %s: invalid date", id)
+		}
+		var format string
+		if a.Date.UTC != "" {
+			format = dateTimeFormat
+		}
+		switch a.Kind {
+		case "birth":
+			fields = append(fields, Bday{Timestamp: t, TimeFormat: format})
+		case "wedding":
+			fields = append(fields, Anniversary{Date: t, TimeFormat: format})
+		case "death":
+			fields = append(fields, Property{Name: "DEATHDATE", Value: t.Format(dateFormat)})
+		}
+	}
+	for _, id := range sortedIDs(c.OnlineServices) {
+		s := c.OnlineServices[id]
+		if f, err := ParseIMPP(s.URI); err == nil {
+			fields = append(fields, f)
+			continue
+		}
+		if s.Service != "" && s.User != "" {
+			fields = append(fields, IMPP{Platform: s.Service, Handle: s.User})
+		}
+	}
+	for _, id := range sortedIDs(c.Calendars) {
+		r := c.Calendars[id]
+		if r.Kind != "freeBusy" {
+			fields = append(fields, Property{Name: "CALURI", Value: r.URI})
+			continue
+		}
+		u, err := url.Parse(r.URI)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %s: %v", id, err)
+		}
+		fields = append(fields, FbURL{u})
+	}
+	for _, id := range sortedIDs(c.CryptoKeys) {
+		r := c.CryptoKeys[id]
+		if mt, data, ok := splitDataURI(r.URI); ok {
+			fields = append(fields, Key{Type: mt, Data: data, Binary: true})
+			continue
+		}
+		u, err := url.Parse(r.URI)
+		if err != nil {
+			return nil, fmt.Errorf("crypto key %s: %v", id, err)
+		}
+		fields = append(fields, Key{Type: r.MediaType, URI: u})
+	}
+	for _, id := range sortedIDs(c.Links) {
+		fields = append(fields, Property{Name: "URL", Value: c.Links[id].URI})
+	}
+	for _, id := range sortedIDs(c.Notes) {
+		fields = append(fields, Property{Name: "NOTE", Value: escapeText(c.Notes[id].Note)})
+	}
+	if len(c.Keywords) > 0 {
+		var k []string
+		for _, id := range sortedIDs(c.Keywords) {
+			if c.Keywords[id] {
+				k = append(k, escapeText(id))
+			}
+		}
+		fields = append(fields, Property{Name: "CATEGORIES", Value: strings.Join(k, ",")})
+	}
+	if c.Updated != "" {
+		t, err := time.Parse(time.RFC3339, c.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("updated: %v", err)
+		}
+		fields = append(fields, Rev{Timestamp: t})
+	}
+	if c.UID != "" {
+		fields = append(fields, Property{Name: "UID", Value: c.UID})
+	}
+
+	for _, line := range c.VCardProps {
+		p, err := parseJCardProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		f, err := decodeField("4.0", p)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+
+	card := &VCard{Version: "4.0", Fields: fields}
+	if err := card.Validate(); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
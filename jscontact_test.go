@@ -0,0 +1,152 @@
+package vcard_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestJSContact(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest", HonorificPrefixes: "Mr."},
+		vcard.FN{"Forrest Gump"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Title{"Shrimp Man"},
+		vcard.Photo{URI: &url.URL{Scheme: "http", Host: "www.example.com", Path: "/forrest.gif"}},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelVoice, vcard.TelPref}, Number: "+1-111-555-1212"},
+		vcard.Email{Types: []string{vcard.TelHome, "internet"}, Email: "forrestgump@example.com"},
+		vcard.Adr{Types: []string{vcard.AdrHome}, StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+		vcard.Gender{"M"},
+		vcard.Property{Name: "UID", Value: "urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b1"},
+		vcard.Property{Name: "X-SHRIMP", Params: vcard.Params{"TYPE": {"fried"}}, Value: "many"},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	b, err := card.JSContact()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"uid", `"urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b1"`},
+		{"name", `{"@type":"Name","components":[{"kind":"title","value":"Mr."},{"kind":"given","value":"Forrest"},{"kind":"surname","value":"Gump"}],"full":"Forrest Gump"}`},
+		{"phones", `{"p1":{"@type":"Phone","contexts":{"work":true},"features":{"voice":true},"number":"+1-111-555-1212","pref":1}}`},
+		{"emails", `{"e1":{"@type":"EmailAddress","address":"forrestgump@example.com","contexts":{"private":true},"vCardParams":{"type":["internet"]}}}`},
+		{"addresses", `{"a1":{"@type":"Address","components":[{"kind":"name","value":"100 Waters Edge"},{"kind":"locality","value":"Baytown"},{"kind":"region","value":"LA"},{"kind":"postcode","value":"30314"},{"kind":"country","value":"United States of America"}],"contexts":{"private":true}}}`},
+		{"anniversaries", `{"k1":{"@type":"Anniversary","date":{"@type":"PartialDate","day":6,"month":6,"year":1944},"kind":"birth"}}`},
+		{"vCardProps", `[["gender",{},"text","M"],["x-shrimp",{"type":"fried"},"text","many"]]`},
+	}
+	for _, test := range tests {
+		v, err := json.Marshal(got[test.key])
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if string(v) != test.expected {
+			t.Fatalf("expected %s to be %s, but got %s", test.key, test.expected, v)
+		}
+	}
+
+	parsed, err := vcard.ParseJSContact(b)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest", HonorificPrefixes: "Mr."},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Title{"Shrimp Man"},
+		vcard.Photo{URI: &url.URL{Scheme: "http", Host: "www.example.com", Path: "/forrest.gif"}},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelVoice, vcard.TelPref}, Number: "+1-111-555-1212"},
+		vcard.Email{Types: []string{vcard.TelHome, "internet"}, Email: "forrestgump@example.com"},
+		vcard.Adr{Types: []string{vcard.AdrHome}, StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America"},
+		vcard.Bday{Timestamp: time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC)},
+		vcard.Property{Name: "UID", Value: "urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b1"},
+		vcard.Gender{"M"},
+		vcard.Property{Name: "X-SHRIMP", Params: vcard.Params{"TYPE": {"fried"}}, Value: "many"},
+	}
+	if !reflect.DeepEqual(parsed.Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, parsed.Fields)
+	}
+}
+
+func TestParseJSContact(t *testing.T) {
+	in := `{
+		"@type": "Card",
+		"version": "1.0",
+		"uid": "22B2C7DF-9120-4969-8460-05956FE6B065",
+		"name": {"@type": "Name", "components": [{"kind": "given", "value": "Jenny"}, {"kind": "surname", "value": "Curran"}]},
+		"phones": {"tel0": {"@type": "Phone", "number": "tel:+1-555-0100", "features": {"mobile": true}}},
+		"addresses": {"k1": {"@type": "Address", "coordinates": "geo:32.2,-86.1"}},
+		"anniversaries": {"k2": {"@type": "Anniversary", "kind": "wedding", "date": {"@type": "Timestamp", "utc": "1982-03-01T12:00:00Z"}}},
+		"keywords": {"friend": true, "runner": true},
+		"notes": {"n1": {"@type": "Note", "note": "Run, Forrest; run"}},
+		"updated": "2021-10-31T22:27:10Z"
+	}`
+	card, err := vcard.ParseJSContact([]byte(in))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Jenny Curran"},
+		vcard.N{FamilyName: "Curran", GivenName: "Jenny"},
+		vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1-555-0100"},
+		vcard.Geo{Lat: 32.2, Long: -86.1},
+		vcard.Anniversary{Date: time.Date(1982, 3, 1, 12, 0, 0, 0, time.UTC), TimeFormat: "20060102T150405Z0700"},
+		vcard.Property{Name: "NOTE", Value: `Run\, Forrest\; run`},
+		vcard.Property{Name: "CATEGORIES", Value: "friend,runner"},
+		vcard.Rev{Timestamp: time.Date(2021, 10, 31, 22, 27, 10, 0, time.UTC)},
+		vcard.Property{Name: "UID", Value: "22B2C7DF-9120-4969-8460-05956FE6B065"},
+	}
+	if !reflect.DeepEqual(card.Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, card.Fields)
+	}
+
+	if _, err := vcard.ParseJSContact([]byte(`{"@type":"Group"}`)); err == nil {
+		t.Fatalf("expected a group to fail")
+	}
+}
+
+func TestJSContactAddress(t *testing.T) {
+	adr := vcard.Adr{
+		Types:         []string{vcard.AdrHome},
+		StreetAddress: "42 Plantation St.",
+		Locality:      "Baytown",
+		CountryName:   "United States of America",
+		Label:         "42 Plantation St.\nBaytown\nUnited States of America",
+		Geo:           "geo:29.7,-94.9",
+		TZ:            "America/Chicago",
+		CC:            "US",
+	}
+	card, err := vcard.New("4.0", vcard.FN{"Forrest Gump"}, adr)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	b, err := card.JSContact()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	parsed, err := vcard.ParseJSContact(b)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got, _ := vcard.First[vcard.Adr](parsed); !reflect.DeepEqual(got, adr) {
+		t.Fatalf("expected %+v, but got %+v", adr, got)
+	}
+	if _, ok := vcard.First[vcard.Geo](parsed); ok {
+		t.Fatalf("expected the coordinates to stay with the address")
+	}
+}
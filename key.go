@@ -0,0 +1,266 @@
+package vcard
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// KeyPGP is the media type of an OpenPGP public key
+	KeyPGP = "application/pgp-keys"
+	// KeyX509 is the media type of an X.509 certificate
+	KeyX509 = "application/pkix-cert"
+)
+
+// keyTypes maps the key types of versions 2.1 and 3.0 to the media types of version 4.0
+var keyTypes = map[string]string{
+	"PGP":  KeyPGP,
+	"X509": KeyX509,
+}
+
+// ErrKeyType is returned when a Key doesn't hold the kind of key requested
+var ErrKeyType = errors.New("unsupported key type")
+
+// MediaType returns the media type of the key, translating the PGP and X509 types of versions 2.1
+// and 3.0
+func (f Key) MediaType() string {
+	if mt, ok := keyTypes[strings.ToUpper(f.Type)]; ok {
+		return mt
+	}
+	return strings.ToLower(f.Type)
+}
+
+// versionType returns the type of the key as written by the version
+func (f Key) versionType(v string) string {
+	if v == "4.0" {
+		if mt, ok := keyTypes[strings.ToUpper(f.Type)]; ok {
+			return mt
+		}
+		return f.Type
+	}
+	for t, mt := range keyTypes {
+		if strings.EqualFold(f.Type, mt) {
+			return t
+		}
+	}
+	return f.Type
+}
+
+// NewX509Key returns a Key embedding the certificate
+func NewX509Key(cert *x509.Certificate) Key {
+	return Key{Type: KeyX509, Data: base64.StdEncoding.EncodeToString(cert.Raw), Binary: true}
+}
+
+// NewPGPKey returns a Key embedding an armored or binary OpenPGP public key. Armored keys are
+// embedded in their binary form.
+func NewPGPKey(key []byte) (Key, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN PGP")) {
+		var err error
+		if key, err = dearmor(key); err != nil {
+			return Key{}, err
+		}
+	}
+	if _, err := pgpPublicKey(key); err != nil {
+		return Key{}, err
+	}
+	return Key{Type: KeyPGP, Data: base64.StdEncoding.EncodeToString(key), Binary: true}, nil
+}
+
+// Bytes returns the embedded key, base64 data is decoded and armored OpenPGP keys are returned in
+// their binary form. It returns an error if the key is referenced by URI.
+func (f Key) Bytes() ([]byte, error) {
+	if f.Data == "" {
+		return nil, fmt.Errorf("key isn't embedded")
+	}
+	if !f.Binary {
+		if strings.HasPrefix(strings.TrimSpace(f.Data), "-----BEGIN PGP") {
+			return dearmor([]byte(f.Data))
+		}
+		return []byte(f.Data), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(f.Data), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key data: %v", err)
+	}
+	return b, nil
+}
+
+// Certificate returns the X.509 certificate of the key
+func (f Key) Certificate() (*x509.Certificate, error) {
+	if f.MediaType() != KeyX509 {
+		return nil, ErrKeyType
+	}
+	b, err := f.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(b)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of an X.509 certificate, or the fingerprint of the
+// primary OpenPGP public key, which is SHA-1 for version 4 keys and SHA-256 for version 5 and 6 keys
+func (f Key) Fingerprint() ([]byte, error) {
+	b, err := f.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	switch f.MediaType() {
+	case KeyX509:
+		if _, err := x509.ParseCertificate(b); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		return sum[:], nil
+	case KeyPGP:
+		return pgpPublicKey(b)
+	}
+	return nil, ErrKeyType
+}
+
+// KeyID returns the key ID of an OpenPGP key, which is the last 8 bytes of the fingerprint of a
+// version 4 key and the first 8 bytes otherwise
+func (f Key) KeyID() (uint64, error) {
+	if f.MediaType() != KeyPGP {
+		return 0, ErrKeyType
+	}
+	fp, err := f.Fingerprint()
+	if err != nil {
+		return 0, err
+	}
+	if len(fp) == sha1.Size {
+		return binary.BigEndian.Uint64(fp[len(fp)-8:]), nil
+	}
+	return binary.BigEndian.Uint64(fp[:8]), nil
+}
+
+// pgpPublicKey reads the public key packet starting an OpenPGP key and returns its fingerprint
+func pgpPublicKey(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0]&0x80 == 0 {
+		return nil, fmt.Errorf("invalid OpenPGP packet")
+	}
+	var tag byte
+	var length, header int
+	if b[0]&0x40 != 0 {
+		// new format packet
+		tag = b[0] & 0x3f
+		switch l := int(b[1]); {
+		case l < 192:
+			length, header = l, 2
+		case l < 224:
+			if len(b) < 3 {
+				return nil, fmt.Errorf("invalid OpenPGP packet")
+			}
+			length, header = (l-192)<<8+int(b[2])+192, 3
+		case l == 255:
+			if len(b) < 6 {
+				return nil, fmt.Errorf("invalid OpenPGP packet")
+			}
+			length, header = int(binary.BigEndian.Uint32(b[2:6])), 6
+		default:
+			return nil, fmt.Errorf("partial OpenPGP packets aren't supported for keys")
+		}
+	} else {
+		// old format packet
+		tag = (b[0] >> 2) & 0x0f
+		switch b[0] & 0x03 {
+		case 0:
+			length, header = int(b[1]), 2
+		case 1:
+			if len(b) < 3 {
+				return nil, fmt.Errorf("invalid OpenPGP packet")
+			}
+			length, header = int(binary.BigEndian.Uint16(b[1:3])), 3
+		case 2:
+			if len(b) < 5 {
+				return nil, fmt.Errorf("invalid OpenPGP packet")
+			}
+			length, header = int(binary.BigEndian.Uint32(b[1:5])), 5
+		default:
+			return nil, fmt.Errorf("indeterminate OpenPGP packet length")
+		}
+	}
+	if tag != 6 {
+		return nil, fmt.Errorf("OpenPGP data doesn't start with a public key")
+	}
+	if length < 1 || header+length > len(b) {
+		return nil, fmt.Errorf("truncated OpenPGP public key")
+	}
+	body := b[header : header+length]
+	switch body[0] {
+	case 4:
+		h := sha1.New()
+		h.Write([]byte{0x99, byte(length >> 8), byte(length)})
+		h.Write(body)
+		return h.Sum(nil), nil
+	case 5, 6:
+		prefix := byte(0x9a)
+		if body[0] == 6 {
+			prefix = 0x9b
+		}
+		h := sha256.New()
+		h.Write([]byte{prefix})
+		binary.Write(h, binary.BigEndian, uint32(length))
+		h.Write(body)
+		return h.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("unsupported OpenPGP key version %d", body[0])
+}
+
+// crc24 computes the OpenPGP armor checksum
+func crc24(b []byte) uint32 {
+	crc := uint32(0xb704ce)
+	for _, c := range b {
+		crc ^= uint32(c) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+	return crc & 0xffffff
+}
+
+// dearmor decodes the first OpenPGP armored block, verifying its checksum if present
+func dearmor(armored []byte) ([]byte, error) {
+	s := bufio.NewScanner(bytes.NewReader(armored))
+	for s.Scan() && !strings.HasPrefix(strings.TrimSpace(s.Text()), "-----BEGIN PGP") {
+	}
+	// armor headers end at the first blank line
+	for s.Scan() && strings.TrimSpace(s.Text()) != "" {
+		if !strings.Contains(s.Text(), ": ") {
+			return nil, fmt.Errorf("invalid OpenPGP armor header %q", s.Text())
+		}
+	}
+	var data, checksum string
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "-----END PGP") {
+			b, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OpenPGP armor: %v", err)
+			}
+			if checksum != "" {
+				c, err := base64.StdEncoding.DecodeString(checksum)
+				if err != nil || len(c) != 3 || uint32(c[0])<<16|uint32(c[1])<<8|uint32(c[2]) != crc24(b) {
+					return nil, fmt.Errorf("invalid OpenPGP armor checksum")
+				}
+			}
+			return b, nil
+		}
+		if strings.HasPrefix(line, "=") && len(line) == 5 {
+			checksum = line[1:]
+			continue
+		}
+		data += line
+	}
+	return nil, fmt.Errorf("invalid OpenPGP armor")
+}
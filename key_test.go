@@ -0,0 +1,125 @@
+package vcard_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+const forrestPGP = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatTMdxYJKwYBBAHaRw8BAQdAILKRU/q6nqMkx3ujhM6LwjycGofla1qLL9XC
+kF9Fga60IkZvcnJlc3QgR3VtcCA8Zm9ycmVzdEBleGFtcGxlLmNvbT6IkAQTFggA
+OBYhBHDrZ2ty4P9wQ9PccuORQpQa/tSzBQJq1Mx3AhsDBQsJCAcCBhUKCQgLAgQW
+AgMBAh4BAheAAAoJEOORQpQa/tSzcjQA/38pXQ122WTEiBF7aHT6HyWSH1eEzKHY
+zE8MpdsORPVXAP0dmGUSr/sRxSBHoOPAm8W4xGhPuNRvw/uOM37LL2uWBQ==
+=X/Cy
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+const forrestFingerprint = "70EB676B72E0FF7043D3DC72E39142941AFED4B3"
+
+func TestPGPKey(t *testing.T) {
+	key, err := vcard.NewPGPKey([]byte(forrestPGP))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if key.MediaType() != vcard.KeyPGP || !key.Binary {
+		t.Fatalf("expected a binary %q key, but got %+v", vcard.KeyPGP, key)
+	}
+	fp, err := key.Fingerprint()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(fp)); got != forrestFingerprint {
+		t.Fatalf("expected %q, but got %q", forrestFingerprint, got)
+	}
+	if id, err := key.KeyID(); err != nil || id != 0xE39142941AFED4B3 {
+		t.Fatalf("expected key ID E39142941AFED4B3, but got %X: %v", id, err)
+	}
+
+	b, _ := key.Bytes()
+	binary, err := vcard.NewPGPKey(b)
+	if err != nil || binary.Data != key.Data {
+		t.Fatalf("expected the binary key to match the armored key, but got: %v", err)
+	}
+	armored := vcard.Key{Type: "PGP", Data: forrestPGP}
+	if fp, err := armored.Fingerprint(); err != nil || strings.ToUpper(hex.EncodeToString(fp)) != forrestFingerprint {
+		t.Fatalf("expected the fingerprint of the armored text key, but got: %v", err)
+	}
+
+	if _, err := key.Certificate(); err != vcard.ErrKeyType {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrKeyType, err)
+	}
+	for _, invalid := range []string{"", "not a key", strings.Replace(forrestPGP, "=X/Cy", "=AAAA", 1)} {
+		if _, err := vcard.NewPGPKey([]byte(invalid)); err == nil {
+			t.Fatalf("expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestX509Key(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "Forrest Gump"},
+		EmailAddresses: []string{"forrest@example.com"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	card := &vcard.VCard{Fields: []vcard.FieldFormatter{vcard.FN{"Forrest Gump"}, vcard.NewX509Key(cert)}}
+	for _, version := range []string{"2.1", "3.0", "4.0"} {
+		card.Version = version
+		s, err := card.Generate()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		expected := map[string]string{"2.1": "KEY;X509;ENCODING=BASE64:", "3.0": "KEY;TYPE=X509;ENCODING=b:", "4.0": "KEY:data:application/pkix-cert;base64,"}[version]
+		if !strings.Contains(s, expected) {
+			t.Fatalf("expected %q in %q", expected, s)
+		}
+
+		parsed, err := vcard.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		key, ok := vcard.First[vcard.Key](parsed[0])
+		if !ok {
+			t.Fatalf("expected a key in %q", s)
+		}
+		got, err := key.Certificate()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got.Subject.CommonName != "Forrest Gump" {
+			t.Fatalf("expected %q, but got %q", "Forrest Gump", got.Subject.CommonName)
+		}
+		fp, err := key.Fingerprint()
+		if sum := sha256.Sum256(der); err != nil || string(fp) != string(sum[:]) {
+			t.Fatalf("expected the SHA-256 fingerprint of the certificate, but got: %v", err)
+		}
+	}
+
+	if _, err := (vcard.Key{Type: "PGP", Data: "AAAA", Binary: true}).Certificate(); err != vcard.ErrKeyType {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrKeyType, err)
+	}
+}
@@ -0,0 +1,527 @@
+package vcard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ldifLineLength is the column at which LDIF lines are folded
+const ldifLineLength = 76
+
+// ldifObjectClasses are the object classes of the entries written for a VCard
+var ldifObjectClasses = []string{"top", "person", "organizationalPerson", "inetOrgPerson"}
+
+// ldifTel maps the LDAP telephone attributes onto the TEL types they are read as
+var ldifTel = map[string][]string{
+	"telephonenumber":          {TelWork, TelVoice},
+	"mobile":                   {TelCell},
+	"facsimiletelephonenumber": {TelFax},
+	"pager":                    {TelPager},
+	"homephone":                {TelHome, TelVoice},
+}
+
+type ldifAttr struct {
+	name  string
+	value string
+}
+
+// telAttr returns the LDAP attribute a telephone number is written as
+func telAttr(t Tel) string {
+	has := func(tp string) bool {
+		for _, v := range t.Types {
+			if strings.EqualFold(v, tp) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has(TelFax):
+		return "facsimileTelephoneNumber"
+	case has(TelCell):
+		return "mobile"
+	case has(TelPager):
+		return "pager"
+	case has(TelHome) && !has(TelWork):
+		return "homePhone"
+	}
+	return "telephoneNumber"
+}
+
+// isHome reports whether an address only has the home type
+func (f Adr) isHome() bool {
+	home := false
+	for _, t := range f.Types {
+		switch strings.ToLower(t) {
+		case AdrHome:
+			home = true
+		case AdrWork:
+			return false
+		}
+	}
+	return home
+}
+
+// postalAddress encodes lines in the LDAP postal address syntax, lines separated by $
+func postalAddress(lines ...string) string {
+	var l []string
+	for _, s := range lines {
+		for _, line := range strings.Split(s, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				l = append(l, strings.NewReplacer(`\`, `\5C`, `$`, `\24`).Replace(line))
+			}
+		}
+	}
+	return strings.Join(l, "$")
+}
+
+// postalLines decodes a value in the LDAP postal address syntax
+func postalLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "$") {
+		l = strings.NewReplacer(`\5C`, `\`, `\5c`, `\`, `\24`, `$`).Replace(strings.TrimSpace(l))
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// ldifNames returns the cn, sn and givenName of the card
+func (v *VCard) ldifNames() (string, string, string, error) {
+	var (
+		cn string
+		n  *N
+	)
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case FN:
+			if cn == "" {
+				cn = f.FormattedName
+			}
+		case N:
+			if n == nil {
+				n = &f
+			}
+		}
+	}
+
+	var sn, given string
+	if n != nil {
+		sn, given = n.FamilyName, n.GivenName
+	}
+	if cn == "" {
+		cn = strings.TrimSpace(given + " " + sn)
+	}
+	if cn == "" {
+		return "", "", "", errors.New("an FN or N field is required for an LDIF entry")
+	}
+	// sn is required by the person object class
+	if sn == "" {
+		sn = cn
+	}
+	return cn, sn, given, nil
+}
+
+func (v *VCard) ldifAttrs(dn string) ([]ldifAttr, error) {
+	cn, sn, given, err := v.ldifNames()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []ldifAttr{{"dn", dn}}
+	for _, oc := range ldifObjectClasses {
+		attrs = append(attrs, ldifAttr{"objectClass", oc})
+	}
+	attrs = append(attrs, ldifAttr{"cn", cn}, ldifAttr{"sn", sn})
+	if given != "" {
+		attrs = append(attrs, ldifAttr{"givenName", given})
+	}
+
+	var work, home bool
+	for _, f := range v.Fields {
+		switch f := f.(type) {
+		case Org:
+			attrs = append(attrs, ldifAttr{"o", f.Name})
+			for _, u := range f.Units {
+				attrs = append(attrs, ldifAttr{"ou", u})
+			}
+		case Title:
+			attrs = append(attrs, ldifAttr{"title", f.Title})
+		case Email:
+			attrs = append(attrs, ldifAttr{"mail", f.Email})
+		case Tel:
+			attrs = append(attrs, ldifAttr{telAttr(f), f.Number})
+		case Adr:
+			// only one address of each kind can be represented, as l, st etc. aren't grouped
+			switch {
+			case f.isHome() && !home:
+				home = true
+				attrs = append(attrs, ldifAttr{"homePostalAddress", postalAddress(
+					f.ExtendedAddress, f.StreetAddress, f.Locality, f.Region, f.PostalCode, f.CountryName)})
+			case !f.isHome() && !work:
+				work = true
+				attrs = append(attrs,
+					ldifAttr{"postalAddress", postalAddress(f.ExtendedAddress, f.StreetAddress)},
+					ldifAttr{"postOfficeBox", f.PostOfficeBox},
+					ldifAttr{"l", f.Locality},
+					ldifAttr{"st", f.Region},
+					ldifAttr{"postalCode", f.PostalCode},
+				)
+				// c holds an ISO 3166 code, the name goes into co unless it is the code
+				code := strings.ToUpper(strings.TrimSpace(f.CC))
+				if code == "" {
+					code, _ = addressCountry(f.CountryName)
+				}
+				attrs = append(attrs, ldifAttr{"c", code})
+				if !strings.EqualFold(strings.TrimSpace(f.CountryName), code) {
+					attrs = append(attrs, ldifAttr{"co", f.CountryName})
+				}
+			}
+		case Photo:
+			t := strings.ToLower(f.Type)
+			if f.Base64Data == "" || (t != "" && !strings.Contains(t, "jpeg") && !strings.Contains(t, "jpg")) {
+				continue
+			}
+			b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(f.Base64Data), ""))
+			if err != nil {
+				return nil, fmt.Errorf("invalid photo data: %v", err)
+			}
+			attrs = append(attrs, ldifAttr{"jpegPhoto", string(b)})
+		}
+	}
+	return attrs, nil
+}
+
+// ldifSafe reports whether a value can be written without base64 encoding
+func ldifSafe(s string) bool {
+	if s == "" {
+		return true
+	}
+	switch s[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if s[len(s)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == 0 || c == '\n' || c == '\r' || c > 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLDIFLine writes an attribute, base64 encoded when needed and folded at ldifLineLength
+func writeLDIFLine(b *bytes.Buffer, a ldifAttr) {
+	l := a.name + ": " + a.value
+	if !ldifSafe(a.value) {
+		l = a.name + ":: " + base64.StdEncoding.EncodeToString([]byte(a.value))
+	}
+	for len(l) > ldifLineLength {
+		b.WriteString(l[:ldifLineLength])
+		b.WriteString("\n ")
+		l = l[ldifLineLength:]
+	}
+	b.WriteString(l)
+	b.WriteByte('\n')
+}
+
+// LDIF returns the card as an LDIF record of an inetOrgPerson entry with the given distinguished name
+func (v *VCard) LDIF(dn string) ([]byte, error) {
+	if dn == "" {
+		return nil, errors.New("a distinguished name is required for an LDIF entry")
+	}
+	attrs, err := v.ldifAttrs(dn)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, a := range attrs {
+		if a.value != "" {
+			writeLDIFLine(&b, a)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// escapeDN escapes an attribute value for use in a distinguished name
+func escapeDN(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(s)-1):
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// WriteLDIF writes the cards as LDIF content records, named by their cn below base
+func WriteLDIF(w io.Writer, base string, cards ...*VCard) error {
+	if _, err := io.WriteString(w, "version: 1\n"); err != nil {
+		return err
+	}
+	for n, card := range cards {
+		cn, _, _, err := card.ldifNames()
+		if err != nil {
+			return fmt.Errorf("card %d: %v", n+1, err)
+		}
+		dn := "cn=" + escapeDN(cn)
+		if base != "" {
+			dn += "," + base
+		}
+		b, err := card.LDIF(dn)
+		if err != nil {
+			return fmt.Errorf("card %d: %v", n+1, err)
+		}
+		if _, err := fmt.Fprintf(w, "\n%s", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ldifRecord is an entry read from LDIF content, with attribute names in lower case
+type ldifRecord struct {
+	line  int
+	attrs []ldifAttr
+}
+
+func (r ldifRecord) get(name string) []string {
+	var v []string
+	for _, a := range r.attrs {
+		if a.name == name {
+			v = append(v, a.value)
+		}
+	}
+	return v
+}
+
+func (r ldifRecord) first(name string) string {
+	if v := r.get(name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// parseLDIFLine parses an unfolded attribute line, decoding base64 values
+func parseLDIFLine(l string) (ldifAttr, error) {
+	i := strings.IndexByte(l, ':')
+	if i <= 0 {
+		return ldifAttr{}, fmt.Errorf("missing attribute name in %q", l)
+	}
+	name := strings.ToLower(l[:i])
+	// attribute options like ;binary or ;lang-en are ignored
+	if j := strings.IndexByte(name, ';'); j >= 0 {
+		name = name[:j]
+	}
+
+	v := l[i+1:]
+	switch {
+	case strings.HasPrefix(v, ":"):
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v[1:]))
+		if err != nil {
+			return ldifAttr{}, fmt.Errorf("invalid base64 value of %s: %v", name, err)
+		}
+		return ldifAttr{name, string(b)}, nil
+	case strings.HasPrefix(v, "<"):
+		return ldifAttr{}, fmt.Errorf("URL values of %s are unsupported", name)
+	}
+	return ldifAttr{name, strings.TrimLeft(v, " ")}, nil
+}
+
+// readLDIF reads the records of LDIF content
+func readLDIF(r io.Reader) ([]ldifRecord, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var (
+		records []ldifRecord
+		cur     ldifRecord
+		lines   []string
+		starts  []int
+		n       int
+	)
+	flush := func() error {
+		for i, l := range lines {
+			if strings.HasPrefix(l, "#") {
+				continue
+			}
+			a, err := parseLDIFLine(l)
+			if err != nil {
+				return &ParseError{Line: starts[i], Err: err}
+			}
+			if len(cur.attrs) == 0 && a.name != "dn" {
+				// the version line precedes the first record
+				if a.name == "version" && len(records) == 0 && a.value == "1" {
+					continue
+				}
+				return &ParseError{Line: starts[i], Err: errors.New("record doesn't start with dn")}
+			}
+			switch a.name {
+			case "dn":
+				if len(cur.attrs) > 0 {
+					return &ParseError{Line: starts[i], Err: errors.New("records must be separated by an empty line")}
+				}
+				cur.line = starts[i]
+			case "changetype":
+				if !strings.EqualFold(a.value, "add") {
+					return &ParseError{Line: starts[i], Err: fmt.Errorf("unsupported changetype %s", a.value)}
+				}
+				continue
+			case "control":
+				continue
+			}
+			cur.attrs = append(cur.attrs, a)
+		}
+		if len(cur.attrs) > 0 {
+			records = append(records, cur)
+		}
+		cur, lines, starts = ldifRecord{}, nil, nil
+		return nil
+	}
+
+	for s.Scan() {
+		n++
+		l := strings.TrimRight(s.Text(), "\r")
+		switch {
+		case l == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case l[0] == ' ':
+			if len(lines) == 0 {
+				return nil, &ParseError{Line: n, Err: errors.New("continuation line without a preceding line")}
+			}
+			lines[len(lines)-1] += l[1:]
+		default:
+			lines = append(lines, l)
+			starts = append(starts, n)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// card returns the record as a version 4.0 VCard
+func (r ldifRecord) card() (*VCard, error) {
+	cn, sn, given := r.first("cn"), r.first("sn"), r.first("givenname")
+	if cn == "" {
+		cn = strings.TrimSpace(given + " " + sn)
+	}
+	if cn == "" {
+		return nil, &ParseError{Line: r.line, Err: errors.New("entry has no cn")}
+	}
+
+	fields := []FieldFormatter{FN{cn}}
+	if sn != "" || given != "" {
+		fields = append(fields, N{FamilyName: sn, GivenName: given})
+	}
+	if o, ou := r.get("o"), r.get("ou"); len(o) > 0 || len(ou) > 0 {
+		org := Org{Units: ou}
+		if len(o) > 0 {
+			org.Name = o[0]
+		}
+		fields = append(fields, org)
+	}
+	for _, t := range r.get("title") {
+		fields = append(fields, Title{t})
+	}
+	for _, m := range r.get("mail") {
+		fields = append(fields, Email{Email: m})
+	}
+	for _, a := range r.attrs {
+		if types, ok := ldifTel[a.name]; ok {
+			fields = append(fields, Tel{Types: types, Number: a.value})
+		}
+	}
+
+	work := Adr{
+		Types:         []string{AdrWork},
+		PostOfficeBox: r.first("postofficebox"),
+		StreetAddress: strings.Join(postalLines(r.first("postaladdress")), ", "),
+		Locality:      r.first("l"),
+		Region:        r.first("st"),
+		PostalCode:    r.first("postalcode"),
+		CountryName:   r.first("co"),
+		CC:            strings.ToUpper(r.first("c")),
+	}
+	if work.CountryName == "" {
+		work.CountryName = work.CC
+	}
+	if work.PostOfficeBox+work.StreetAddress+work.Locality+work.Region+work.PostalCode+work.CountryName != "" {
+		fields = append(fields, work)
+	}
+	if home := postalLines(r.first("homepostaladdress")); len(home) > 0 {
+		fields = append(fields, Adr{Types: []string{AdrHome}, StreetAddress: strings.Join(home, ", ")})
+	}
+
+	for _, p := range r.get("jpegphoto") {
+		fields = append(fields, Photo{Type: "image/jpeg", Base64Data: base64.StdEncoding.EncodeToString([]byte(p))})
+	}
+
+	card, err := New("4.0", fields...)
+	if err != nil {
+		return nil, &ParseError{Line: r.line, Err: err}
+	}
+	return card, nil
+}
+
+// LDIFErrors collects the errors of all entries which couldn't be read as VCard
+type LDIFErrors []*ParseError
+
+// Error implements the error interface
+func (errs LDIFErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// ParseLDIF reads the entries of LDIF content as version 4.0 VCards, mapping the inetOrgPerson
+// attributes onto fields. Attributes without a matching field are ignored. Entries which can't be
+// read as VCard are skipped and reported in an LDIFErrors, along with the cards of the other entries.
+func ParseLDIF(r io.Reader) ([]*VCard, error) {
+	records, err := readLDIF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cards []*VCard
+		errs  LDIFErrors
+	)
+	for _, rec := range records {
+		card, err := rec.card()
+		if err != nil {
+			if perr, ok := err.(*ParseError); ok {
+				errs = append(errs, perr)
+				continue
+			}
+			return cards, err
+		}
+		cards = append(cards, card)
+	}
+	if len(errs) > 0 {
+		return cards, errs
+	}
+	return cards, nil
+}
@@ -0,0 +1,212 @@
+package vcard_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestLDIF(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.FN{"Forrest Gump"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co.", Units: []string{"Boats"}},
+		vcard.Title{"Shrimp Man"},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelVoice}, Number: "+1-111-555-1212"},
+		vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1-111-555-1213"},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelFax}, Number: "+1-111-555-1214"},
+		vcard.Email{Email: "forrestgump@example.com"},
+		vcard.Adr{Types: []string{vcard.AdrWork}, StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America"},
+		vcard.Photo{Type: "image/jpeg", Base64Data: "/9j/4AAQ"},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := vcard.WriteLDIF(&b, "ou=people,dc=example,dc=com", card); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := `version: 1
+
+dn: cn=Forrest Gump,ou=people,dc=example,dc=com
+objectClass: top
+objectClass: person
+objectClass: organizationalPerson
+objectClass: inetOrgPerson
+cn: Forrest Gump
+sn: Gump
+givenName: Forrest
+o: Bubba Gump Shrimp Co.
+ou: Boats
+title: Shrimp Man
+telephoneNumber: +1-111-555-1212
+mobile: +1-111-555-1213
+facsimileTelephoneNumber: +1-111-555-1214
+mail: forrestgump@example.com
+postalAddress: 100 Waters Edge
+l: Baytown
+st: LA
+postalCode: 30314
+c: US
+co: United States of America
+jpegPhoto:: /9j/4AAQ
+`
+	if b.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, b.String())
+	}
+
+	cards, err := vcard.ParseLDIF(&b)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("expected 1 card, but got %d", len(cards))
+	}
+	// the parsed card has FN before N and the fields grouped by attribute
+	fields := []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co.", Units: []string{"Boats"}},
+		vcard.Title{"Shrimp Man"},
+		vcard.Email{Email: "forrestgump@example.com"},
+		vcard.Tel{Types: []string{vcard.TelWork, vcard.TelVoice}, Number: "+1-111-555-1212"},
+		vcard.Tel{Types: []string{vcard.TelCell}, Number: "+1-111-555-1213"},
+		vcard.Tel{Types: []string{vcard.TelFax}, Number: "+1-111-555-1214"},
+		vcard.Adr{Types: []string{vcard.AdrWork}, StreetAddress: "100 Waters Edge", Locality: "Baytown", Region: "LA", PostalCode: "30314", CountryName: "United States of America", CC: "US"},
+		vcard.Photo{Type: "image/jpeg", Base64Data: "/9j/4AAQ"},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, fields) {
+		t.Fatalf("expected %v, but got %v", fields, cards[0].Fields)
+	}
+}
+
+func TestParseLDIF(t *testing.T) {
+	in := `version: 1
+# a comment which is
+  continued
+
+dn:: Y249SsO8cmdlbiBNw7xsbGVyLGRjPWV4YW1wbGU=
+objectclass: inetOrgPerson
+cn:: SsO8cmdlbiBNw7xsbGVy
+sn: Muel
+ ler
+mail: juergen@exam
+ ple.com
+homePhone: +49 30 1234
+homePostalAddress: Hauptstrasse 1 \5C 2\24$10115 Berlin$Germany
+description;lang-de: ignored
+
+dn: cn=Jenny Curran,dc=example
+changetype: add
+givenName: Jenny
+sn: Curran
+`
+	cards, err := vcard.ParseLDIF(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, but got %d", len(cards))
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.FN{"Jürgen Müller"},
+		vcard.N{FamilyName: "Mueller"},
+		vcard.Email{Email: "juergen@example.com"},
+		vcard.Tel{Types: []string{vcard.TelHome, vcard.TelVoice}, Number: "+49 30 1234"},
+		vcard.Adr{Types: []string{vcard.AdrHome}, StreetAddress: `Hauptstrasse 1 \ 2$, 10115 Berlin, Germany`},
+	}
+	if !reflect.DeepEqual(cards[0].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[0].Fields)
+	}
+	expected = []vcard.FieldFormatter{vcard.FN{"Jenny Curran"}, vcard.N{FamilyName: "Curran", GivenName: "Jenny"}}
+	if !reflect.DeepEqual(cards[1].Fields, expected) {
+		t.Fatalf("expected %v, but got %v", expected, cards[1].Fields)
+	}
+}
+
+func TestLDIFEncoding(t *testing.T) {
+	card, err := vcard.New("4.0", vcard.FN{"Jürgen, \"Jay\" Müller"}, vcard.Title{strings.Repeat("x", 80)})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := vcard.WriteLDIF(&b, "dc=example", card); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	for _, l := range strings.Split(b.String(), "\n") {
+		if len(l) > 76 {
+			t.Fatalf("expected lines to be folded, but got %q", l)
+		}
+	}
+	if !strings.Contains(b.String(), "\ncn:: ") {
+		t.Fatalf("expected a base64 encoded cn, but got %s", b.String())
+	}
+
+	cards, err := vcard.ParseLDIF(&b)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if got := cards[0].Fields[0]; got != card.Fields[0] {
+		t.Fatalf("expected %v, but got %v", card.Fields[0], got)
+	}
+	if got := cards[0].Fields[2]; got != card.Fields[1] {
+		t.Fatalf("expected %v, but got %v", card.Fields[1], got)
+	}
+}
+
+func TestParseLDIFErrors(t *testing.T) {
+	tests := []string{
+		"cn: No DN\n",
+		"dn: cn=x\nchangetype: delete\n",
+		"dn: cn=x\ncn:: not base64!\n",
+		"dn: cn=x\njpegPhoto:< file:///tmp/photo.jpg\n",
+		"dn: cn=x\nobjectClass: top\n",
+		" continued\n",
+	}
+	for _, in := range tests {
+		if _, err := vcard.ParseLDIF(strings.NewReader(in)); err == nil {
+			t.Fatalf("expected %q to fail", in)
+		}
+	}
+
+	// entries which aren't valid cards are reported along with the other cards
+	cards, err := vcard.ParseLDIF(strings.NewReader("dn: cn=x\nobjectClass: top\n\ndn: cn=Jenny Curran\ncn: Jenny Curran\n"))
+	errs, ok := err.(vcard.LDIFErrors)
+	if !ok || len(errs) != 1 || errs[0].Line != 1 {
+		t.Fatalf("expected an error for the entry on line 1, but got: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("expected 1 card, but got %d", len(cards))
+	}
+}
+
+func TestLDIFCountry(t *testing.T) {
+	tests := []struct {
+		adr      vcard.Adr
+		expected string
+	}{
+		{vcard.Adr{Locality: "Berlin", CountryName: "Allemagne", CC: "de"}, "l: Berlin\nc: DE\nco: Allemagne\n"},
+		{vcard.Adr{Locality: "Vilnius", CountryName: "Lietuva"}, "l: Vilnius\nc: LT\nco: Lietuva\n"},
+		// c is left out for countries which don't resolve to a code
+		{vcard.Adr{Locality: "Atlantis", CountryName: "Atlantis"}, "l: Atlantis\nco: Atlantis\n"},
+	}
+	for _, test := range tests {
+		card, err := vcard.New("4.0", vcard.FN{"Forrest Gump"}, test.adr)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		var b bytes.Buffer
+		if err := vcard.WriteLDIF(&b, "dc=example", card); err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if !strings.HasSuffix(b.String(), test.expected) {
+			t.Fatalf("expected %q at the end of %q", test.expected, b.String())
+		}
+	}
+}
@@ -0,0 +1,730 @@
+package vcard
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshaler is implemented by types which provide their own fields to Marshal
+type Marshaler interface {
+	MarshalVCard(version string) ([]FieldFormatter, error)
+}
+
+// Unmarshaler is implemented by types which read themselves from a VCard in Unmarshal
+type Unmarshaler interface {
+	UnmarshalVCard(card *VCard) error
+}
+
+// structuredComponents contains the tag names of the components of structured properties
+var structuredComponents = map[string][]string{
+	"N":      {"family", "given", "additional", "prefix", "suffix"},
+	"ADR":    {"pobox", "ext", "street", "locality", "region", "code", "country"},
+	"ORG":    {"name", "unit"},
+	"GENDER": {"sex", "identity"},
+}
+
+var (
+	fieldFormatterType  = reflect.TypeOf((*FieldFormatter)(nil)).Elem()
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// fieldTag is a parsed vcard struct tag
+type fieldTag struct {
+	name      string
+	component int
+	types     []string
+}
+
+// parseTag parses a struct tag of the form "name[.component][,type=value...]"
+func parseTag(tag string) (fieldTag, error) {
+	opts := strings.Split(tag, ",")
+	t := fieldTag{component: -1}
+
+	name := opts[0]
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		comp := strings.ToLower(name[i+1:])
+		name = name[:i]
+		for j, c := range structuredComponents[strings.ToUpper(name)] {
+			if c == comp {
+				t.component = j
+			}
+		}
+		if t.component < 0 {
+			return t, fmt.Errorf("%s has no component %q", strings.ToUpper(name), comp)
+		}
+	}
+	if name == "" {
+		return t, fmt.Errorf("missing property name in tag %q", tag)
+	}
+	t.name = strings.ToUpper(name)
+
+	for _, o := range opts[1:] {
+		switch {
+		case strings.HasPrefix(o, "type="):
+			t.types = append(t.types, strings.ToLower(o[len("type="):]))
+		default:
+			return t, fmt.Errorf("unknown option %q in tag %q", o, tag)
+		}
+	}
+	return t, nil
+}
+
+// params returns the TYPE parameter of the tag
+func (t fieldTag) params() Params {
+	p := Params{}
+	for _, typ := range t.types {
+		p.Add("TYPE", typ)
+	}
+	return p
+}
+
+// key identifies the property of the tag, so components of the same property can be collected
+func (t fieldTag) key() string {
+	return t.name + ";" + strings.Join(t.types, ",")
+}
+
+// text reports whether the values of the tag's property are text
+func (t fieldTag) text() bool {
+	_, ok := valueTypes[t.name]
+	return !ok
+}
+
+// componentSep returns the separator of multiple values of a component, units of an organization
+// are separate components
+func (t fieldTag) componentSep() string {
+	if t.name == "ORG" && t.component == 1 {
+		return ";"
+	}
+	return ","
+}
+
+// visible reports whether a struct field is marshaled, which are the exported fields and embedded
+// structs as their exported fields are promoted
+func visible(sf reflect.StructField) bool {
+	return sf.PkgPath == "" || sf.Anonymous && sf.Type.Kind() == reflect.Struct
+}
+
+// isSlice reports whether a type holds repeated values, byte slices are a single value
+func isSlice(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8
+}
+
+// isComponentStruct reports whether a struct type is read as the components of a structured property
+func isComponentStruct(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ != timeType && typ != urlType &&
+		!typ.Implements(textMarshalerType) && !reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// structured collects the components of a structured property
+type structured struct {
+	tag   fieldTag
+	comps []string
+}
+
+type encoder struct {
+	// entries contains the fields, the *Property of tagged values and the *structured properties in struct order
+	entries []interface{}
+	// structured contains the structured properties set through component tags by key
+	structured map[string]*structured
+	version    string
+}
+
+// Marshal returns a VCard of the version holding the fields of v, which is a struct or a pointer to a
+// struct. Exported fields are mapped through their vcard tag, which names the property and optionally
+// the component of a structured property and the types, such as `vcard:"email,type=work"` or
+// `vcard:"n.family"`. The components are family, given, additional, prefix and suffix for N; pobox, ext,
+// street, locality, region, code and country for ADR; name and unit for ORG; sex and identity for
+// GENDER. A struct tagged with a structured property holds its components in fields tagged with their
+// names.
+//
+// Slices result in a property per element, or a list of values when tagged with a component. Strings,
+// numbers, booleans, time.Time, url.URL and encoding.TextMarshaler values are supported, zero values are
+// left out. Untagged fields of a FieldFormatter type are added as they are and untagged embedded structs
+// are marshaled as part of v. Types implementing Marshaler provide their own fields. A field tagged with
+// "-" is ignored.
+func Marshal(v interface{}, version string) (*VCard, error) {
+	if _, ok := versions[version]; !ok {
+		return nil, &VersionError{}
+	}
+
+	e := &encoder{structured: make(map[string]*structured), version: version}
+	rv := reflect.ValueOf(v)
+	ok, err := e.marshaler(rv)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("can't marshal %T, expected a struct", v)
+		}
+		if err := e.encodeStruct(rv); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := e.fields()
+	if err != nil {
+		return nil, err
+	}
+	return New(version, fields...)
+}
+
+// marshaler adds the fields of a value implementing Marshaler, reporting whether it did
+func (e *encoder) marshaler(v reflect.Value) (bool, error) {
+	if !v.IsValid() || !v.CanInterface() {
+		return false, nil
+	}
+	if !v.Type().Implements(marshalerType) {
+		if v.CanAddr() && v.Addr().Type().Implements(marshalerType) {
+			v = v.Addr()
+		} else {
+			return false, nil
+		}
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return true, nil
+	}
+	fields, err := v.Interface().(Marshaler).MarshalVCard(e.version)
+	if err != nil {
+		return true, err
+	}
+	for _, f := range fields {
+		e.entries = append(e.entries, f)
+	}
+	return true, nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("vcard")
+		if tag == "-" || !visible(sf) {
+			continue
+		}
+
+		fv := v.Field(i)
+		if ok, err := e.marshaler(fv); ok || err != nil {
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+			}
+			continue
+		}
+		if tag == "" {
+			if err := e.encodeUntagged(sf, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		t, err := parseTag(tag)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+		if err := e.encode(t, fv); err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// encodeUntagged adds fields of a FieldFormatter type and marshals embedded structs
+func (e *encoder) encodeUntagged(sf reflect.StructField, v reflect.Value) error {
+	switch {
+	case v.Type().Implements(fieldFormatterType):
+		if v.Kind() != reflect.Interface && v.IsZero() || v.Kind() == reflect.Interface && v.IsNil() {
+			return nil
+		}
+		e.entries = append(e.entries, v.Interface().(FieldFormatter))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Implements(fieldFormatterType):
+		for i := 0; i < v.Len(); i++ {
+			if f, ok := v.Index(i).Interface().(FieldFormatter); ok {
+				e.entries = append(e.entries, f)
+			}
+		}
+	case sf.Anonymous:
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			return e.encodeStruct(v)
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encode(t fieldTag, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type().Implements(fieldFormatterType):
+		if !v.IsZero() {
+			e.entries = append(e.entries, v.Interface().(FieldFormatter))
+		}
+	case t.component >= 0:
+		s, err := componentValue(t, v)
+		if err != nil || s == "" {
+			return err
+		}
+		e.component(t).comps[t.component] = s
+	case isSlice(v.Type()):
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(t, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case structuredComponents[t.name] != nil && isComponentStruct(v.Type()):
+		s := &structured{tag: t, comps: make([]string, len(structuredComponents[t.name]))}
+		if err := s.encodeStruct(v); err != nil {
+			return err
+		}
+		e.entries = append(e.entries, s)
+	default:
+		s, err := textValue(v)
+		if err != nil || s == "" {
+			return err
+		}
+		if t.text() {
+			s = escapeText(s)
+		}
+		e.entries = append(e.entries, &Property{Name: t.name, Params: t.params(), Value: s})
+	}
+	return nil
+}
+
+// component returns the structured property of a component tag, creating it on first use
+func (e *encoder) component(t fieldTag) *structured {
+	s, ok := e.structured[t.key()]
+	if !ok {
+		s = &structured{tag: t, comps: make([]string, len(structuredComponents[t.name]))}
+		e.structured[t.key()] = s
+		e.entries = append(e.entries, s)
+	}
+	return s
+}
+
+// encodeStruct sets the components of a structured property from the fields of a struct
+func (s *structured) encodeStruct(v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("vcard")
+		if tag == "" || tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		t, err := parseTag(s.tag.name + "." + tag)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+
+		c, err := componentValue(t, v.Field(i))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+		s.comps[t.component] = c
+	}
+	return nil
+}
+
+// componentValue returns the escaped component of a structured property, slices are a list of values
+func componentValue(t fieldTag, v reflect.Value) (string, error) {
+	if !isSlice(v.Type()) {
+		s, err := textValue(v)
+		return escapeText(s), err
+	}
+	var vals []string
+	for i := 0; i < v.Len(); i++ {
+		s, err := textValue(v.Index(i))
+		if err != nil {
+			return "", err
+		}
+		if s != "" {
+			vals = append(vals, escapeText(s))
+		}
+	}
+	return strings.Join(vals, t.componentSep()), nil
+}
+
+// fields turns the collected entries into fields of the encoder's version
+func (e *encoder) fields() ([]FieldFormatter, error) {
+	var fields []FieldFormatter
+	for _, entry := range e.entries {
+		var p Property
+		switch entry := entry.(type) {
+		case *Property:
+			p = *entry
+		case FieldFormatter:
+			fields = append(fields, entry)
+			continue
+		case *structured:
+			if strings.Join(entry.comps, "") == "" {
+				continue
+			}
+			p = Property{Name: entry.tag.name, Params: entry.tag.params(), Value: strings.Join(entry.comps, ";")}
+		}
+		f, err := decodeField(e.version, p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.Name, err)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// textValue returns the text of a value, zero values result in an empty string
+func textValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return "", nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		t := v.Interface().(time.Time)
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+			return t.Format(dateFormat), nil
+		}
+		return t.Format(dateTimeFormat), nil
+	case urlType:
+		u := v.Interface().(url.URL)
+		return u.String(), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+type decoder struct {
+	card  *VCard
+	props []Property
+}
+
+// Unmarshal stores the fields of a VCard in the struct v points to, reversing Marshal. A field tagged
+// with a property receives the first property with that name and all the types of the tag, slices
+// receive all of them. Untagged fields of a FieldFormatter type receive the first field of that type,
+// slices of a FieldFormatter type all of them. Types implementing Unmarshaler read themselves from the
+// VCard.
+func Unmarshal(card *VCard, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can't unmarshal into %T, expected a non-nil pointer", v)
+	}
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalVCard(card)
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("can't unmarshal into %T, expected a pointer to a struct", v)
+	}
+
+	props, err := card.Properties()
+	if err != nil {
+		return err
+	}
+	d := &decoder{card: card, props: props}
+	return d.decodeStruct(rv.Elem())
+}
+
+// unmarshaler lets a value implementing Unmarshaler read itself, reporting whether it did
+func (d *decoder) unmarshaler(v reflect.Value) (bool, error) {
+	if !v.CanInterface() {
+		return false, nil
+	}
+	if v.Kind() == reflect.Ptr && v.Type().Implements(unmarshalerType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+	} else if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		v = v.Addr()
+	} else {
+		return false, nil
+	}
+	return true, v.Interface().(Unmarshaler).UnmarshalVCard(d.card)
+}
+
+func (d *decoder) decodeStruct(v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("vcard")
+		if tag == "-" || !visible(sf) {
+			continue
+		}
+
+		fv := v.Field(i)
+		if ok, err := d.unmarshaler(fv); ok || err != nil {
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+			}
+			continue
+		}
+		if tag == "" || fv.Type().Implements(fieldFormatterType) ||
+			isSlice(fv.Type()) && fv.Type().Elem().Implements(fieldFormatterType) {
+			if err := d.decodeUntagged(sf, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		t, err := parseTag(tag)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+		if err := d.decode(t, fv); err != nil {
+			return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeUntagged sets fields of a FieldFormatter type and unmarshals embedded structs
+func (d *decoder) decodeUntagged(sf reflect.StructField, v reflect.Value) error {
+	switch {
+	case v.Type().Implements(fieldFormatterType):
+		for _, f := range d.card.Fields {
+			if reflect.TypeOf(f) == v.Type() || v.Kind() == reflect.Interface {
+				v.Set(reflect.ValueOf(f))
+				return nil
+			}
+		}
+	case isSlice(v.Type()) && v.Type().Elem().Implements(fieldFormatterType):
+		elem := v.Type().Elem()
+		s := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, f := range d.card.Fields {
+			if reflect.TypeOf(f) == elem || elem.Kind() == reflect.Interface {
+				s = reflect.Append(s, reflect.ValueOf(f))
+			}
+		}
+		if s.Len() > 0 {
+			v.Set(s)
+		}
+	case sf.Anonymous:
+		if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			return d.decodeStruct(v)
+		}
+	}
+	return nil
+}
+
+// matches returns the properties with the name and all the types of the tag
+func (d *decoder) matches(t fieldTag) []Property {
+	var props []Property
+	for _, p := range d.props {
+		if p.Name == t.name && subset(t.types, types(p.Params)) {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+func (d *decoder) decode(t fieldTag, v reflect.Value) error {
+	props := d.matches(t)
+	if len(props) == 0 {
+		return nil
+	}
+	if isSlice(v.Type()) && t.component < 0 {
+		s := reflect.MakeSlice(v.Type(), len(props), len(props))
+		for i := range props {
+			if err := decodeProperty(t, s.Index(i), props[i]); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return decodeProperty(t, v, props[0])
+}
+
+// decodeProperty stores a property, or the component of the tag, in v
+func decodeProperty(t fieldTag, v reflect.Value, p Property) error {
+	if v.Kind() == reflect.Ptr && isComponentStruct(v.Type().Elem()) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case t.component >= 0:
+		return setComponent(t, v, structuredValue(t, p))
+	case structuredComponents[t.name] != nil && isComponentStruct(v.Type()):
+		c := structuredValue(t, p)
+		typ := v.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			sf := typ.Field(i)
+			tag := sf.Tag.Get("vcard")
+			if tag == "" || tag == "-" || sf.PkgPath != "" {
+				continue
+			}
+			ct, err := parseTag(t.name + "." + tag)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+			}
+			if err := setComponent(ct, v.Field(i), c); err != nil {
+				return fmt.Errorf("%s.%s: %v", typ.Name(), sf.Name, err)
+			}
+		}
+		return nil
+	}
+
+	s := p.Value
+	if t.text() {
+		s = unescapeText(s)
+	}
+	if t.name == "TEL" {
+		s = strings.TrimPrefix(s, "tel:")
+	}
+	return setText(v, s)
+}
+
+// structuredValue returns the components of a structured property, at least as many as the property has
+// component tags
+func structuredValue(t fieldTag, p Property) []string {
+	c := splitComponents(p.Value, ';')
+	for len(c) < len(structuredComponents[t.name]) {
+		c = append(c, "")
+	}
+	return c
+}
+
+// setComponent stores the component of the tag in v, slices receive the list of values
+func setComponent(t fieldTag, v reflect.Value, c []string) error {
+	s := c[t.component]
+	if t.name == "ORG" && t.component == 1 {
+		s = strings.Join(trimEmpty(c[1:]), ";")
+	}
+	if !isSlice(v.Type()) {
+		return setText(v, s)
+	}
+	if s == "" {
+		return nil
+	}
+	vals := strings.Split(s, t.componentSep())
+	sl := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+	for i := range vals {
+		if err := setText(sl.Index(i), vals[i]); err != nil {
+			return err
+		}
+	}
+	v.Set(sl)
+	return nil
+}
+
+// trimEmpty removes the trailing empty strings
+func trimEmpty(s []string) []string {
+	for len(s) > 0 && s[len(s)-1] == "" {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// setText stores text in v, reversing textValue
+func setText(v reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch v.Type() {
+	case timeType:
+		t, _, ok := parseTime(s, dateTimeFormat)
+		if !ok {
+			return fmt.Errorf("invalid date or time %q", s)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package vcard_test
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("L%d", l)), nil
+}
+
+func (l *level) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "L%d", (*int)(l))
+	return err
+}
+
+type person struct {
+	FullName string `vcard:"fn"`
+	Family   string `vcard:"n.family"`
+	Given    string `vcard:"n.given"`
+}
+
+type address struct {
+	Street   string `vcard:"street"`
+	City     string `vcard:"locality"`
+	Postcode string `vcard:"code"`
+}
+
+type employee struct {
+	person
+	Person
+	Emails      []string  `vcard:"email,type=work"`
+	Mobile      string    `vcard:"tel,type=cell"`
+	Company     string    `vcard:"org.name"`
+	Departments []string  `vcard:"org.unit"`
+	Home        *address  `vcard:"adr,type=home"`
+	Birthday    time.Time `vcard:"bday"`
+	Homepage    *url.URL  `vcard:"url"`
+	Level       level     `vcard:"x-level"`
+	Remote      bool      `vcard:"x-remote"`
+	Note        string    `vcard:"note"`
+	Geo         vcard.Geo
+	Keys        []vcard.Key
+	Secret      string `vcard:"-"`
+}
+
+// Person is embedded in employee to check exported embedded structs
+type Person struct {
+	Nickname string `vcard:"nickname"`
+}
+
+func TestMarshal(t *testing.T) {
+	e := employee{
+		person:      person{FullName: "Forrest Gump", Family: "Gump", Given: "Forrest"},
+		Person:      Person{Nickname: "Gump"},
+		Emails:      []string{"forrest@example.com", "gump@example.com"},
+		Mobile:      "+11115551213",
+		Company:     "Bubba Gump Shrimp Co.",
+		Departments: []string{"Boats", "Shrimp"},
+		Home:        &address{Street: "100 Waters Edge", City: "Baytown", Postcode: "30314"},
+		Birthday:    time.Date(1944, 6, 6, 0, 0, 0, 0, time.UTC),
+		Homepage:    &url.URL{Scheme: "https", Host: "example.com", Path: "/forrest"},
+		Level:       3,
+		Remote:      true,
+		Note:        "Runs; a lot",
+		Geo:         vcard.Geo{Lat: 30.4, Long: -89.1},
+		Secret:      "shrimp",
+	}
+	card, err := vcard.Marshal(e, "4.0")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	got, err := card.Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:Forrest Gump",
+		"N:Gump;Forrest;;;",
+		"NICKNAME:Gump",
+		"EMAIL;TYPE=work:forrest@example.com",
+		"EMAIL;TYPE=work:gump@example.com",
+		"TEL;TYPE=cell;VALUE=uri:tel:+11115551213",
+		"ORG:Bubba Gump Shrimp Co.;Boats;Shrimp",
+		"ADR;TYPE=home:;;100 Waters Edge;Baytown;;30314;",
+		"BDAY:19440606",
+		"URL:https://example.com/forrest",
+		"X-LEVEL:L3",
+		"X-REMOTE:true",
+		`NOTE:Runs\; a lot`,
+		"GEO:geo:30.4,-89.1",
+		"END:VCARD",
+	}, "\n")
+	if got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	var u employee
+	if err := vcard.Unmarshal(card, &u); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	e.Secret = ""
+	if !reflect.DeepEqual(u, e) {
+		t.Fatalf("expected %+v, but got %+v", e, u)
+	}
+}
+
+type contact struct {
+	Name string
+}
+
+func (c contact) MarshalVCard(version string) ([]vcard.FieldFormatter, error) {
+	return []vcard.FieldFormatter{vcard.FN{c.Name}, vcard.N{FamilyName: c.Name}}, nil
+}
+
+func (c *contact) UnmarshalVCard(card *vcard.VCard) error {
+	for _, f := range card.Fields {
+		if fn, ok := f.(vcard.FN); ok {
+			c.Name = fn.FormattedName
+		}
+	}
+	return nil
+}
+
+func TestMarshaler(t *testing.T) {
+	card, err := vcard.Marshal(&contact{"Jenny"}, "3.0")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	var c contact
+	if err := vcard.Unmarshal(card, &c); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if c.Name != "Jenny" {
+		t.Fatalf("expected %q, but got %q", "Jenny", c.Name)
+	}
+
+	tests := []struct {
+		v        interface{}
+		expected string
+	}{
+		{struct {
+			Name string `vcard:"n.nickname"`
+		}{"x"}, "N has no component"},
+		{struct {
+			Name string `vcard:"fn,omitempty"`
+		}{"x"}, "unknown option"},
+		{struct {
+			Name []int `vcard:"fn"`
+		}{}, "FN is a required field"},
+		{"Jenny", "expected a struct"},
+	}
+	for _, test := range tests {
+		if _, err := vcard.Marshal(test.v, "4.0"); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("expected %q, but got: %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package vcard
+
+import (
+	"strings"
+	"unicode"
+)
+
+// namePrefixes contains the honorific prefixes recognized by ParseName, in lower case without dots
+var namePrefixes = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "prof": true,
+	"sir": true, "dame": true, "lord": true, "lady": true, "rev": true, "fr": true, "hon": true,
+	"capt": true, "col": true, "gen": true, "lt": true, "sgt": true, "mme": true, "mlle": true,
+	"herr": true, "frau": true, "ing": true, "mag": true,
+}
+
+// nameSuffixes contains the honorific suffixes recognized by ParseName, in lower case without dots
+var nameSuffixes = map[string]bool{
+	"jr": true, "sr": true, "ii": true, "iii": true, "iv": true, "v": true, "phd": true, "md": true,
+	"dds": true, "esq": true, "mba": true, "cpa": true, "rn": true, "ret": true, "obe": true,
+	"mbe": true, "kbe": true, "qc": true, "kc": true,
+}
+
+// nameParticles contains the words which belong to the family name that follows them
+var nameParticles = map[string]bool{
+	"van": true, "von": true, "der": true, "den": true, "de": true, "del": true, "della": true,
+	"di": true, "da": true, "dos": true, "das": true, "du": true, "la": true, "le": true, "ter": true,
+	"ten": true, "bin": true, "binti": true, "ibn": true, "al": true, "el": true, "mc": true,
+	"mac": true, "st": true, "zu": true, "af": true, "av": true,
+}
+
+// familyFirst contains the languages which put the family name before the given name
+var familyFirst = map[string]bool{
+	"hu": true, "zh": true, "ja": true, "ko": true, "vi": true, "mn": true,
+}
+
+// FamilyFirst reports whether names are written with the family name first in the language, a BCP
+// 47 tag such as "hu" or "zh-Hant"
+func FamilyFirst(language string) bool {
+	base := strings.ToLower(language)
+	if i := strings.IndexAny(base, "-_"); i >= 0 {
+		base = base[:i]
+	}
+	return familyFirst[base]
+}
+
+// nameWord returns a word of a name in lower case without dots, for looking it up
+func nameWord(w string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(w, ","), ".", ""))
+}
+
+// ParseName splits a free text name, such as "Dr. Arjan van Eersel Jr." or "van Eersel, Arjan", into
+// the components of N. Particles such as van, de and bin are kept with the family name. Names in a
+// language writing the family name first, see FamilyFirst, are split accordingly. Multiple prefixes,
+// suffixes and additional names are separated by commas, as in a vCard.
+func ParseName(s, language string) N {
+	var n N
+	words := strings.Fields(s)
+
+	var prefixes, suffixes []string
+	for len(words) > 0 && namePrefixes[nameWord(words[0])] {
+		prefixes, words = append(prefixes, strings.TrimSuffix(words[0], ",")), words[1:]
+	}
+
+	// a comma separates either the suffixes or the family name from the rest
+	var family []string
+	for i, w := range words {
+		if !strings.HasSuffix(w, ",") {
+			continue
+		}
+		rest := words[i+1:]
+		allSuffixes := true
+		for _, r := range rest {
+			allSuffixes = allSuffixes && nameSuffixes[nameWord(r)]
+		}
+		if !allSuffixes {
+			family = append(words[:i:i], strings.TrimSuffix(w, ","))
+			words = rest
+		}
+		break
+	}
+	for len(words) > 1 && nameSuffixes[nameWord(words[len(words)-1])] {
+		suffixes = append([]string{strings.Trim(words[len(words)-1], ",")}, suffixes...)
+		words = words[:len(words)-1]
+	}
+	for i := range words {
+		words[i] = strings.TrimSuffix(words[i], ",")
+	}
+
+	switch {
+	case family != nil:
+	case len(words) < 2:
+		// a single name is a family name in family first languages
+		if FamilyFirst(language) {
+			family, words = words, nil
+		}
+	case FamilyFirst(language):
+		i := 0
+		for i < len(words)-2 && nameParticles[nameWord(words[i])] {
+			i++
+		}
+		family, words = words[:i+1], words[i+1:]
+	default:
+		// the particles before the last word belong to the family name, but the first word is
+		// always a given name
+		i := len(words) - 1
+		for i > 1 && nameParticles[nameWord(words[i-1])] {
+			i--
+		}
+		family, words = words[i:], words[:i]
+	}
+
+	n.HonorificPrefixes = strings.Join(prefixes, ",")
+	n.FamilyName = strings.Join(family, " ")
+	if len(words) > 0 {
+		n.GivenName = words[0]
+		n.AdditionalNames = strings.Join(words[1:], ",")
+	}
+	n.HonorificSuffixes = strings.Join(suffixes, ",")
+	return n
+}
+
+// nameComponent returns a component of N as words separated by spaces
+func nameComponent(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }), " ")
+}
+
+// ideographic reports whether a name is written in Chinese, Japanese or Korean script, which don't
+// separate the family and given name by a space
+func ideographic(s string) bool {
+	found := false
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			found = true
+		case !unicode.IsSpace(r):
+			return false
+		}
+	}
+	return found
+}
+
+// Formatted returns the formatted name of the N in the order of the language, a BCP 47 tag. Most
+// languages put the given name first, languages such as Hungarian, Chinese and Japanese put the
+// family name first, see FamilyFirst. Chinese, Japanese and Korean names in their own script aren't
+// separated by spaces.
+func (f N) Formatted(language string) FN {
+	given := []string{nameComponent(f.GivenName), nameComponent(f.AdditionalNames)}
+	family := nameComponent(f.FamilyName)
+
+	var parts []string
+	if FamilyFirst(language) {
+		parts = append([]string{family}, given...)
+	} else {
+		parts = append(given, family)
+	}
+	sep := " "
+	if FamilyFirst(language) && ideographic(f.FamilyName+f.GivenName+f.AdditionalNames) {
+		sep = ""
+	}
+	name := joinNonEmpty(sep, parts...)
+	return FN{joinNonEmpty(" ", nameComponent(f.HonorificPrefixes), name, nameComponent(f.HonorificSuffixes))}
+}
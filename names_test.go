@@ -0,0 +1,54 @@
+package vcard_test
+
+import (
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		expected vcard.N
+	}{
+		{"Dr. Arjan van Eersel Jr.", "", vcard.N{FamilyName: "van Eersel", GivenName: "Arjan", HonorificPrefixes: "Dr.", HonorificSuffixes: "Jr."}},
+		{"Forrest Gump", "en", vcard.N{FamilyName: "Gump", GivenName: "Forrest"}},
+		{"Juan Carlos de la Cruz", "es", vcard.N{FamilyName: "de la Cruz", GivenName: "Juan", AdditionalNames: "Carlos"}},
+		{"Osama bin Laden", "", vcard.N{FamilyName: "bin Laden", GivenName: "Osama"}},
+		{"Ronald Mc Donald", "", vcard.N{FamilyName: "Mc Donald", GivenName: "Ronald"}},
+		{"Van Morrison", "", vcard.N{FamilyName: "Morrison", GivenName: "Van"}},
+		{"van Eersel, Arjan", "", vcard.N{FamilyName: "van Eersel", GivenName: "Arjan"}},
+		{"Martin Luther King, Jr.", "", vcard.N{FamilyName: "King", GivenName: "Martin", AdditionalNames: "Luther", HonorificSuffixes: "Jr."}},
+		{"Prof. Dr. John Philip Paul Stevenson Jr. M.D.", "", vcard.N{FamilyName: "Stevenson", GivenName: "John", AdditionalNames: "Philip,Paul", HonorificPrefixes: "Prof.,Dr.", HonorificSuffixes: "Jr.,M.D."}},
+		{"Madonna", "", vcard.N{GivenName: "Madonna"}},
+		{"Dr. Kovács János", "hu", vcard.N{FamilyName: "Kovács", GivenName: "János", HonorificPrefixes: "Dr."}},
+		{"Mao Zedong", "zh-Hans", vcard.N{FamilyName: "Mao", GivenName: "Zedong"}},
+	}
+	for _, test := range tests {
+		if n := vcard.ParseName(test.name, test.language); n != test.expected {
+			t.Fatalf("expected %+v, but got %+v", test.expected, n)
+		}
+	}
+}
+
+func TestNameFormatted(t *testing.T) {
+	tests := []struct {
+		n        vcard.N
+		language string
+		expected string
+	}{
+		{vcard.N{FamilyName: "van Eersel", GivenName: "Arjan", HonorificPrefixes: "Dr.", HonorificSuffixes: "Jr."}, "nl", "Dr. Arjan van Eersel Jr."},
+		{vcard.N{FamilyName: "Stevenson", GivenName: "John", AdditionalNames: "Philip,Paul", HonorificSuffixes: "Jr.,M.D."}, "", "John Philip Paul Stevenson Jr. M.D."},
+		{vcard.N{FamilyName: "Kovács", GivenName: "János", HonorificPrefixes: "Dr."}, "hu", "Dr. Kovács János"},
+		{vcard.N{FamilyName: "毛", GivenName: "泽东"}, "zh", "毛泽东"},
+		{vcard.N{FamilyName: "山田", GivenName: "太郎"}, "ja-JP", "山田太郎"},
+		{vcard.N{FamilyName: "Yamada", GivenName: "Taro"}, "ja", "Yamada Taro"},
+		{vcard.N{GivenName: "Madonna"}, "", "Madonna"},
+	}
+	for _, test := range tests {
+		if fn := test.n.Formatted(test.language); fn.FormattedName != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, fn.FormattedName)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxLineLength is the maximum length of a single physical line the decoder accepts,
+// large enough for unfolded inline photos
+const maxLineLength = 16 << 20
+
+// ParseError is returned when the input isn't a well-formed vCard
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", err.Line, err.Err)
+}
+
+// Unwrap returns the underlying error
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// Decoder reads VCards from an input stream
+type Decoder struct {
+	// Strict makes Decode validate every VCard, see VCard.Validate
+	Strict bool
+	// MaxDepth is the maximum nesting of agent vCards, 0 means DefaultMaxDepth
+	MaxDepth int
+
+	depth int
+
+	s        *bufio.Scanner
+	line     int
+	peek     string
+	peekLine int
+	peeked   bool
+}
+
+// NewDecoder returns a new decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	return &Decoder{s: s}
+}
+
+func (d *Decoder) physical() (string, int, bool) {
+	if d.peeked {
+		d.peeked = false
+		return d.peek, d.peekLine, true
+	}
+	if !d.s.Scan() {
+		return "", d.line, false
+	}
+	d.line++
+	return strings.TrimRight(d.s.Text(), "\r"), d.line, true
+}
+
+func (d *Decoder) unread(s string, line int) {
+	d.peek, d.peekLine, d.peeked = s, line, true
+}
+
+// readLine returns the next non-empty logical line, with folded lines and
+// quoted-printable soft line breaks joined
+func (d *Decoder) readLine() (string, int, error) {
+	var (
+		l     string
+		start int
+		ok    bool
+	)
+	for {
+		if l, start, ok = d.physical(); !ok {
+			if err := d.s.Err(); err != nil {
+				return "", start, err
+			}
+			return "", start, io.EOF
+		}
+		if strings.TrimSpace(l) != "" {
+			break
+		}
+	}
+
+	qp := isQuotedPrintable(l)
+	for {
+		n, ln, ok := d.physical()
+		if !ok {
+			break
+		}
+		switch {
+		case len(n) > 0 && (n[0] == ' ' || n[0] == '\t'):
+			l += n[1:]
+		case qp && strings.HasSuffix(l, "="):
+			l = l[:len(l)-1] + n
+		default:
+			d.unread(n, ln)
+			return l, start, nil
+		}
+	}
+	return l, start, nil
+}
+
+func isQuotedPrintable(l string) bool {
+	i := strings.IndexByte(l, ':')
+	return i > 0 && strings.Contains(strings.ToUpper(l[:i]), "QUOTED-PRINTABLE")
+}
+
+// Decode reads the next VCard from the input, it returns io.EOF when there are no more VCards.
+// The VCard isn't validated, properties which can't be mapped onto a typed field are returned as Property.
+// The vCards of agents are decoded into Agent.VCard, up to the maximum depth.
+func (d *Decoder) Decode() (*VCard, error) {
+	l, n, err := d.readLine()
+	if err != nil {
+		return nil, err
+	}
+	return d.decode(l, n)
+}
+
+// decode reads a VCard starting with the BEGIN line l at line n
+func (d *Decoder) decode(l string, n int) (*VCard, error) {
+	if p, err := parseProperty(l); err != nil || p.Name != "BEGIN" || !strings.EqualFold(p.Value, "VCARD") {
+		return nil, &ParseError{n, fmt.Errorf("expected BEGIN:VCARD, got %q", l)}
+	}
+	if d.depth > d.maxDepth() {
+		return nil, &ParseError{n, ErrAgentDepth}
+	}
+	begin := n
+
+	var (
+		props   []Property
+		lines   []int
+		agents  = map[int]*VCard{}
+		version string
+		pending bool
+		err     error
+	)
+	for {
+		if !pending {
+			l, n, err = d.readLine()
+		}
+		pending = false
+		if err == io.EOF {
+			return nil, &ParseError{n, fmt.Errorf("missing END:VCARD")}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p, perr := parseProperty(l)
+		if perr != nil {
+			return nil, &ParseError{n, perr}
+		}
+		if p.Name == "END" && strings.EqualFold(p.Value, "VCARD") {
+			break
+		}
+		if p.Name == "VERSION" {
+			version = strings.TrimSpace(p.Value)
+			continue
+		}
+		if p.Name == "AGENT" && p.Group == "" && (p.Value == "" || strings.EqualFold(p.Value, "BEGIN:VCARD")) {
+			// version 2.1 embeds the vCard of the agent on the following lines
+			nl, nn := "BEGIN:VCARD", n
+			if p.Value == "" {
+				if nl, nn, err = d.readLine(); err != nil && err != io.EOF {
+					return nil, err
+				}
+			}
+			if err == nil && isNestedCard(nl) {
+				d.depth++
+				nested, err := d.decode(nl, nn)
+				d.depth--
+				if err != nil {
+					return nil, err
+				}
+				agents[len(props)] = nested
+				props = append(props, p)
+				lines = append(lines, n)
+				continue
+			}
+			props = append(props, p)
+			lines = append(lines, n)
+			// the next line isn't part of the agent
+			l, n, pending = nl, nn, true
+			continue
+		}
+		props = append(props, p)
+		lines = append(lines, n)
+	}
+
+	if version == "" {
+		return nil, &ParseError{n, fmt.Errorf("missing VERSION")}
+	}
+	if _, ok := versions[version]; !ok {
+		return nil, &VersionError{}
+	}
+
+	card := VCard{Version: version}
+	for i, p := range props {
+		if nested, ok := agents[i]; ok {
+			card.Fields = append(card.Fields, Agent{VCard: nested})
+			continue
+		}
+		f, err := decodeTyped(version, p)
+		if err != nil {
+			return nil, &ParseError{lines[i], err}
+		}
+		if a, ok := f.(Agent); ok && isNestedCard(a.Text) {
+			if f, err = d.decodeAgent(a); err != nil {
+				return nil, &ParseError{lines[i], err}
+			}
+		}
+		card.Fields = append(card.Fields, f)
+	}
+	card.Fields = attachLabels(card.Fields)
+	if d.Strict {
+		if err := card.Validate(); err != nil {
+			return nil, &ParseError{begin, err}
+		}
+	}
+	return &card, nil
+}
+
+// attachLabels moves the LABEL properties of versions 2.1 and 3.0 into the Label of the preceding ADR
+// with the same types, labels without such an address are kept as they are
+func attachLabels(fields []FieldFormatter) []FieldFormatter {
+	kept := make([]FieldFormatter, 0, len(fields))
+	for _, f := range fields {
+		p, ok := f.(Property)
+		if !ok || p.Name != "LABEL" || p.Group != "" {
+			kept = append(kept, f)
+			continue
+		}
+		t := types(p.Params)
+		attached := false
+		for i, a := range kept {
+			if adr, ok := a.(Adr); ok && adr.Label == "" && subset(t, adr.Types) && subset(adr.Types, t) {
+				adr.Label = strings.ReplaceAll(unescapeText(p.Value), "\r\n", "\n")
+				kept[i], attached = adr, true
+				break
+			}
+		}
+		if !attached {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Parse reads all VCards from r
+func Parse(r io.Reader) ([]*VCard, error) {
+	var (
+		cards []*VCard
+		d     = NewDecoder(r)
+	)
+	for {
+		card, err := d.Decode()
+		if err == io.EOF {
+			return cards, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+}
+
+// fieldDecoders maps property names onto functions which turn a parsed property into a typed field
+var fieldDecoders = map[string]func(v string, p Property) (FieldFormatter, error){
+	"N": func(v string, p Property) (FieldFormatter, error) {
+		c := components(p.Value, 5)
+		return N{
+			FamilyName:        c[0],
+			GivenName:         c[1],
+			AdditionalNames:   c[2],
+			HonorificPrefixes: c[3],
+			HonorificSuffixes: c[4],
+		}, nil
+	},
+	"FN": func(v string, p Property) (FieldFormatter, error) {
+		return FN{unescapeText(p.Value)}, nil
+	},
+	"ORG": func(v string, p Property) (FieldFormatter, error) {
+		c := splitComponents(p.Value, ';')
+		return Org{Name: c[0], Units: c[1:]}, nil
+	},
+	"TITLE": func(v string, p Property) (FieldFormatter, error) {
+		return Title{unescapeText(p.Value)}, nil
+	},
+	"ROLE": func(v string, p Property) (FieldFormatter, error) {
+		return Role{unescapeText(p.Value)}, nil
+	},
+	"PHOTO": func(v string, p Property) (FieldFormatter, error) {
+		tp, data, uri, ok := decodeMedia(v, p)
+		if !ok {
+			return p, nil
+		}
+		return Photo{Type: tp, URI: uri, Base64Data: data}, nil
+	},
+	"TEL": func(v string, p Property) (FieldFormatter, error) {
+		return Tel{Types: types(p.Params), Number: strings.TrimPrefix(p.Value, "tel:")}, nil
+	},
+	"ADR": func(v string, p Property) (FieldFormatter, error) {
+		c := components(p.Value, 7)
+		return Adr{
+			Types:           types(p.Params),
+			PostOfficeBox:   c[0],
+			ExtendedAddress: c[1],
+			StreetAddress:   c[2],
+			Locality:        c[3],
+			Region:          c[4],
+			PostalCode:      c[5],
+			CountryName:     c[6],
+			Label:           decodeParamValue(p.Params.Get("LABEL")),
+			Geo:             p.Params.Get("GEO"),
+			TZ:              p.Params.Get("TZ"),
+			CC:              p.Params.Get("CC"),
+		}, nil
+	},
+	"EMAIL": func(v string, p Property) (FieldFormatter, error) {
+		return Email{Types: types(p.Params), Email: unescapeText(p.Value)}, nil
+	},
+	"REV": func(v string, p Property) (FieldFormatter, error) {
+		t, format, ok := parseTime(p.Value, dateTimeFormat)
+		if !ok {
+			return p, nil
+		}
+		return Rev{Timestamp: t, TimeFormat: format}, nil
+	},
+	"AGENT": func(v string, p Property) (FieldFormatter, error) {
+		switch strings.ToLower(p.Params.Get("VALUE")) {
+		case "uri", "url":
+			if u, err := url.Parse(p.Value); err == nil {
+				return Agent{URI: u}, nil
+			}
+		}
+		return Agent{Text: unescapeText(p.Value)}, nil
+	},
+	"RELATED": decodeRelated,
+	"ANNIVERSARY": func(v string, p Property) (FieldFormatter, error) {
+		t, format, ok := parseTime(p.Value, dateFormat)
+		if !ok {
+			return p, nil
+		}
+		return Anniversary{Date: t, TimeFormat: format}, nil
+	},
+	"BDAY": func(v string, p Property) (FieldFormatter, error) {
+		t, format, ok := parseTime(p.Value, dateFormat)
+		if !ok {
+			return p, nil
+		}
+		return Bday{Timestamp: t, TimeFormat: format}, nil
+	},
+	"FBURL": func(v string, p Property) (FieldFormatter, error) {
+		u, err := url.Parse(p.Value)
+		if err != nil {
+			return p, nil
+		}
+		return FbURL{u}, nil
+	},
+	"GENDER": func(v string, p Property) (FieldFormatter, error) {
+		return Gender{p.Value}, nil
+	},
+	"GEO": func(v string, p Property) (FieldFormatter, error) {
+		f, err := ParseGeo(p.Value)
+		if err != nil {
+			return p, nil
+		}
+		return f, nil
+	},
+	"IMPP": func(v string, p Property) (FieldFormatter, error) {
+		f, err := ParseIMPP(p.Value)
+		if err != nil {
+			return p, nil
+		}
+		f.Types = types(p.Params)
+		return f, nil
+	},
+	"KEY": func(v string, p Property) (FieldFormatter, error) {
+		if strings.EqualFold(p.Params.Get("VALUE"), "text") {
+			return Key{Type: p.Params.Get("TYPE"), Data: p.Value}, nil
+		}
+		tp, data, uri, ok := decodeMedia(v, p)
+		if !ok {
+			return Key{Type: p.Params.Get("TYPE"), Data: p.Value}, nil
+		}
+		return Key{Type: tp, URI: uri, Data: data, Binary: data != ""}, nil
+	},
+	"KIND": func(v string, p Property) (FieldFormatter, error) {
+		return Kind{p.Value}, nil
+	},
+}
+
+// decodeField decodes a property into a typed field, the vCard of an agent is decoded as well
+func decodeField(v string, p Property) (FieldFormatter, error) {
+	f, err := decodeTyped(v, p)
+	if a, ok := f.(Agent); ok && err == nil && isNestedCard(a.Text) {
+		return (&Decoder{}).decodeAgent(a)
+	}
+	return f, err
+}
+
+// decodeTyped decodes a property into a typed field, leaving the vCard of an agent as text
+func decodeTyped(v string, p Property) (FieldFormatter, error) {
+	if strings.EqualFold(p.Params.Get("ENCODING"), "QUOTED-PRINTABLE") {
+		b, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(p.Value)))
+		if err != nil {
+			return nil, err
+		}
+		p.Value = string(b)
+		delete(p.Params, "ENCODING")
+		delete(p.Params, "CHARSET")
+	}
+
+	dec, ok := fieldDecoders[p.Name]
+	if f, impp := imppProperty(p); impp && v == "2.1" {
+		return f, nil
+	}
+	if !ok || p.Group != "" {
+		return p, nil
+	}
+	return dec(v, p)
+}
+
+// components splits a structured value into exactly n components
+func components(s string, n int) []string {
+	c := splitComponents(s, ';')
+	for len(c) < n {
+		c = append(c, "")
+	}
+	return c[:n]
+}
+
+// types collects the TYPE parameter values in lower case, PREF is mapped onto the pref type
+func types(p Params) []string {
+	var t []string
+	for _, v := range p["TYPE"] {
+		t = append(t, strings.ToLower(v))
+	}
+	if p.Has("PREF") {
+		t = append(t, TelPref)
+	}
+	return t
+}
+
+// decodeMedia reads the type, base64 data or URI of a PHOTO, LOGO, SOUND or KEY property
+func decodeMedia(v string, p Property) (string, string, *url.URL, bool) {
+	tp := p.Params.Get("TYPE")
+	switch enc := strings.ToUpper(p.Params.Get("ENCODING")); enc {
+	case "B", "BASE64":
+		return tp, p.Value, nil, true
+	}
+
+	if v == "4.0" && strings.HasPrefix(p.Value, "data:") {
+		i := strings.Index(p.Value, ";base64,")
+		if i < 0 {
+			return "", "", nil, false
+		}
+		return p.Value[len("data:"):i], p.Value[i+len(";base64,"):], nil, true
+	}
+	if mt := p.Params.Get("MEDIATYPE"); mt != "" {
+		tp = mt
+	}
+
+	u, err := url.Parse(p.Value)
+	if err != nil || u.Scheme == "" {
+		return "", "", nil, false
+	}
+	return tp, "", u, true
+}
+
+// timeFormats are the date and time layouts accepted by the parser
+var timeFormats = []string{
+	dateTimeFormat,
+	"20060102T150405",
+	"20060102T1504Z0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	dateFormat,
+	"2006-01-02",
+}
+
+// parseTime parses a date or timestamp and returns the layout it was written in,
+// or an empty layout when it matched the field's default
+func parseTime(s, def string) (time.Time, string, bool) {
+	for _, format := range timeFormats {
+		t, err := time.Parse(format, s)
+		if err != nil {
+			continue
+		}
+		if format == def {
+			format = ""
+		}
+		return t, format, true
+	}
+	return time.Time{}, "", false
+}
@@ -0,0 +1,200 @@
+package vcard_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestParse(t *testing.T) {
+	input := "BEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"N:Gump;Forrest;;Mr.;\r\n" +
+		"FN:Forrest Gump\r\n" +
+		"ORG:Bubba Gump Shrimp Co.;Shrimp\\, boats\r\n" +
+		"TITLE:Shrimp Man\r\n" +
+		"PHOTO;MEDIATYPE=image/gif:http://www.example.com/dir_photos/my_photo.gif\r\n" +
+		"TEL;TYPE=work,voice;VALUE=uri:tel:+1-111-555-1212\r\n" +
+		"ADR;TYPE=WORK:;;100 Waters Edge;Baytown;LA;30314;United States of A\r\n" +
+		" merica\r\n" +
+		"EMAIL:forrestgump@example.com\r\n" +
+		"REV:20080424T195243Z\r\n" +
+		"x-qq:21588891\r\n" +
+		"END:VCARD\r\n"
+
+	cards, err := vcard.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("expected 1 card, but got %d", len(cards))
+	}
+
+	card := cards[0]
+	if card.Version != "4.0" {
+		t.Fatalf("expected version %q, but got %q", "4.0", card.Version)
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest", HonorificPrefixes: "Mr."},
+		vcard.FN{"Forrest Gump"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co.", Units: []string{"Shrimp, boats"}},
+		vcard.Title{"Shrimp Man"},
+		card.Fields[4],
+		vcard.Tel{Types: []string{"work", "voice"}, Number: "+1-111-555-1212"},
+		vcard.Adr{
+			Types:         []string{vcard.AdrWork},
+			StreetAddress: "100 Waters Edge",
+			Locality:      "Baytown",
+			Region:        "LA",
+			PostalCode:    "30314",
+			CountryName:   "United States of America",
+		},
+		vcard.Email{Email: "forrestgump@example.com"},
+		vcard.Rev{Timestamp: time.Date(2008, 4, 24, 19, 52, 43, 0, time.UTC)},
+		vcard.Property{Name: "X-QQ", Params: vcard.Params{}, Value: "21588891"},
+	}
+	if !reflect.DeepEqual(card.Fields, expected) {
+		t.Fatalf("expected %#v, but got %#v", expected, card.Fields)
+	}
+
+	photo, ok := card.Fields[4].(vcard.Photo)
+	if !ok || photo.Type != "image/gif" || photo.URI.String() != "http://www.example.com/dir_photos/my_photo.gif" {
+		t.Fatalf("expected a gif photo, but got %#v", card.Fields[4])
+	}
+
+	if err := card.Validate(); err != nil {
+		t.Fatalf("expected parsed card to be valid, but got: %v", err)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	card, err := vcard.New("3.0",
+		vcard.N{FamilyName: "Person", GivenName: "Test"},
+		vcard.FN{"Test Person"},
+		vcard.Tel{Number: "+3701234567890", Types: []string{vcard.TelWork, vcard.TelVoice}},
+		vcard.Email{Types: []string{vcard.EmailInternet}, Email: "test@example.com"},
+		vcard.Geo{Lat: 39.95, Long: -75.1667},
+		vcard.IMPP{Platform: "aim", Handle: "test@example.com"},
+		vcard.Bday{Timestamp: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC)},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected, err := card.Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	cards, err := vcard.Parse(strings.NewReader(expected + "\n" + expected))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, but got %d", len(cards))
+	}
+
+	for _, c := range cards {
+		got, err := c.Generate()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got != expected {
+			t.Fatalf("expected %q, but got %q", expected, got)
+		}
+	}
+}
+
+func TestParse21(t *testing.T) {
+	input := "BEGIN:VCARD\n" +
+		"VERSION:2.1\n" +
+		"N:Person;Test\n" +
+		"TEL;WORK;VOICE:+3701234567890\n" +
+		"NOTE;ENCODING=QUOTED-PRINTABLE:first line=0D=0A=\n" +
+		"second line\n" +
+		"PHOTO;JPEG;ENCODING=BASE64:MIICajCCAdOgAwIBAgICBEUwDQYJ\n" +
+		"END:VCARD\n"
+
+	card, err := vcard.NewDecoder(strings.NewReader(input)).Decode()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected := []vcard.FieldFormatter{
+		vcard.N{FamilyName: "Person", GivenName: "Test"},
+		vcard.Tel{Types: []string{"work", "voice"}, Number: "+3701234567890"},
+		vcard.Property{Name: "NOTE", Params: vcard.Params{}, Value: "first line\r\nsecond line"},
+		vcard.Photo{Type: "JPEG", Base64Data: "MIICajCCAdOgAwIBAgICBEUwDQYJ"},
+	}
+	if !reflect.DeepEqual(card.Fields, expected) {
+		t.Fatalf("expected %#v, but got %#v", expected, card.Fields)
+	}
+}
+
+func TestParseInvalidFbURL(t *testing.T) {
+	cards, err := vcard.Parse(strings.NewReader("BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Forrest Gump\r\nFBURL:http://%zz\r\nEND:VCARD\r\n"))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := vcard.Property{Name: "FBURL", Params: vcard.Params{}, Value: "http://%zz"}
+	if len(cards) != 1 || len(cards[0].Fields) != 2 || !reflect.DeepEqual(cards[0].Fields[1], expected) {
+		t.Fatalf("expected the FBURL as property, but got %#v", cards)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tt := []struct {
+		input string
+		err   string
+	}{
+		{"FN:Test\n", "line 1: expected BEGIN:VCARD"},
+		{"BEGIN:VCARD\nVERSION:4.0\nFN:Test\n", "missing END:VCARD"},
+		{"BEGIN:VCARD\nFN:Test\nEND:VCARD\n", "line 3: missing VERSION"},
+		{"BEGIN:VCARD\nVERSION:4.0\nFN\nEND:VCARD\n", "line 3: invalid content line"},
+		{"BEGIN:VCARD\nVERSION:5.0\nEND:VCARD\n", "invalid version"},
+	}
+
+	for _, tc := range tt {
+		_, err := vcard.Parse(strings.NewReader(tc.input))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("expected error containing %q, but got: %v", tc.err, err)
+		}
+	}
+
+	if _, err := vcard.NewDecoder(strings.NewReader("\n\n")).Decode(); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got: %v", err)
+	}
+}
+
+func TestProperty(t *testing.T) {
+	tt := []struct {
+		field    vcard.Property
+		expected string
+	}{
+		{vcard.Property{Name: "x-qq", Value: "21588891"}, "X-QQ:21588891"},
+		{vcard.Property{Group: "item1", Name: "X-ABLabel", Value: "Other"}, "item1.X-ABLABEL:Other"},
+		{
+			vcard.Property{Name: "NOTE", Params: vcard.Params{"LANGUAGE": {"en"}, "ALTID": {"1"}}, Value: "Test"},
+			"NOTE;ALTID=1;LANGUAGE=en:Test",
+		},
+		{
+			vcard.Property{Name: "X-LABEL", Params: vcard.Params{"LABEL": {"Baytown, LA"}}, Value: "Test"},
+			`X-LABEL;LABEL="Baytown, LA":Test`,
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := tc.field.Format("4.0")
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if got != tc.expected {
+			t.Fatalf("expected %q, but got %q", tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package vcard
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// phoneRegion holds the dialing conventions of a region
+type phoneRegion struct {
+	// code is the country calling code
+	code string
+	// trunk is the prefix for national calls, which is dropped for international calls
+	trunk string
+	// exit is the prefix for international calls made from the region
+	exit string
+}
+
+// phoneRegions contains the dialing conventions keyed by ISO 3166-1 alpha-2 region code
+var phoneRegions = map[string]phoneRegion{
+	"AR": {"54", "0", "00"},
+	"AT": {"43", "0", "00"},
+	"AU": {"61", "0", "0011"},
+	"BE": {"32", "0", "00"},
+	"BG": {"359", "0", "00"},
+	"BR": {"55", "0", "00"},
+	"CA": {"1", "1", "011"},
+	"CH": {"41", "0", "00"},
+	"CN": {"86", "0", "00"},
+	"CZ": {"420", "", "00"},
+	"DE": {"49", "0", "00"},
+	"DK": {"45", "", "00"},
+	"EE": {"372", "", "00"},
+	"ES": {"34", "", "00"},
+	"FI": {"358", "0", "00"},
+	"FR": {"33", "0", "00"},
+	"GB": {"44", "0", "00"},
+	"GR": {"30", "", "00"},
+	"HK": {"852", "", "001"},
+	"HU": {"36", "06", "00"},
+	"IE": {"353", "0", "00"},
+	"IL": {"972", "0", "00"},
+	"IN": {"91", "0", "00"},
+	"IT": {"39", "", "00"},
+	"JP": {"81", "0", "010"},
+	"KR": {"82", "0", "001"},
+	"LT": {"370", "8", "00"},
+	"LU": {"352", "", "00"},
+	"LV": {"371", "", "00"},
+	"MX": {"52", "", "00"},
+	"NL": {"31", "0", "00"},
+	"NO": {"47", "", "00"},
+	"NZ": {"64", "0", "00"},
+	"PL": {"48", "", "00"},
+	"PT": {"351", "", "00"},
+	"RO": {"40", "0", "00"},
+	"RU": {"7", "8", "810"},
+	"SE": {"46", "0", "00"},
+	"SG": {"65", "", "000"},
+	"TR": {"90", "0", "00"},
+	"UA": {"380", "0", "00"},
+	"US": {"1", "1", "011"},
+	"ZA": {"27", "0", "00"},
+}
+
+// phoneTrunks contains the trunk prefixes keyed by country calling code, codes shared by several
+// regions take the prefix of the first region in alphabetical order
+var phoneTrunks = func() map[string]string {
+	regions := make([]string, 0, len(phoneRegions))
+	for r := range phoneRegions {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+	trunks := make(map[string]string)
+	for _, r := range regions {
+		if _, ok := trunks[phoneRegions[r].code]; !ok {
+			trunks[phoneRegions[r].code] = phoneRegions[r].trunk
+		}
+	}
+	return trunks
+}()
+
+// twoDigitCodes contains the country calling codes of two digits, the codes 1 and 7 have a single
+// digit and all others have three
+var twoDigitCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true,
+	"39": true, "40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true,
+	"48": true, "49": true, "51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true, "64": true, "65": true,
+	"66": true, "81": true, "82": true, "84": true, "86": true, "90": true, "91": true, "92": true,
+	"93": true, "94": true, "95": true, "98": true,
+}
+
+// splitCountryCode splits the country calling code from the digits of an international number
+func splitCountryCode(digits string) (code, rest string) {
+	n := 3
+	switch {
+	case digits == "":
+		return "", ""
+	case digits[0] == '1' || digits[0] == '7':
+		n = 1
+	case len(digits) >= 2 && twoDigitCodes[digits[:2]]:
+		n = 2
+	}
+	if len(digits) < n {
+		return "", digits
+	}
+	return digits[:n], digits[n:]
+}
+
+// phoneExtension matches the extension at the end of a phone number
+var phoneExtension = regexp.MustCompile(`(?i)(?:;\s*ext=|\s*(?:ext\.?|extension|x|#)\s*)(\d+)\s*$`)
+
+// phoneTrunkNotation matches the trunk prefix written in parentheses after the country code, as in
+// +44 (0)20 7946 0958
+var phoneTrunkNotation = regexp.MustCompile(`\(\s*0\s*\)`)
+
+// phoneLetters maps the letters on a phone keypad onto their digits
+var phoneLetters = strings.NewReplacer(
+	"A", "2", "B", "2", "C", "2", "D", "3", "E", "3", "F", "3", "G", "4", "H", "4", "I", "4",
+	"J", "5", "K", "5", "L", "5", "M", "6", "N", "6", "O", "6", "P", "7", "Q", "7", "R", "7", "S", "7",
+	"T", "8", "U", "8", "V", "8", "W", "9", "X", "9", "Y", "9", "Z", "9",
+)
+
+// PhoneNumber is a telephone number split into its country calling code, national significant
+// number and extension, which is the number without trunk prefix
+type PhoneNumber struct {
+	CountryCode string
+	Number      string
+	Extension   string
+}
+
+// ParsePhone parses a phone number as written in the region, an ISO 3166-1 alpha-2 code such as
+// "US" or "LT". Numbers starting with a plus, the international call prefix of the region or 00 are
+// international, other numbers are national numbers of the region and require it to be set.
+// Extensions such as "x123", "ext. 123" and ";ext=123", the letters of vanity numbers and a trunk
+// prefix in parentheses after the country code, as in "+44 (0)20 7946 0958", are supported.
+func ParsePhone(number, region string) (PhoneNumber, error) {
+	var p PhoneNumber
+	s := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(number), "tel:"))
+	if m := phoneExtension.FindStringSubmatchIndex(s); m != nil {
+		p.Extension = s[m[2]:m[3]]
+		s = s[:m[0]]
+	}
+	// parameters of tel URIs other than the extension, such as isub, don't matter here
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+
+	// the trunk prefix isn't dialed with the country code, unless it starts the number
+	if m := phoneTrunkNotation.FindStringIndex(s); m != nil && strings.TrimSpace(s[:m[0]]) != "" {
+		s = s[:m[0]] + " " + s[m[1]:]
+	}
+
+	international := strings.HasPrefix(s, "+")
+	digits := normalizePhone(phoneLetters.Replace(strings.ToUpper(s)))
+	if strings.ContainsAny(strings.Trim(s, "+"), "+") || digits == "" {
+		return p, fmt.Errorf("invalid phone number %q", number)
+	}
+
+	r, known := phoneRegions[strings.ToUpper(region)]
+	if region != "" && !known {
+		return p, fmt.Errorf("unknown region %q", region)
+	}
+	switch {
+	case international:
+	case known && r.exit != "" && strings.HasPrefix(digits, r.exit):
+		digits, international = digits[len(r.exit):], true
+	case strings.HasPrefix(digits, "00"):
+		digits, international = digits[2:], true
+	}
+
+	if international {
+		p.CountryCode, p.Number = splitCountryCode(digits)
+	} else {
+		if !known {
+			return p, fmt.Errorf("national phone number %q without region", number)
+		}
+		p.CountryCode, p.Number = r.code, digits
+		// the trunk prefix of NANP numbers is optional
+		if r.trunk != "" && strings.HasPrefix(digits, r.trunk) && (r.code != "1" || len(digits) == 11) {
+			p.Number = digits[len(r.trunk):]
+		}
+	}
+
+	switch {
+	case p.CountryCode == "":
+		return p, fmt.Errorf("invalid phone number %q", number)
+	case p.CountryCode == "1" && len(p.Number) != 10:
+		return p, fmt.Errorf("invalid phone number %q: NANP numbers have 10 digits", number)
+	case len(p.Number) < 4 || len(p.CountryCode)+len(p.Number) > 15:
+		return p, fmt.Errorf("invalid phone number %q: wrong number of digits", number)
+	}
+	return p, nil
+}
+
+// E164 returns the number in E.164 format, such as +14045551212, without extension
+func (p PhoneNumber) E164() string {
+	return "+" + p.CountryCode + p.Number
+}
+
+// String returns the number in E.164 format followed by the extension, such as +14045551212;ext=12
+func (p PhoneNumber) String() string {
+	if p.Extension != "" {
+		return p.E164() + ";ext=" + p.Extension
+	}
+	return p.E164()
+}
+
+// groups splits the national significant number into groups of digits for display
+func (p PhoneNumber) groups() []string {
+	n := p.Number
+	if p.CountryCode == "1" && len(n) == 10 {
+		return []string{n[:3], n[3:6], n[6:]}
+	}
+	var g []string
+	for len(n) > 4 {
+		g, n = append(g, n[:3]), n[3:]
+	}
+	return append(g, n)
+}
+
+// International returns the number formatted for international use, such as +1 404-555-1212 x12
+func (p PhoneNumber) International() string {
+	s := "+" + p.CountryCode + " " + strings.Join(p.groups(), "-")
+	if p.Extension != "" {
+		s += " x" + p.Extension
+	}
+	return s
+}
+
+// National returns the number formatted for use in its own country, such as (404) 555-1212 x12
+// or 8 612-345-67
+func (p PhoneNumber) National() string {
+	var s string
+	g := p.groups()
+	if p.CountryCode == "1" && len(g) == 3 {
+		s = fmt.Sprintf("(%s) %s-%s", g[0], g[1], g[2])
+	} else {
+		s = strings.Join(g, "-")
+		if trunk := phoneTrunks[p.CountryCode]; trunk != "" {
+			s = trunk + " " + s
+		}
+	}
+	if p.Extension != "" {
+		s += " x" + p.Extension
+	}
+	return s
+}
+
+// URI returns the number as global tel URI as defined by RFC 3966, such as tel:+14045551212;ext=12.
+// The digits aren't grouped, as the grouping of numbers differs per country.
+func (p PhoneNumber) URI() string {
+	return "tel:" + p.String()
+}
+
+// Parse parses the number of the Tel as written in the region, see ParsePhone
+func (f Tel) Parse(region string) (PhoneNumber, error) {
+	return ParsePhone(f.Number, region)
+}
+
+// Normalize returns the Tel with its number in E.164 format, including the extension if any
+func (f Tel) Normalize(region string) (Tel, error) {
+	p, err := f.Parse(region)
+	if err != nil {
+		return f, err
+	}
+	f.Number = p.String()
+	return f, nil
+}
@@ -0,0 +1,100 @@
+package vcard_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestParsePhone(t *testing.T) {
+	tests := []struct {
+		number   string
+		region   string
+		expected string
+	}{
+		{"+1-111-555-1212", "", "+11115551212"},
+		{"(404) 555 1212", "US", "+14045551212"},
+		{"1 404 555 1212", "US", "+14045551212"},
+		{"011 370 612 34567", "US", "+37061234567"},
+		{"00370 612 34567", "", "+37061234567"},
+		{"8 612 34567", "LT", "+37061234567"},
+		{"030 1234567", "de", "+49301234567"},
+		{"tel:+1-404-555-1212;ext=12", "", "+14045551212;ext=12"},
+		{"+1 404 555 1212 ext. 34", "", "+14045551212;ext=34"},
+		{"(404) 555-1212 x5", "US", "+14045551212;ext=5"},
+		{"1-800-FLOWERS", "US", "+18003569377"},
+		{"+44 (0)20 7946 0958", "", "+442079460958"},
+		{"0044 (0) 20 7946 0958", "GB", "+442079460958"},
+	}
+	for _, test := range tests {
+		p, err := vcard.ParsePhone(test.number, test.region)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if p.String() != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, p.String())
+		}
+	}
+
+	for _, number := range []string{"", "555-1212", "+1 555 1212", "+370 1234567890123456", "+1+2"} {
+		if _, err := vcard.ParsePhone(number, "US"); err == nil {
+			t.Fatalf("expected %q to fail", number)
+		}
+	}
+	if _, err := vcard.ParsePhone("404 555 1212", ""); err == nil {
+		t.Fatalf("expected a national number without region to fail")
+	}
+	if _, err := vcard.ParsePhone("404 555 1212", "XX"); err == nil {
+		t.Fatalf("expected an unknown region to fail")
+	}
+}
+
+func TestPhoneFormat(t *testing.T) {
+	tests := []struct {
+		number        string
+		international string
+		national      string
+		uri           string
+	}{
+		{"+14045551212", "+1 404-555-1212", "(404) 555-1212", "tel:+14045551212"},
+		{"+37061234567", "+370 612-345-67", "8 612-345-67", "tel:+37061234567"},
+		{"+1 404 555 1212 x12", "+1 404-555-1212 x12", "(404) 555-1212 x12", "tel:+14045551212;ext=12"},
+		{"+7 495 123 4567", "+7 495-123-4567", "8 495-123-4567", "tel:+74951234567"},
+		{"+420 601 123 456", "+420 601-123-456", "601-123-456", "tel:+420601123456"},
+	}
+	for _, test := range tests {
+		p, err := vcard.ParsePhone(test.number, "")
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		for _, c := range [][2]string{{p.International(), test.international}, {p.National(), test.national}, {p.URI(), test.uri}} {
+			if c[0] != c[1] {
+				t.Fatalf("expected %q, but got %q", c[1], c[0])
+			}
+		}
+	}
+}
+
+func TestTelNormalize(t *testing.T) {
+	tel, err := vcard.Tel{Types: []string{vcard.TelHome}, Number: "(404) 555-1212"}.Normalize("US")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if tel.Number != "+14045551212" || tel.Types[0] != vcard.TelHome {
+		t.Fatalf("expected %q, but got %q", "+14045551212", tel.Number)
+	}
+
+	// the tel URI of 4.0 is parsed back into the same number
+	cards, err := vcard.Parse(strings.NewReader("BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nTEL;TYPE=home;VALUE=uri:tel:+1-404-555-1212;ext=12\nEND:VCARD"))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	tel, _ = vcard.First[vcard.Tel](cards[0])
+	if l, _ := tel.Format("4.0"); l != "TEL;TYPE=home;VALUE=uri:tel:+14045551212;ext=12" {
+		t.Fatalf("expected the tel URI, but got %q", l)
+	}
+	if l, _ := tel.Format("3.0"); l != "TEL;TYPE=home:+1-404-555-1212;ext=12" {
+		t.Fatalf("expected the number as text, but got %q", l)
+	}
+}
@@ -0,0 +1,292 @@
+package vcard
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Params holds the parameters of a vCard property, keyed by their upper case name
+type Params map[string][]string
+
+// Get returns the first value of the named parameter, or an empty string if it isn't set
+func (p Params) Get(name string) string {
+	if v := p[strings.ToUpper(name)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Add appends a value to the named parameter
+func (p Params) Add(name, value string) {
+	name = strings.ToUpper(name)
+	p[name] = append(p[name], value)
+}
+
+// Has reports whether the named parameter is set
+func (p Params) Has(name string) bool {
+	_, ok := p[strings.ToUpper(name)]
+	return ok
+}
+
+// String returns the parameters as they appear in a content line, sorted by name
+// and including the leading semicolon
+func (p Params) String() string {
+	var b bytes.Buffer
+	for _, k := range sortedKeys(p) {
+		vals := make([]string, len(p[k]))
+		for i, v := range p[k] {
+			vals[i] = quoteParam(v)
+		}
+		fmt.Fprintf(&b, ";%s=%s", k, strings.Join(vals, ","))
+	}
+	return b.String()
+}
+
+func sortedKeys(p Params) []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteParam quotes a parameter value containing separators, parameter values can't contain quotes
+// so they are replaced by single quotes
+func quoteParam(v string) string {
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + strings.ReplaceAll(v, `"`, "'") + `"`
+	}
+	return v
+}
+
+// encodeParamValue encodes the newlines, carets and quotes of a parameter value as in RFC 6868
+func encodeParamValue(v string) string {
+	return strings.NewReplacer("^", "^^", "\r\n", "^n", "\n", "^n", `"`, "^'").Replace(v)
+}
+
+// decodeParamValue decodes a parameter value encoded as in RFC 6868, newlines written as \n by
+// clients predating it are decoded as well
+func decodeParamValue(v string) string {
+	return strings.NewReplacer("^^", "^", "^n", "\n", "^N", "\n", "^'", `"`, `\n`, "\n", `\N`, "\n").Replace(v)
+}
+
+// Property type definition to specify a property without a dedicated field type,
+// such as X- extension properties. It is used by the parser for every property it
+// doesn't know how to map onto a typed field, so no information is lost.
+type Property struct {
+	Group  string
+	Name   string
+	Params Params
+	Value  string
+}
+
+// Format implements the FieldFormatter interface
+func (f Property) Format(v string) (string, error) {
+	switch v {
+	case "2.1", "3.0", "4.0":
+		var b bytes.Buffer
+		if f.Group != "" {
+			fmt.Fprintf(&b, "%s.", f.Group)
+		}
+		fmt.Fprintf(&b, "%s%s:%s", strings.ToUpper(f.Name), f.Params.String(), f.Value)
+		return b.String(), nil
+	}
+	return "", ErrVersion
+}
+
+// properties returns the fields of the VCard as generic properties for the requested version
+func (v *VCard) properties(version string) ([]Property, error) {
+	props := make([]Property, 0, len(v.Fields))
+	for i := range v.Fields {
+		if a, ok := v.Fields[i].(Agent); ok && a.VCard != nil && version == "2.1" {
+			// the nested card of 2.1 spans several lines, the property holds it as escaped text as in 3.0
+			nested, err := a.nested(version)
+			if err != nil {
+				return nil, err
+			}
+			props = append(props, Property{Name: "AGENT", Params: Params{}, Value: escapeText(nested)})
+			continue
+		}
+		l, err := v.Fields[i].Format(version)
+		if err == ErrVersion {
+			return nil, fmt.Errorf("%T is an unsupported field for vCard version %s", v.Fields[i], version)
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines := []string{l}
+		if _, ok := v.Fields[i].(Adr); ok {
+			// the label of 2.1 and 3.0 is a property of its own
+			lines = strings.Split(l, "\n")
+		}
+		for _, l := range lines {
+			p, err := parseProperty(l)
+			if err != nil {
+				return nil, err
+			}
+			props = append(props, p)
+		}
+	}
+	return props, nil
+}
+
+// Properties returns the fields of the VCard as generic properties in the version of the VCard
+func (v *VCard) Properties() ([]Property, error) {
+	return v.properties(v.Version)
+}
+
+// Text returns the value with text escaping removed
+func (f Property) Text() string {
+	return unescapeText(f.Value)
+}
+
+// parseProperty splits a single unfolded content line into its group, name, parameters and raw value.
+// Parameters without a name, as allowed by 2.1 (TEL;WORK;VOICE:...), are stored as TYPE or ENCODING.
+func parseProperty(line string) (Property, error) {
+	p := Property{Params: Params{}}
+
+	// name and optional group
+	i := strings.IndexAny(line, ";:")
+	if i < 1 {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+	if dot := strings.LastIndex(p.Name, "."); dot >= 0 {
+		p.Group, p.Name = line[:dot], p.Name[dot+1:]
+	}
+	rest := line[i:]
+
+	// parameters, separated by unquoted semicolons and terminated by an unquoted colon
+	for len(rest) > 0 && rest[0] == ';' {
+		rest = rest[1:]
+		var (
+			quoted bool
+			end    = -1
+		)
+		for j := 0; j < len(rest); j++ {
+			if rest[j] == '"' {
+				quoted = !quoted
+			} else if !quoted && (rest[j] == ';' || rest[j] == ':') {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return p, fmt.Errorf("missing value in content line %q", line)
+		}
+		addParam(p.Params, rest[:end])
+		rest = rest[end:]
+	}
+
+	if len(rest) == 0 || rest[0] != ':' {
+		return p, fmt.Errorf("missing value in content line %q", line)
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
+
+func addParam(params Params, raw string) {
+	eq := strings.IndexByte(raw, '=')
+	if eq < 0 {
+		// 2.1 allows parameter values without a name
+		switch v := strings.ToUpper(raw); v {
+		case "BASE64", "QUOTED-PRINTABLE", "8BIT", "7BIT", "B":
+			params.Add("ENCODING", v)
+		default:
+			params.Add("TYPE", raw)
+		}
+		return
+	}
+
+	name := raw[:eq]
+	for _, v := range splitParamValues(raw[eq+1:]) {
+		params.Add(name, v)
+	}
+}
+
+func splitParamValues(s string) []string {
+	var (
+		vals   []string
+		quoted bool
+		cur    strings.Builder
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			vals = append(vals, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(vals, cur.String())
+}
+
+// unescapeText reverses the backslash escaping of text values
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// escapeText applies the backslash escaping of text values
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`).Replace(s)
+}
+
+// encodeQuotedPrintable encodes a text value of version 2.1 as quoted printable on a single line,
+// line breaks are written as CRLF
+func encodeQuotedPrintable(s string) string {
+	var b strings.Builder
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b.WriteString("=0D=0A")
+		case c == '=' || c < ' ' || c > '~':
+			fmt.Fprintf(&b, "=%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// splitComponents splits a structured value on unescaped separators and unescapes every component
+func splitComponents(s string, sep byte) []string {
+	var (
+		parts []string
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeText(s[start:]))
+}
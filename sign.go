@@ -0,0 +1,251 @@
+package vcard
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SignatureProperty is the property holding the embedded signature of a VCard, which is excluded
+// from the signed content
+const SignatureProperty = "X-SIGNATURE"
+
+// ErrSignature is returned when a signature doesn't match the VCard
+var ErrSignature = errors.New("invalid vCard signature")
+
+// jwsHeader is the protected header of a JWS signature
+type jwsHeader struct {
+	Alg string   `json:"alg"`
+	Cty string   `json:"cty"`
+	X5c []string `json:"x5c,omitempty"`
+}
+
+// signingInput returns the content that is signed, which is the canonical form of the VCard
+// without the signature, so that the order of the properties and parameters and the folding of the
+// lines don't change it
+func (v *VCard) signingInput() ([]byte, error) {
+	return v.canonicalForm(SignatureProperty)
+}
+
+// jwsAlgorithm returns the JWS algorithm and hash for a public key
+func jwsAlgorithm(pub crypto.PublicKey) (string, crypto.Hash, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return "ES256", crypto.SHA256, nil
+		case 384:
+			return "ES384", crypto.SHA384, nil
+		case 521:
+			return "ES512", crypto.SHA512, nil
+		}
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return "EdDSA", 0, nil
+	}
+	return "", 0, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// SignJWS returns a JWS with detached payload, as in RFC 7515 appendix F, signing the canonical
+// form of the VCard. The certificate chain, if any, is included in the x5c header and should start
+// with the certificate of the key. ECDSA, RSA and Ed25519 keys are supported.
+func (v *VCard) SignJWS(key crypto.Signer, chain ...*x509.Certificate) (string, error) {
+	alg, hash, err := jwsAlgorithm(key.Public())
+	if err != nil {
+		return "", err
+	}
+	h := jwsHeader{Alg: alg, Cty: "text/vcard"}
+	for _, c := range chain {
+		h.X5c = append(h.X5c, base64.StdEncoding.EncodeToString(c.Raw))
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	payload, err := v.signingInput()
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := []byte(input)
+	if hash != 0 {
+		d := hash.New()
+		d.Write(digest)
+		digest = d.Sum(nil)
+	}
+	sig, err := key.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		return "", err
+	}
+	if pub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		// JWS uses the fixed size concatenation of r and s instead of ASN.1
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			return "", err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		rs.R.FillBytes(sig[:size])
+		rs.S.FillBytes(sig[size:])
+	}
+	return base64.RawURLEncoding.EncodeToString(header) + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Sign embeds a JWS signature of the VCard as X-SIGNATURE property, replacing an existing one, see
+// SignJWS
+func (v *VCard) Sign(key crypto.Signer, chain ...*x509.Certificate) error {
+	jws, err := v.SignJWS(key, chain...)
+	if err != nil {
+		return err
+	}
+	v.removeSignature()
+	v.Fields = append(v.Fields, Property{Name: SignatureProperty, Params: Params{"VALUE": {"jws"}}, Value: jws})
+	return nil
+}
+
+// removeSignature removes the embedded signatures
+func (v *VCard) removeSignature() {
+	fields := v.Fields[:0:0]
+	for _, f := range v.Fields {
+		if p, ok := f.(Property); !ok || propertyName(p) != SignatureProperty {
+			fields = append(fields, f)
+		}
+	}
+	v.Fields = fields
+}
+
+// signature returns the JWS, or the embedded signature if it is empty
+func (v *VCard) signature(jws string) (string, error) {
+	if jws != "" {
+		return jws, nil
+	}
+	for _, f := range v.Fields {
+		if p, ok := f.(Property); ok && propertyName(p) == SignatureProperty {
+			return p.Value, nil
+		}
+	}
+	return "", fmt.Errorf("vCard has no %s property", SignatureProperty)
+}
+
+// parseJWS splits a detached JWS into its header, signing input and signature
+func (v *VCard) parseJWS(jws string) (jwsHeader, string, []byte, error) {
+	var h jwsHeader
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return h, "", nil, fmt.Errorf("invalid detached JWS")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return h, "", nil, fmt.Errorf("invalid JWS header: %v", err)
+	}
+	if err := json.Unmarshal(header, &h); err != nil {
+		return h, "", nil, fmt.Errorf("invalid JWS header: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return h, "", nil, fmt.Errorf("invalid JWS signature: %v", err)
+	}
+	payload, err := v.signingInput()
+	if err != nil {
+		return h, "", nil, err
+	}
+	return h, parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload), sig, nil
+}
+
+// Verify checks a detached JWS signature of the VCard with the public key. If jws is empty the
+// embedded X-SIGNATURE property is checked. It returns ErrSignature if the signature doesn't match.
+func (v *VCard) Verify(jws string, key crypto.PublicKey) error {
+	jws, err := v.signature(jws)
+	if err != nil {
+		return err
+	}
+	h, input, sig, err := v.parseJWS(jws)
+	if err != nil {
+		return err
+	}
+	alg, hash, err := jwsAlgorithm(key)
+	if err != nil {
+		return err
+	}
+	if h.Alg != alg {
+		return fmt.Errorf("JWS algorithm %q doesn't match the %s key", h.Alg, alg)
+	}
+	digest := []byte(input)
+	if hash != 0 {
+		d := hash.New()
+		d.Write(digest)
+		digest = d.Sum(nil)
+	}
+	var ok bool
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) == 2*size {
+			ok = ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:]))
+		}
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, digest, sig)
+	}
+	if !ok {
+		return ErrSignature
+	}
+	return nil
+}
+
+// VerifyChain checks a signature like Verify with the key of the first certificate of the chain,
+// after verifying the chain with the options. If the chain is empty, the x5c header of the JWS is
+// used. Unless set in the options, any extended key usage is accepted. It returns the certificate
+// of the signer.
+func (v *VCard) VerifyChain(jws string, chain []*x509.Certificate, opts x509.VerifyOptions) (*x509.Certificate, error) {
+	jws, err := v.signature(jws)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		h, _, _, err := v.parseJWS(jws)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range h.X5c {
+			der, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x5c certificate: %v", err)
+			}
+			c, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x5c certificate: %v", err)
+			}
+			chain = append(chain, c)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("missing certificate chain")
+	}
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	if _, err := chain[0].Verify(opts); err != nil {
+		return nil, err
+	}
+	return chain[0], v.Verify(jws, chain[0].PublicKey)
+}
@@ -0,0 +1,168 @@
+package vcard_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func staffCard() *vcard.VCard {
+	return &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{"Forrest Gump"},
+		vcard.N{FamilyName: "Gump", GivenName: "Forrest"},
+		vcard.Org{Name: "Bubba Gump Shrimp Co."},
+		vcard.Title{"Shrimp Man"},
+		vcard.Email{Types: []string{"work", "pref"}, Email: "forrest@example.com"},
+		vcard.Tel{Types: []string{"work", "voice"}, Number: "+1-111-555-1212"},
+	}}
+}
+
+// reserialize generates the card, reverses the order of its properties, folds its lines and
+// parses it again
+func reserialize(t *testing.T, card *vcard.VCard) *vcard.VCard {
+	s, err := card.Generate()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	lines := strings.Split(s, "\n")
+	body := lines[2 : len(lines)-1]
+	for i, j := 0, len(body)-1; i < j; i, j = i+1, j-1 {
+		body[i], body[j] = body[j], body[i]
+	}
+	for i, l := range body {
+		var folded []string
+		for len(l) > 20 {
+			folded = append(folded, l[:20])
+			l = l[20:]
+		}
+		body[i] = strings.Join(append(folded, l), "\r\n ")
+	}
+	parsed, err := vcard.Parse(strings.NewReader(strings.Join(lines, "\r\n")))
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return parsed[0]
+}
+
+func TestSignJWS(t *testing.T) {
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, key := range []crypto.Signer{ec, rsaKey, ed} {
+		card := staffCard()
+		jws, err := card.SignJWS(key)
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if parts := strings.Split(jws, "."); len(parts) != 3 || parts[1] != "" {
+			t.Fatalf("expected a detached JWS, but got %q", jws)
+		}
+		if err := reserialize(t, card).Verify(jws, key.Public()); err != nil {
+			t.Fatalf("expected %T signature to pass, but got: %v", key, err)
+		}
+
+		card.Fields = append(card.Fields, vcard.Title{"Captain"})
+		if err := card.Verify(jws, key.Public()); err != vcard.ErrSignature {
+			t.Fatalf("expected err %v, but got: %v", vcard.ErrSignature, err)
+		}
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jws, _ := staffCard().SignJWS(ec)
+	if err := staffCard().Verify(jws, &other.PublicKey); err != vcard.ErrSignature {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrSignature, err)
+	}
+	if err := staffCard().Verify(jws, rsaKey.Public()); err == nil {
+		t.Fatalf("expected a key of the wrong type to fail")
+	}
+}
+
+func TestSignEmbedded(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	card := staffCard()
+	if err := card.Sign(key); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := card.Sign(key); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if n := len(vcard.All[vcard.Property](card)); n != 1 {
+		t.Fatalf("expected 1 signature, but got %d", n)
+	}
+
+	parsed := reserialize(t, card)
+	if err := parsed.Verify("", &key.PublicKey); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	parsed.Fields[1] = vcard.N{FamilyName: "Gump", GivenName: "Bubba"}
+	if err := parsed.Verify("", &key.PublicKey); err != vcard.ErrSignature {
+		t.Fatalf("expected err %v, but got: %v", vcard.ErrSignature, err)
+	}
+	if err := staffCard().Verify("", &key.PublicKey); err == nil {
+		t.Fatalf("expected a card without signature to fail")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Bubba Gump Staff CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	ca, _ = x509.ParseCertificate(der)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Forrest Gump"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, _ = x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	leaf, _ = x509.ParseCertificate(der)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	card := staffCard()
+	if err := card.Sign(key, leaf); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	signer, err := reserialize(t, card).VerifyChain("", nil, x509.VerifyOptions{Roots: roots})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if signer.Subject.CommonName != "Forrest Gump" {
+		t.Fatalf("expected %q, but got %q", "Forrest Gump", signer.Subject.CommonName)
+	}
+
+	jws, _ := card.SignJWS(key)
+	if _, err := card.VerifyChain(jws, []*x509.Certificate{leaf}, x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if _, err := card.VerifyChain(jws, nil, x509.VerifyOptions{Roots: roots}); err == nil {
+		t.Fatalf("expected a JWS without chain to fail")
+	}
+	if _, err := card.VerifyChain("", nil, x509.VerifyOptions{Roots: x509.NewCertPool()}); err == nil {
+		t.Fatalf("expected an untrusted chain to fail")
+	}
+}
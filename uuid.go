@@ -0,0 +1,26 @@
+package vcard
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewUUID returns a random version 4 UUID, such as 0f8fad5b-d9cb-469f-a165-70867728950e
+func NewUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// newUID returns a random UID as urn:uuid URI, as RFC 6350 recommends
+func newUID() (string, error) {
+	u, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	return "urn:uuid:" + u, nil
+}
@@ -0,0 +1,19 @@
+package vcard_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestNewUUID(t *testing.T) {
+	a, err := vcard.NewUUID()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	b, _ := vcard.NewUUID()
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(a) || a == b {
+		t.Fatalf("expected distinct version 4 UUIDs, but got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,258 @@
+package vcard
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// URISchemes contains the URI schemes allowed per property, properties which aren't listed allow any
+// scheme. It can be changed to allow additional schemes. The schemes of IMPP are those of the
+// IMPPPlatforms.
+var URISchemes = map[string][]string{
+	"PHOTO":  {"http", "https", "data"},
+	"FBURL":  {"http", "https", "ftp", "webcal"},
+	"URL":    {"http", "https", "ftp"},
+	"SOURCE": {"http", "https", "ldap", "ldaps"},
+}
+
+// allowedScheme returns an error if the scheme isn't allowed for the property
+func allowedScheme(property, scheme string) error {
+	if scheme == "" {
+		return fmt.Errorf("missing URI scheme")
+	}
+	allowed, ok := URISchemes[property]
+	if !ok {
+		return nil
+	}
+	for _, s := range allowed {
+		if strings.EqualFold(s, scheme) {
+			return nil
+		}
+	}
+	return fmt.Errorf("URI scheme %q isn't allowed for %s", scheme, property)
+}
+
+// asciiDomain returns a domain name in lower case with international labels converted to punycode
+func asciiDomain(domain string) (string, error) {
+	d, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+	return strings.ToLower(d), nil
+}
+
+// normalizeURI checks a URI against the schemes allowed for the property and returns it with the
+// scheme and host in lower case and an international host converted to punycode
+func normalizeURI(property string, u *url.URL) (*url.URL, error) {
+	if u == nil {
+		return nil, fmt.Errorf("missing URI")
+	}
+	if err := allowedScheme(property, u.Scheme); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp", "webcal", "ldap", "ldaps":
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("missing host in %q", u)
+		}
+	}
+	return uriSyntax(u)
+}
+
+// uriSyntax returns a URI with the scheme and host in lower case and an international host
+// converted to punycode, regardless of its property
+func uriSyntax(u *url.URL) (*url.URL, error) {
+	n := *u
+	n.Scheme = strings.ToLower(u.Scheme)
+	if h := n.Hostname(); h != "" {
+		host, err := asciiDomain(h)
+		if err != nil {
+			return nil, err
+		}
+		if p := n.Port(); p != "" {
+			host += ":" + p
+		}
+		n.Host = host
+	}
+	return &n, nil
+}
+
+// Normalize returns the email address without mailto: prefix and display name, and with its domain
+// in lower case and converted to punycode. X.400 addresses are returned as they are.
+func (f Email) Normalize() (Email, error) {
+	if hasType(f.Types, EmailX400) {
+		return f, nil
+	}
+	s := strings.TrimSpace(f.Email)
+	if len(s) >= 7 && strings.EqualFold(s[:7], "mailto:") {
+		s = s[7:]
+	}
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		s = unescaped
+	}
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return f, fmt.Errorf("invalid email address %q: %v", f.Email, err)
+	}
+	at := strings.LastIndexByte(a.Address, '@')
+	domain, err := asciiDomain(a.Address[at+1:])
+	if err != nil {
+		return f, err
+	}
+	f.Email = a.Address[:at+1] + domain
+	return f, nil
+}
+
+// Validate returns an error if the email address is invalid
+func (f Email) Validate() error {
+	_, err := f.Normalize()
+	return err
+}
+
+// Normalize returns the FbURL with its scheme and host in lower case and its host in punycode
+func (f FbURL) Normalize() (FbURL, error) {
+	u, err := normalizeURI("FBURL", f.URL)
+	return FbURL{u}, err
+}
+
+// Validate returns an error if the URL is missing or its scheme isn't allowed, see URISchemes
+func (f FbURL) Validate() error {
+	_, err := f.Normalize()
+	return err
+}
+
+// Normalize returns the Photo with the scheme and host of its URI in lower case and its host in punycode
+func (f Photo) Normalize() (Photo, error) {
+	if f.URI == nil {
+		return f, nil
+	}
+	u, err := normalizeURI("PHOTO", f.URI)
+	f.URI = u
+	return f, err
+}
+
+// Validate returns an error if the Photo has no data and its URI is missing or has a scheme which
+// isn't allowed, see URISchemes
+func (f Photo) Validate() error {
+	if f.URI == nil && f.Base64Data == "" {
+		return fmt.Errorf("photo without URI or data")
+	}
+	_, err := f.Normalize()
+	return err
+}
+
+// uriScheme matches the URI schemes of RFC 3986
+var uriScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+
+// Normalize returns the IMPP with the URI scheme of its platform, see IMPPPlatforms, and the domain
+// of a handle such as user@example.com in lower case and punycode. Platforms which aren't
+// registered are accepted with their name in lower case if it is a valid URI scheme.
+func (f IMPP) Normalize() (IMPP, error) {
+	platform, ok := LookupIMPP(f.Platform)
+	if !ok {
+		if !uriScheme.MatchString(f.Platform) {
+			return f, fmt.Errorf("invalid IMPP platform %q", f.Platform)
+		}
+		platform = IMPPPlatform{Name: f.Platform, Scheme: strings.ToLower(f.Platform)}
+	}
+	f.Platform = platform.Scheme
+	if f.Handle == "" || strings.ContainsAny(f.Handle, " \t\r\n") {
+		return f, fmt.Errorf("invalid IMPP handle %q", f.Handle)
+	}
+	if at := strings.LastIndexByte(f.Handle, '@'); at > 0 {
+		// XMPP addresses may end in a resource such as user@example.com/mobile
+		domain, resource := f.Handle[at+1:], ""
+		if i := strings.IndexByte(domain, '/'); i >= 0 {
+			domain, resource = domain[:i], domain[i:]
+		}
+		domain, err := asciiDomain(domain)
+		if err != nil {
+			return f, err
+		}
+		f.Handle = f.Handle[:at+1] + domain + resource
+	}
+	if platform.Handle != nil && !platform.Handle.MatchString(f.Handle) {
+		return f, fmt.Errorf("invalid %s handle %q", platform.Name, f.Handle)
+	}
+	return f, nil
+}
+
+// Validate returns an error if the platform isn't a valid URI scheme or the handle is invalid for
+// its platform in IMPPPlatforms
+func (f IMPP) Validate() error {
+	_, err := f.Normalize()
+	return err
+}
+
+// Normalize returns the property with its URI normalized if it is a property with a URI value listed
+// in URISchemes, other properties are returned as they are
+func (f Property) Normalize() (Property, error) {
+	name := strings.ToUpper(f.Name)
+	if _, ok := URISchemes[name]; !ok || f.Group != "" || name == "PHOTO" {
+		return f, nil
+	}
+	u, err := url.Parse(f.Value)
+	if err != nil {
+		return f, err
+	}
+	if u, err = normalizeURI(name, u); err != nil {
+		return f, err
+	}
+	f.Value = u.String()
+	return f, nil
+}
+
+// Validate returns an error if the property has a URI value which is invalid, see Normalize
+func (f Property) Validate() error {
+	_, err := f.Normalize()
+	return err
+}
+
+// validateFields returns an error for the first field which is invalid
+func (v *VCard) validateFields() error {
+	for _, f := range v.Fields {
+		if val, ok := f.(interface{ Validate() error }); ok {
+			if err := val.Validate(); err != nil {
+				return fmt.Errorf("invalid %T: %v", f, err)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeField returns the field normalized if it is an email address, URI or IMPP handle
+func normalizeField(f FieldFormatter) (FieldFormatter, error) {
+	switch n := f.(type) {
+	case Email:
+		return n.Normalize()
+	case FbURL:
+		return n.Normalize()
+	case Photo:
+		return n.Normalize()
+	case IMPP:
+		return n.Normalize()
+	case Property:
+		return n.Normalize()
+	}
+	return f, nil
+}
+
+// Normalize normalizes the email addresses, URIs and IMPP handles of the VCard, it returns an error
+// for the first invalid field and leaves the VCard unchanged in that case
+func (v *VCard) Normalize() error {
+	fields := make([]FieldFormatter, len(v.Fields))
+	for i, f := range v.Fields {
+		n, err := normalizeField(f)
+		if err != nil {
+			return fmt.Errorf("invalid %T: %v", f, err)
+		}
+		fields[i] = n
+	}
+	v.Fields = fields
+	return nil
+}
@@ -0,0 +1,106 @@
+package vcard_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestEmailNormalize(t *testing.T) {
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"forrest@example.com", "forrest@example.com"},
+		{"mailto:Forrest@Example.COM", "Forrest@example.com"},
+		{"Forrest Gump <forrest@example.com>", "forrest@example.com"},
+		{"jenny@bücher.example", "jenny@xn--bcher-kva.example"},
+	}
+	for _, test := range tests {
+		e, err := vcard.Email{Email: test.email}.Normalize()
+		if err != nil {
+			t.Fatalf("expected to pass, but got: %v", err)
+		}
+		if e.Email != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, e.Email)
+		}
+	}
+
+	for _, email := range []string{"", "forrest", "forrest@", "forrest@exa mple.com", "a@b@c"} {
+		if err := (vcard.Email{Email: email}).Validate(); err == nil {
+			t.Fatalf("expected %q to fail", email)
+		}
+	}
+	if err := (vcard.Email{Types: []string{vcard.EmailX400}, Email: "G=Forrest;S=Gump;O=Bubba"}).Validate(); err != nil {
+		t.Fatalf("expected X.400 addresses to pass, but got: %v", err)
+	}
+}
+
+func TestURIValidation(t *testing.T) {
+	tests := []struct {
+		field vcard.FieldFormatter
+		valid bool
+	}{
+		{vcard.Photo{URI: &url.URL{Scheme: "https", Host: "www.example.com", Path: "/forrest.gif"}}, true},
+		{vcard.Photo{URI: &url.URL{Scheme: "javascript", Opaque: "alert(1)"}}, false},
+		{vcard.Photo{URI: &url.URL{Scheme: "http", Path: "/forrest.gif"}}, false},
+		{vcard.Photo{}, false},
+		{vcard.FbURL{&url.URL{Scheme: "https", Host: "example.com", Path: "/busy"}}, true},
+		{vcard.FbURL{&url.URL{Scheme: "file", Path: "/etc/passwd"}}, false},
+		{vcard.FbURL{}, false},
+		{vcard.IMPP{Platform: "XMPP", Handle: "forrest@example.com"}, true},
+		{vcard.IMPP{Platform: "x-apple", Handle: "forrest"}, true},
+		{vcard.IMPP{Platform: "signal", Handle: "+123456789"}, true},
+		{vcard.IMPP{Platform: "not a scheme", Handle: "forrest"}, false},
+		{vcard.IMPP{Platform: "skype", Handle: "forrest gump"}, false},
+		{vcard.Property{Name: "URL", Value: "https://example.com/forrest"}, true},
+		{vcard.Property{Name: "URL", Value: "javascript:alert(1)"}, false},
+		{vcard.Property{Name: "X-URL", Value: "javascript:alert(1)"}, true},
+	}
+	for _, test := range tests {
+		card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{"Forrest Gump"}, test.field}}
+		if err := card.Validate(); (err == nil) != test.valid {
+			t.Fatalf("expected %+v to be valid %t, but got: %v", test.field, test.valid, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.FN{"Forrest Gump"},
+		vcard.Email{Email: "MAILTO:forrest@EXAMPLE.com"},
+		vcard.IMPP{Platform: "XMPP", Handle: "forrest@Bücher.example"},
+		vcard.FbURL{&url.URL{Scheme: "HTTPS", Host: "Bücher.example", Path: "/Busy"}},
+		vcard.Property{Name: "URL", Value: "HTTPS://Example.COM/Forrest"},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := card.Normalize(); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected := "BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nEMAIL:forrest@example.com\nIMPP:xmpp:forrest@xn--bcher-kva.example\n" +
+		"FBURL:https://xn--bcher-kva.example/Busy\nURL:https://example.com/Forrest\nEND:VCARD"
+	if got, _ := card.Generate(); got != expected {
+		t.Fatalf("expected %q, but got %q", expected, got)
+	}
+
+	card.Fields = append(card.Fields, vcard.Email{Email: "broken"})
+	if err := card.Normalize(); err == nil || len(card.Fields) != 6 {
+		t.Fatalf("expected to fail without changes, but got: %v", err)
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:4.0\nFN:Forrest Gump\nEMAIL:not an address\nEND:VCARD\n"
+	if _, err := vcard.NewDecoder(strings.NewReader(input)).Decode(); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	d := vcard.NewDecoder(strings.NewReader(input))
+	d.Strict = true
+	if _, err := d.Decode(); err == nil {
+		t.Fatalf("expected the invalid email address to fail")
+	}
+}
@@ -71,9 +71,19 @@ func (v *VCard) fieldMap() map[reflect.Type]int {
 // 	return false
 // }
 
-// Validate checks whether a VCard is valid by checking if all required fields are set for the version
-// and if provided fields are supported by the required version
+// Validate checks whether a VCard is valid by checking if all required fields are set for the version,
+// if provided fields are supported by the required version and if email addresses, URIs and IMPP
+// handles are valid
 func (v *VCard) Validate() error {
+	if err := v.validateRequired(); err != nil {
+		return err
+	}
+	return v.validateFields()
+}
+
+// validateRequired checks whether all required fields are set and all fields are supported by the
+// version, without checking their values
+func (v *VCard) validateRequired() error {
 	for i := range v.Fields {
 		if _, err := v.Fields[i].Format(v.Version); err == ErrVersion {
 			return fmt.Errorf("%T is an unsupported field for vCard version %s", v.Fields[i], v.Version)
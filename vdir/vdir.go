@@ -0,0 +1,481 @@
+// Package vdir stores VCards in a vdir, the directory layout used by vdirsyncer and khard: a
+// directory per address book with one .vcf file per card, named after its UID, and optional
+// metadata files such as displayname and color.
+package vdir
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+var (
+	// ErrNotFound is returned when a card file doesn't exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrExists is returned by Create when a card file with the same name already exists
+	ErrExists = errors.New("already exists")
+
+	// ErrConflict is returned by Update and Delete when the card file changed on disk since it was loaded
+	ErrConflict = errors.New("changed on disk since it was loaded")
+)
+
+// FileError reports a card file which couldn't be read or parsed
+type FileError struct {
+	Name string
+	Err  error
+}
+
+// Error implements the error interface
+func (err *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Name, err.Err)
+}
+
+// FileErrors collects the errors of all card files which couldn't be loaded by LoadAll
+type FileErrors []*FileError
+
+// Error implements the error interface
+func (errs FileErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Ext is the extension of card files
+const Ext = ".vcf"
+
+// Item is a card loaded from a vdir
+type Item struct {
+	// Name is the file name of the card within the vdir
+	Name string
+	// ETag identifies the content of the file when it was loaded or saved
+	ETag    string
+	ModTime time.Time
+	Card    *vcard.VCard
+}
+
+// fileState is the last known state of a card file, the hash is only recomputed when the size or
+// modification time changed
+type fileState struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// Dir is a single vdir collection. It is safe for concurrent use. Other processes, such as
+// vdirsyncer, may change the directory at the same time: files are replaced atomically, so readers
+// never see a partial card, and Create never overwrites a file. The ETag check of Update and Delete
+// isn't atomic with the write across processes though, so a change made by another process in
+// between can be overwritten or deleted, as the vdir format has no locking.
+type Dir struct {
+	path  string
+	mu    sync.Mutex
+	files map[string]fileState
+}
+
+// Open returns the vdir collection in an existing directory
+func Open(path string) (*Dir, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
+	}
+	return &Dir{path: path, files: make(map[string]fileState)}, nil
+}
+
+// Path returns the directory of the collection
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// validName reports whether a name is a card file of the vdir, hidden files are temporary files
+func validName(name string) bool {
+	return strings.HasSuffix(name, Ext) && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// FileName returns the file name of a card with the UID. UIDs which aren't safe as a file name
+// are hashed, like vdirsyncer does.
+func FileName(uid string) string {
+	safe := uid != ""
+	for _, r := range uid {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_.-+@", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe && !strings.HasPrefix(uid, ".") {
+		return uid + Ext
+	}
+	sum := sha256.Sum256([]byte(uid))
+	return hex.EncodeToString(sum[:]) + Ext
+}
+
+// stat returns the current state of a card file, reading it only when it changed since the last stat
+func (d *Dir) stat(name string) (fileState, error) {
+	fi, err := os.Stat(filepath.Join(d.path, name))
+	if os.IsNotExist(err) {
+		delete(d.files, name)
+		return fileState{}, ErrNotFound
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	if old, ok := d.files[name]; ok && old.size == fi.Size() && old.modTime.Equal(fi.ModTime()) {
+		return old, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(d.path, name))
+	if os.IsNotExist(err) {
+		delete(d.files, name)
+		return fileState{}, ErrNotFound
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	s := fileState{size: fi.Size(), modTime: fi.ModTime(), etag: vcard.ETag(data)}
+	d.files[name] = s
+	return s, nil
+}
+
+// List returns the names of the card files, sorted
+func (d *Dir) List() ([]string, error) {
+	files, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range files {
+		if !fi.IsDir() && validName(fi.Name()) {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads a single card file
+func (d *Dir) Load(name string) (Item, error) {
+	if !validName(name) {
+		return Item{}, ErrNotFound
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// stat before reading, so a change after the read shows up as a new modification time
+	fi, err := os.Stat(filepath.Join(d.path, name))
+	if err == nil {
+		var data []byte
+		if data, err = ioutil.ReadFile(filepath.Join(d.path, name)); err == nil {
+			st := fileState{size: fi.Size(), modTime: fi.ModTime(), etag: vcard.ETag(data)}
+			d.files[name] = st
+			return parseItem(name, data, st)
+		}
+	}
+	if os.IsNotExist(err) {
+		delete(d.files, name)
+		return Item{}, ErrNotFound
+	}
+	return Item{}, err
+}
+
+// parseItem parses the content of a card file
+func parseItem(name string, data []byte, s fileState) (Item, error) {
+	cards, err := vcard.Parse(bytes.NewReader(data))
+	if err != nil {
+		return Item{}, &FileError{Name: name, Err: err}
+	}
+	if len(cards) != 1 {
+		return Item{}, &FileError{Name: name, Err: fmt.Errorf("expected exactly one card, got %d", len(cards))}
+	}
+	return Item{Name: name, ETag: s.etag, ModTime: s.modTime, Card: cards[0]}, nil
+}
+
+// LoadAll reads all card files. Files which can't be read or don't hold exactly one card are
+// reported in a FileErrors, along with the other cards.
+func (d *Dir) LoadAll() ([]Item, error) {
+	names, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(names))
+	var errs FileErrors
+	for _, name := range names {
+		item, err := d.Load(name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			fe, ok := err.(*FileError)
+			if !ok {
+				fe = &FileError{Name: name, Err: err}
+			}
+			errs = append(errs, fe)
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(errs) > 0 {
+		return items, errs
+	}
+	return items, nil
+}
+
+// Create writes a new card file named after the UID of the card, a UID is added to the card when
+// it has none. It returns ErrExists if the file already exists.
+func (d *Dir) Create(card *vcard.VCard) (Item, error) {
+	uid := card.UID()
+	if uid == "" {
+		var err error
+		if uid, err = vcard.NewUUID(); err != nil {
+			return Item{}, err
+		}
+		card.Fields = append(card.Fields, vcard.Property{Name: "UID", Value: uid})
+	}
+	return d.write(Item{Name: FileName(uid), Card: card}, true)
+}
+
+// Update writes the card of an item loaded before. It returns ErrConflict if the file changed on
+// disk since it was loaded, or was deleted.
+func (d *Dir) Update(item Item) (Item, error) {
+	if !validName(item.Name) {
+		return Item{}, ErrNotFound
+	}
+	return d.write(item, false)
+}
+
+func (d *Dir) write(item Item, create bool) (Item, error) {
+	data, err := item.Card.Generate()
+	if err != nil {
+		return Item{}, err
+	}
+	b := []byte(data + "\n")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur, err := d.stat(item.Name)
+	switch {
+	case err == ErrNotFound && create:
+	case err == ErrNotFound:
+		return Item{}, ErrConflict
+	case err != nil:
+		return Item{}, err
+	case create:
+		return Item{}, ErrExists
+	case cur.etag != item.ETag:
+		return Item{}, ErrConflict
+	}
+
+	// the temporary file is hidden, so other vdir tools ignore it
+	tmp, err := ioutil.TempFile(d.path, "."+item.Name+".")
+	if err != nil {
+		return Item{}, err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Item{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Item{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return Item{}, err
+	}
+	path := filepath.Join(d.path, item.Name)
+	if create {
+		// link fails when another process created the file in the meantime
+		if err := os.Link(tmp.Name(), path); err != nil {
+			os.Remove(tmp.Name())
+			if os.IsExist(err) {
+				return Item{}, ErrExists
+			}
+			return Item{}, err
+		}
+		os.Remove(tmp.Name())
+	} else if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return Item{}, err
+	}
+
+	// forget the old state, the new file may have the same size and modification time
+	delete(d.files, item.Name)
+	s, err := d.stat(item.Name)
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Name: item.Name, ETag: s.etag, ModTime: s.modTime, Card: item.Card}, nil
+}
+
+// Delete removes the card file of an item loaded before. It returns ErrConflict if the file changed
+// on disk since it was loaded and ErrNotFound if it no longer exists.
+func (d *Dir) Delete(item Item) error {
+	if !validName(item.Name) {
+		return ErrNotFound
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur, err := d.stat(item.Name)
+	if err != nil {
+		return err
+	}
+	if cur.etag != item.ETag {
+		return ErrConflict
+	}
+	if err := os.Remove(filepath.Join(d.path, item.Name)); err != nil {
+		return err
+	}
+	delete(d.files, item.Name)
+	return nil
+}
+
+// Meta returns the content of a metadata file of the collection, such as displayname or color,
+// or an empty string if it doesn't exist
+func (d *Dir) Meta(key string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(d.path, key))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(b)), err
+}
+
+// SetMeta writes a metadata file of the collection
+func (d *Dir) SetMeta(key, value string) error {
+	if key == "" || strings.HasSuffix(key, Ext) || strings.ContainsAny(key, "/\\\x00") {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+	return ioutil.WriteFile(filepath.Join(d.path, key), []byte(value), 0644)
+}
+
+// Op is the kind of change to a card file
+type Op int
+
+const (
+	// Created indicates a new card file
+	Created Op = iota
+	// Modified indicates a card file with new content
+	Modified
+	// Deleted indicates a removed card file
+	Deleted
+)
+
+// String implements the fmt.Stringer interface
+func (op Op) String() string {
+	switch op {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Event is a change to a card file reported by Watch
+type Event struct {
+	Name string
+	Op   Op
+	ETag string
+}
+
+// snapshot returns the ETags of all card files
+func (d *Dir) snapshot() (map[string]string, error) {
+	names, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tags := make(map[string]string, len(names))
+	for _, name := range names {
+		s, err := d.stat(name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tags[name] = s.etag
+	}
+	return tags, nil
+}
+
+// Watch polls the collection every interval and reports the card files which were created,
+// modified or deleted since the previous poll, including the changes made through the Dir itself.
+// The channel is closed when the context is done or when the directory can't be read anymore.
+func (d *Dir) Watch(ctx context.Context, interval time.Duration) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+
+		prev, err := d.snapshot()
+		if err != nil {
+			return
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			cur, err := d.snapshot()
+			if err != nil {
+				return
+			}
+			for _, e := range diff(prev, cur) {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+	return events
+}
+
+// diff returns the events between two snapshots, sorted by name
+func diff(prev, cur map[string]string) []Event {
+	var events []Event
+	for name, tag := range cur {
+		old, ok := prev[name]
+		switch {
+		case !ok:
+			events = append(events, Event{Name: name, Op: Created, ETag: tag})
+		case old != tag:
+			events = append(events, Event{Name: name, Op: Modified, ETag: tag})
+		}
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			events = append(events, Event{Name: name, Op: Deleted})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
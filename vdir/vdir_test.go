@@ -0,0 +1,188 @@
+package vdir_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjanvaneersel/vcard"
+	"github.com/arjanvaneersel/vcard/vdir"
+)
+
+func newDir(t *testing.T) *vdir.Dir {
+	d, err := vdir.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	return d
+}
+
+func TestDir(t *testing.T) {
+	d := newDir(t)
+	card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{
+		vcard.FN{FormattedName: "Forrest Gump"},
+		vcard.Property{Name: "UID", Value: "forrest"},
+	}}
+
+	item, err := d.Create(card)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if item.Name != "forrest.vcf" || item.ETag == "" {
+		t.Fatalf("expected forrest.vcf with an ETag, but got %+v", item)
+	}
+	if _, err := d.Create(card); err != vdir.ErrExists {
+		t.Fatalf("expected %v, but got: %v", vdir.ErrExists, err)
+	}
+
+	loaded, err := d.Load("forrest.vcf")
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	expected, _ := card.Generate()
+	got, _ := loaded.Card.Generate()
+	if loaded.ETag != item.ETag || got != expected {
+		t.Fatalf("expected %q with ETag %s, but got %q with %s", expected, item.ETag, got, loaded.ETag)
+	}
+
+	loaded.Card.Fields[0] = vcard.FN{FormattedName: "Captain Forrest Gump"}
+	updated, err := d.Update(loaded)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if updated.ETag == loaded.ETag {
+		t.Fatalf("expected a new ETag, but got %s", updated.ETag)
+	}
+	// the item loaded before the update is stale now
+	if _, err := d.Update(loaded); err != vdir.ErrConflict {
+		t.Fatalf("expected %v, but got: %v", vdir.ErrConflict, err)
+	}
+
+	// a change by another program is detected, even within the same second and size
+	path := filepath.Join(d.Path(), "forrest.vcf")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(string(b), "Captain", "Admiral", 1)), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	fi, _ := os.Stat(path)
+	os.Chtimes(path, fi.ModTime().Add(time.Second), fi.ModTime().Add(time.Second))
+	if err := d.Delete(updated); err != vdir.ErrConflict {
+		t.Fatalf("expected %v, but got: %v", vdir.ErrConflict, err)
+	}
+
+	items, err := d.LoadAll()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if len(items) != 1 || items[0].Card.Fields[0] != (vcard.FN{FormattedName: "Admiral Forrest Gump"}) {
+		t.Fatalf("expected the changed card, but got %+v", items)
+	}
+	if err := d.Delete(items[0]); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if _, err := d.Load("forrest.vcf"); err != vdir.ErrNotFound {
+		t.Fatalf("expected %v, but got: %v", vdir.ErrNotFound, err)
+	}
+}
+
+func TestCreateWithoutUID(t *testing.T) {
+	d := newDir(t)
+	item, err := d.Create(&vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{FormattedName: "Jenny"}}})
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if uid := item.Card.UID(); uid == "" || item.Name != uid+".vcf" {
+		t.Fatalf("expected a generated UID as name, but got %q for %q", item.Name, uid)
+	}
+
+	if name := vdir.FileName("urn:uuid:1/2"); strings.ContainsAny(name, ":/") || !strings.HasSuffix(name, ".vcf") {
+		t.Fatalf("expected a safe file name, but got %q", name)
+	}
+	names, err := d.List()
+	if err != nil || len(names) != 1 {
+		t.Fatalf("expected no temporary files, but got %v: %v", names, err)
+	}
+}
+
+func TestLoadAllBadFiles(t *testing.T) {
+	d := newDir(t)
+	if _, err := d.Create(&vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{FormattedName: "Jenny"}, vcard.Property{Name: "UID", Value: "jenny"}}}); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	two := "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Bubba\r\nEND:VCARD\r\nBEGIN:VCARD\r\nVERSION:4.0\r\nFN:Dan\r\nEND:VCARD\r\n"
+	if err := ioutil.WriteFile(filepath.Join(d.Path(), "two.vcf"), []byte(two), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.Path(), "broken.vcf"), []byte("BEGIN:VCARD\r\nFN:Bubba\r\n"), 0644); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	items, err := d.LoadAll()
+	errs, ok := err.(vdir.FileErrors)
+	if !ok || len(errs) != 2 || errs[0].Name != "broken.vcf" || errs[1].Name != "two.vcf" {
+		t.Fatalf("expected errors for broken.vcf and two.vcf, but got: %v", err)
+	}
+	if len(items) != 1 || items[0].Name != "jenny.vcf" {
+		t.Fatalf("expected the valid card, but got %+v", items)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	d := newDir(t)
+	if err := d.SetMeta("displayname", "Friends"); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if name, err := d.Meta("displayname"); err != nil || name != "Friends" {
+		t.Fatalf("expected %q, but got %q: %v", "Friends", name, err)
+	}
+	if color, err := d.Meta("color"); err != nil || color != "" {
+		t.Fatalf("expected no color, but got %q: %v", color, err)
+	}
+	if err := d.SetMeta("x.vcf", ""); err == nil {
+		t.Fatalf("expected a card file name to fail")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	d := newDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := d.Watch(ctx, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	card := &vcard.VCard{Version: "4.0", Fields: []vcard.FieldFormatter{vcard.FN{FormattedName: "Bubba"}, vcard.Property{Name: "UID", Value: "bubba"}}}
+	item, err := d.Create(card)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	next := func() vdir.Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatalf("expected an event")
+		}
+		return vdir.Event{}
+	}
+	if e := next(); e.Name != "bubba.vcf" || e.Op != vdir.Created || e.ETag != item.ETag {
+		t.Fatalf("expected bubba.vcf to be created, but got %+v", e)
+	}
+
+	if err := d.Delete(item); err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+	if e := next(); e.Name != "bubba.vcf" || e.Op != vdir.Deleted {
+		t.Fatalf("expected bubba.vcf to be deleted, but got %+v", e)
+	}
+
+	cancel()
+	for range events {
+	}
+}
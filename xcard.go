@@ -0,0 +1,89 @@
+package vcard
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// xcardNamespace is the XML namespace of xCard documents
+const xcardNamespace = "urn:ietf:params:xml:ns:vcard-4.0"
+
+// xcardComponents contains the element names of the components of structured properties
+var xcardComponents = map[string][]string{
+	"N":      {"surname", "given", "additional", "prefix", "suffix"},
+	"ADR":    {"pobox", "ext", "street", "locality", "region", "code", "country"},
+	"GENDER": {"sex", "identity"},
+}
+
+func xmlText(b *bytes.Buffer, s string) {
+	xml.EscapeText(b, []byte(s))
+}
+
+func xmlElement(b *bytes.Buffer, name, value string) {
+	fmt.Fprintf(b, "<%s>", name)
+	xmlText(b, value)
+	fmt.Fprintf(b, "</%s>", name)
+}
+
+// XCard returns the xCard (RFC 6351) representation of the VCard. As xCard is
+// based upon vCard 4.0, all fields are formatted as version 4.0.
+func (v *VCard) XCard() ([]byte, error) {
+	props, err := v.properties("4.0")
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s<vcards xmlns=\"%s\"><vcard>", xml.Header, xcardNamespace)
+	for _, p := range props {
+		if p.Group != "" {
+			fmt.Fprintf(&b, "<group name=\"")
+			xmlText(&b, p.Group)
+			fmt.Fprintf(&b, "\">")
+		}
+
+		name := strings.ToLower(p.Name)
+		typ := valueType(p)
+		fmt.Fprintf(&b, "<%s>", name)
+
+		if len(p.Params) > 0 {
+			fmt.Fprintf(&b, "<parameters>")
+			for _, k := range sortedKeys(p.Params) {
+				pt := "text"
+				if k == "PREF" {
+					pt = "integer"
+				}
+				fmt.Fprintf(&b, "<%s>", strings.ToLower(k))
+				for _, pv := range p.Params[k] {
+					xmlElement(&b, pt, pv)
+				}
+				fmt.Fprintf(&b, "</%s>", strings.ToLower(k))
+			}
+			fmt.Fprintf(&b, "</parameters>")
+		}
+
+		names := xcardComponents[p.Name]
+		for _, val := range values(p, typ) {
+			comps, ok := val.([]interface{})
+			if !ok {
+				comps = []interface{}{val}
+			}
+			for i, c := range comps {
+				if i < len(names) {
+					xmlElement(&b, names[i], c.(string))
+					continue
+				}
+				xmlElement(&b, typ, c.(string))
+			}
+		}
+
+		fmt.Fprintf(&b, "</%s>", name)
+		if p.Group != "" {
+			fmt.Fprintf(&b, "</group>")
+		}
+	}
+	fmt.Fprintf(&b, "</vcard></vcards>")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package vcard_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/arjanvaneersel/vcard"
+)
+
+func TestXCard(t *testing.T) {
+	card, err := vcard.New("4.0",
+		vcard.N{FamilyName: "Person", GivenName: "Test"},
+		vcard.FN{"Test & Person"},
+		vcard.Email{Types: []string{vcard.EmailInternet}, Email: "test@example.com"},
+		vcard.Gender{"F"},
+	)
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	got, err := card.XCard()
+	if err != nil {
+		t.Fatalf("expected to pass, but got: %v", err)
+	}
+
+	expected := xml.Header + `<vcards xmlns="urn:ietf:params:xml:ns:vcard-4.0"><vcard>` +
+		`<n><surname>Person</surname><given>Test</given><additional></additional><prefix></prefix><suffix></suffix></n>` +
+		`<fn><text>Test &amp; Person</text></fn>` +
+		`<email><parameters><type><text>internet</text></type></parameters><text>test@example.com</text></email>` +
+		`<gender><sex>F</sex></gender>` +
+		`</vcard></vcards>`
+	if string(got) != expected {
+		t.Fatalf("expected %s, but got %s", expected, got)
+	}
+
+	if err := xml.NewDecoder(strings.NewReader(string(got))).Decode(new(interface{})); err != nil {
+		t.Fatalf("expected well-formed XML, but got: %v", err)
+	}
+}